@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/joho/godotenv"
+
+	"desafio/internal/storage"
+	"desafio/pkg/auth"
+)
+
+const usage = "usage: apikey create <subject> [reader | clerk | admin] | revoke <subject> | hash <key>"
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		panic(err)
+	}
+
+	if len(os.Args) < 3 || len(os.Args) > 4 || (len(os.Args) == 4 && os.Args[1] != "create") {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// hash needs no database, its output goes to API_KEYS as subject:hash:role
+	if os.Args[1] == "hash" {
+		fmt.Println(auth.HashKey(os.Args[2]))
+		return
+	}
+
+	driver := os.Getenv("DATA_BASE")
+	dataSource := os.Getenv("MYSQL_DATA_SOURCE")
+	if driver == storage.SQLite {
+		dataSource = os.Getenv("SQLITE_DATA_SOURCE")
+	}
+
+	db, _, err := storage.OpenDB(driver, dataSource)
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	subject := os.Args[2]
+	switch os.Args[1] {
+	case "create":
+		role := auth.RoleReader
+		if len(os.Args) == 4 {
+			role = os.Args[3]
+		}
+		if _, ok := auth.Roles[role]; !ok {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+
+		key, err := auth.NewKey()
+		if err != nil {
+			panic(err)
+		}
+		if _, err := db.ExecContext(ctx, "INSERT INTO api_keys (subject, key_hash, role) VALUES (?, ?, ?)", subject, auth.HashKey(key), role); err != nil {
+			panic(err)
+		}
+		// the key is not stored, this is the only time it is shown
+		fmt.Println(key)
+	case "revoke":
+		result, err := db.ExecContext(ctx, "UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE subject = ? AND revoked_at IS NULL", subject)
+		if err != nil {
+			panic(err)
+		}
+		revoked, err := result.RowsAffected()
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("revoked %d key(s) of %s\n", revoked, subject)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"desafio/internal/audit"
+	"desafio/pkg/apperr"
+	"desafio/pkg/listing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Audit struct {
+	s audit.Service
+}
+
+func NewHandlerAudit(s audit.Service) *Audit {
+	return &Audit{s}
+}
+
+// GetAll lists the audit log, filtered by entity, entity_id, action, actor and
+// the from and to datetimes.
+func (a *Audit) GetAll() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		params, err := listing.Parse(ctx.Request.URL.Query(), audit.QuerySpec)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		page, err := a.s.ReadAll(ctx, params)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		ctx.JSON(200, page)
+	}
+}
@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"desafio/internal/checkout"
+	"desafio/internal/domain"
+	"desafio/pkg/apperr"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Checkout struct {
+	s checkout.Service
+}
+
+func NewHandlerCheckout(s checkout.Service) *Checkout {
+	return &Checkout{s}
+}
+
+func (c *Checkout) Post() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		request := domain.Checkout{}
+		err := ctx.ShouldBindJSON(&request)
+		if err != nil {
+			apperr.Write(ctx, bindError(err))
+			return
+		}
+
+		result, err := c.s.Checkout(ctx, &request)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		ctx.JSON(201, gin.H{"data": result})
+	}
+}
@@ -1,8 +1,13 @@
 package handler
 
 import (
+	"strconv"
+
 	"desafio/internal/customers"
 	"desafio/internal/domain"
+	"desafio/pkg/apperr"
+	"desafio/pkg/filemanager"
+	"desafio/pkg/listing"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,13 +22,19 @@ func NewHandlerCustomers(s customers.Service) *Customers {
 
 func (c *Customers) GetAll() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		customers, err := c.s.ReadAll(ctx)
+		params, err := listing.Parse(ctx.Request.URL.Query(), customers.QuerySpec)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		page, err := c.s.ReadAll(ctx, params)
 		if err != nil {
-			ctx.JSON(500, gin.H{"error": err.Error()})
+			apperr.Write(ctx, err)
 			return
 		}
 
-		ctx.JSON(200, customers)
+		ctx.JSON(200, page)
 	}
 }
 
@@ -32,13 +43,13 @@ func (c *Customers) Post() gin.HandlerFunc {
 		customer := domain.Customer{}
 		err := ctx.ShouldBindJSON(&customer)
 		if err != nil {
-			ctx.JSON(500, gin.H{"error": err.Error()})
+			apperr.Write(ctx, bindError(err))
 			return
 		}
 
 		err = c.s.Create(ctx, &customer)
 		if err != nil {
-			ctx.JSON(500, gin.H{"error": err.Error()})
+			apperr.Write(ctx, err)
 			return
 		}
 
@@ -46,14 +57,95 @@ func (c *Customers) Post() gin.HandlerFunc {
 	}
 }
 
+func (c *Customers) Get() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			apperr.Write(ctx, customers.ErrServiceInvalidCustomerID.Wrap(err))
+			return
+		}
+
+		customer, err := c.s.Read(ctx, id)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		ctx.JSON(200, gin.H{"data": customer})
+	}
+}
+
+func (c *Customers) Patch() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			apperr.Write(ctx, customers.ErrServiceInvalidCustomerID.Wrap(err))
+			return
+		}
+
+		customer, err := c.s.Read(ctx, id)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		err = ctx.ShouldBindJSON(customer)
+		if err != nil {
+			apperr.Write(ctx, bindError(err))
+			return
+		}
+		customer.Id = id
+
+		err = c.s.Update(ctx, customer)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		ctx.JSON(200, gin.H{"data": customer})
+	}
+}
+
+func (c *Customers) Delete() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			apperr.Write(ctx, customers.ErrServiceInvalidCustomerID.Wrap(err))
+			return
+		}
+
+		err = c.s.Delete(ctx, id)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		ctx.Status(204)
+	}
+}
+
 func (c *Customers) PostManyFromJSON() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		customers, err := c.s.CreateManyFromJSON(ctx)
+		opts, err := batchOptions(ctx)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		src, format, err := importSource(ctx, "customers")
 		if err != nil {
-			ctx.JSON(500, gin.H{"error": err.Error()})
+			apperr.Write(ctx, apperr.Ensure(err, filemanager.ErrInvalidSource))
 			return
 		}
-		ctx.JSON(201, gin.H{"data": customers})
+		defer src.Close()
+
+		report, err := c.s.CreateManyFromJSON(ctx, src, format, opts)
+		if err != nil {
+			apperr.WriteWithData(ctx, err, report)
+			return
+		}
+
+		ctx.JSON(201, gin.H{"data": report})
 	}
 }
 
@@ -61,7 +153,7 @@ func (c *Customers) GetTotalsGroupedByCondition() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		totalGrouped, err := c.s.GetTotalsGroupedByCondition(ctx)
 		if err != nil {
-			ctx.JSON(500, gin.H{"error": err.Error()})
+			apperr.Write(ctx, err)
 			return
 		}
 		ctx.JSON(200, gin.H{"data": totalGrouped})
@@ -72,7 +164,7 @@ func (c *Customers) GetActivesWhoSpentTheMost() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		activesWhoSpentTheMost, err := c.s.GetActivesWhoSpentTheMost(ctx)
 		if err != nil {
-			ctx.JSON(500, gin.H{"error": err.Error()})
+			apperr.Write(ctx, err)
 			return
 		}
 		ctx.JSON(200, gin.H{"data": activesWhoSpentTheMost})
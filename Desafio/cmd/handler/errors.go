@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+
+	"desafio/pkg/apperr"
+)
+
+var errInvalidBody = apperr.New(apperr.Invalid, "INVALID_BODY", "invalid request body")
+
+// bindError returns the application error for a body that could not be bound,
+// with the field that failed when it is known.
+func bindError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return errInvalidBody.Wrap(err).WithFields(apperr.FieldError{
+			Field:   typeErr.Field,
+			Code:    "INVALID_TYPE",
+			Message: "expected " + typeErr.Type.String(),
+		})
+	}
+
+	return apperr.Ensure(err, errInvalidBody)
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"desafio/pkg/database"
+	"desafio/pkg/filemanager"
+
+	"github.com/gin-gonic/gin"
+)
+
+// importSource returns the data to import for resource and its format. It
+// reads the multipart "file" field when there is one, otherwise the raw body,
+// and falls back to the resource seed file in DATA_DIR (datos by default)
+// when the request has no body. The format query parameter overrides the
+// format detected from the file name or Content-Type.
+func importSource(ctx *gin.Context, resource string) (io.ReadCloser, filemanager.Format, error) {
+	format, err := requestedFormat(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if strings.HasPrefix(ctx.ContentType(), "multipart/form-data") {
+		header, err := ctx.FormFile("file")
+		if err != nil {
+			return nil, "", err
+		}
+		if format == "" {
+			if format, err = filemanager.FormatFromFilename(header.Filename); err != nil {
+				return nil, "", err
+			}
+		}
+
+		file, err := header.Open()
+		if err != nil {
+			return nil, "", err
+		}
+		return file, format, nil
+	}
+
+	if ctx.Request.Body != nil && ctx.Request.ContentLength != 0 {
+		if format == "" {
+			if format, err = filemanager.FormatFromContentType(ctx.ContentType()); err != nil {
+				return nil, "", err
+			}
+		}
+		return ctx.Request.Body, format, nil
+	}
+
+	dataDir := os.Getenv("DATA_DIR")
+	if dataDir == "" {
+		dataDir = "datos"
+	}
+	file, err := os.Open(filepath.Join(dataDir, resource+".json"))
+	if err != nil {
+		return nil, "", err
+	}
+	if format == "" {
+		format = filemanager.JSON
+	}
+
+	return file, format, nil
+}
+
+func requestedFormat(ctx *gin.Context) (filemanager.Format, error) {
+	if name := ctx.Query("format"); name != "" {
+		return filemanager.ParseFormat(name)
+	}
+
+	return "", nil
+}
+
+// batchOptions reads the mode (atomic or chunk), chunk_size and on_conflict
+// (fail, skip or overwrite) query parameters.
+func batchOptions(ctx *gin.Context) (database.BatchOptions, error) {
+	chunkSize := 0
+	if raw := ctx.Query("chunk_size"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return database.BatchOptions{}, database.ErrInvalidBatchOptions
+		}
+		chunkSize = n
+	}
+
+	return database.ParseBatchOptions(ctx.Query("mode"), chunkSize, ctx.Query("on_conflict"))
+}
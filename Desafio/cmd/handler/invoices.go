@@ -1,8 +1,13 @@
 package handler
 
 import (
+	"strconv"
+
 	"desafio/internal/domain"
 	"desafio/internal/invoices"
+	"desafio/pkg/apperr"
+	"desafio/pkg/filemanager"
+	"desafio/pkg/listing"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,13 +22,19 @@ func NewHandlerInvoices(s invoices.Service) *Invoices {
 
 func (i *Invoices) GetAll() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		invoices, err := i.s.ReadAll(ctx)
+		params, err := listing.Parse(ctx.Request.URL.Query(), invoices.QuerySpec)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		page, err := i.s.ReadAll(ctx, params)
 		if err != nil {
-			ctx.JSON(500, gin.H{"error": err.Error()})
+			apperr.Write(ctx, err)
 			return
 		}
 
-		ctx.JSON(200, invoices)
+		ctx.JSON(200, page)
 	}
 }
 
@@ -32,13 +43,13 @@ func (i *Invoices) Post() gin.HandlerFunc {
 		invoices := domain.Invoice{}
 		err := ctx.ShouldBindJSON(&invoices)
 		if err != nil {
-			ctx.JSON(500, gin.H{"error": err.Error()})
+			apperr.Write(ctx, bindError(err))
 			return
 		}
 
 		err = i.s.Create(ctx, &invoices)
 		if err != nil {
-			ctx.JSON(500, gin.H{"error": err.Error()})
+			apperr.Write(ctx, err)
 			return
 		}
 
@@ -46,26 +57,119 @@ func (i *Invoices) Post() gin.HandlerFunc {
 	}
 }
 
+func (i *Invoices) Get() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			apperr.Write(ctx, invoices.ErrServiceInvalidInvoiceID.Wrap(err))
+			return
+		}
+
+		invoice, err := i.s.Read(ctx, id)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		ctx.JSON(200, gin.H{"data": invoice})
+	}
+}
+
+func (i *Invoices) Patch() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			apperr.Write(ctx, invoices.ErrServiceInvalidInvoiceID.Wrap(err))
+			return
+		}
+
+		invoice, err := i.s.Read(ctx, id)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		err = ctx.ShouldBindJSON(invoice)
+		if err != nil {
+			apperr.Write(ctx, bindError(err))
+			return
+		}
+		invoice.Id = id
+
+		err = i.s.Update(ctx, invoice)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		ctx.JSON(200, gin.H{"data": invoice})
+	}
+}
+
+func (i *Invoices) Delete() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			apperr.Write(ctx, invoices.ErrServiceInvalidInvoiceID.Wrap(err))
+			return
+		}
+
+		err = i.s.Delete(ctx, id)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		ctx.Status(204)
+	}
+}
+
 func (i *Invoices) PostManyFromJSON() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		invoices, err := i.s.CreateManyFromJSON(ctx)
+		opts, err := batchOptions(ctx)
 		if err != nil {
-			ctx.JSON(500, gin.H{"error": err.Error()})
+			apperr.Write(ctx, err)
 			return
 		}
 
-		ctx.JSON(201, gin.H{"data": invoices})
+		src, format, err := importSource(ctx, "invoices")
+		if err != nil {
+			apperr.Write(ctx, apperr.Ensure(err, filemanager.ErrInvalidSource))
+			return
+		}
+		defer src.Close()
+
+		report, err := i.s.CreateManyFromJSON(ctx, src, format, opts)
+		if err != nil {
+			apperr.WriteWithData(ctx, err, report)
+			return
+		}
+
+		ctx.JSON(201, gin.H{"data": report})
 	}
 }
 
 func (i *Invoices) UpdateTotals() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		err := i.s.UpdateTotals(ctx)
+		filter := domain.InvoiceTotalsFilter{
+			DatetimeFrom: ctx.Query("datetime_from"),
+			DatetimeTo:   ctx.Query("datetime_to"),
+		}
+		if id := ctx.Query("invoice_id"); id != "" {
+			invoiceId, err := strconv.Atoi(id)
+			if err != nil {
+				apperr.Write(ctx, invoices.ErrServiceInvalidInvoiceID.Wrap(err))
+				return
+			}
+			filter.InvoiceId = invoiceId
+		}
+
+		report, err := i.s.UpdateTotals(ctx, filter)
 		if err != nil {
-			ctx.JSON(500, gin.H{"error": err.Error()})
+			apperr.Write(ctx, err)
 			return
 		}
 
-		ctx.JSON(200, gin.H{"data": "Totals updated successfully"})
+		ctx.JSON(200, gin.H{"data": report})
 	}
 }
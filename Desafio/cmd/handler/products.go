@@ -1,8 +1,13 @@
 package handler
 
 import (
+	"strconv"
+
 	"desafio/internal/domain"
 	"desafio/internal/products"
+	"desafio/pkg/apperr"
+	"desafio/pkg/filemanager"
+	"desafio/pkg/listing"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,12 +22,19 @@ func NewHandlerProducts(s products.Service) *Products {
 
 func (p *Products) GetAll() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		products, err := p.s.ReadAll(ctx)
+		params, err := listing.Parse(ctx.Request.URL.Query(), products.QuerySpec)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		page, err := p.s.ReadAll(ctx, params)
 		if err != nil {
-			ctx.JSON(500, gin.H{"error": err.Error()})
+			apperr.Write(ctx, err)
 			return
 		}
-		ctx.JSON(200, products)
+
+		ctx.JSON(200, page)
 	}
 }
 
@@ -31,26 +43,107 @@ func (p *Products) Post() gin.HandlerFunc {
 		products := domain.Product{}
 		err := ctx.ShouldBindJSON(&products)
 		if err != nil {
-			ctx.JSON(500, gin.H{"error": err.Error()})
+			apperr.Write(ctx, bindError(err))
 			return
 		}
 		err = p.s.Create(ctx, &products)
 		if err != nil {
-			ctx.JSON(500, gin.H{"error": err.Error()})
+			apperr.Write(ctx, err)
 			return
 		}
 		ctx.JSON(201, gin.H{"data": products})
 	}
 }
 
+func (p *Products) Get() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			apperr.Write(ctx, products.ErrServiceInvalidProductID.Wrap(err))
+			return
+		}
+
+		product, err := p.s.Read(ctx, id)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		ctx.JSON(200, gin.H{"data": product})
+	}
+}
+
+func (p *Products) Patch() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			apperr.Write(ctx, products.ErrServiceInvalidProductID.Wrap(err))
+			return
+		}
+
+		product, err := p.s.Read(ctx, id)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		err = ctx.ShouldBindJSON(product)
+		if err != nil {
+			apperr.Write(ctx, bindError(err))
+			return
+		}
+		product.Id = id
+
+		err = p.s.Update(ctx, product)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		ctx.JSON(200, gin.H{"data": product})
+	}
+}
+
+func (p *Products) Delete() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			apperr.Write(ctx, products.ErrServiceInvalidProductID.Wrap(err))
+			return
+		}
+
+		err = p.s.Delete(ctx, id)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		ctx.Status(204)
+	}
+}
+
 func (p *Products) PostManyFromJSON() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		products, err := p.s.CreateManyFromJSON(ctx)
+		opts, err := batchOptions(ctx)
 		if err != nil {
-			ctx.JSON(500, gin.H{"error": err.Error()})
+			apperr.Write(ctx, err)
 			return
 		}
-		ctx.JSON(201, gin.H{"data": products})
+
+		src, format, err := importSource(ctx, "products")
+		if err != nil {
+			apperr.Write(ctx, apperr.Ensure(err, filemanager.ErrInvalidSource))
+			return
+		}
+		defer src.Close()
+
+		report, err := p.s.CreateManyFromJSON(ctx, src, format, opts)
+		if err != nil {
+			apperr.WriteWithData(ctx, err, report)
+			return
+		}
+
+		ctx.JSON(201, gin.H{"data": report})
 	}
 }
 
@@ -58,9 +151,30 @@ func (p *Products) GetQtySaledGroupedByDescription() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		qtySaledGrouped, err := p.s.GetQtySaledGroupedByDescription(ctx)
 		if err != nil {
-			ctx.JSON(500, gin.H{"error": err.Error()})
+			apperr.Write(ctx, err)
 			return
 		}
 		ctx.JSON(200, gin.H{"data": qtySaledGrouped})
 	}
 }
+
+func (p *Products) GetLowStock() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		threshold := products.DefaultLowStockThreshold
+		if value, ok := ctx.GetQuery("threshold"); ok {
+			var err error
+			threshold, err = strconv.Atoi(value)
+			if err != nil {
+				apperr.Write(ctx, products.ErrServiceInvalidStockThreshold.Wrap(err))
+				return
+			}
+		}
+
+		lowStock, err := p.s.GetLowStock(ctx, threshold)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+		ctx.JSON(200, gin.H{"data": lowStock})
+	}
+}
@@ -1,8 +1,13 @@
 package handler
 
 import (
+	"strconv"
+
 	"desafio/internal/domain"
 	"desafio/internal/sales"
+	"desafio/pkg/apperr"
+	"desafio/pkg/filemanager"
+	"desafio/pkg/listing"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,13 +22,19 @@ func NewHandlerSales(s sales.Service) *Sales {
 
 func (s *Sales) GetAll() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		invoices, err := s.s.ReadAll(ctx)
+		params, err := listing.Parse(ctx.Request.URL.Query(), sales.QuerySpec)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		page, err := s.s.ReadAll(ctx, params)
 		if err != nil {
-			ctx.JSON(500, gin.H{"error": err.Error()})
+			apperr.Write(ctx, err)
 			return
 		}
 
-		ctx.JSON(200, invoices)
+		ctx.JSON(200, page)
 	}
 }
 
@@ -32,13 +43,13 @@ func (s *Sales) Post() gin.HandlerFunc {
 		sale := domain.Sale{}
 		err := ctx.ShouldBindJSON(&sale)
 		if err != nil {
-			ctx.JSON(500, gin.H{"error": err.Error()})
+			apperr.Write(ctx, bindError(err))
 			return
 		}
 
 		err = s.s.Create(ctx, &sale)
 		if err != nil {
-			ctx.JSON(500, gin.H{"error": err.Error()})
+			apperr.Write(ctx, err)
 			return
 		}
 
@@ -46,14 +57,94 @@ func (s *Sales) Post() gin.HandlerFunc {
 	}
 }
 
+func (s *Sales) Get() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			apperr.Write(ctx, sales.ErrServiceInvalidSaleID.Wrap(err))
+			return
+		}
+
+		sale, err := s.s.Read(ctx, id)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		ctx.JSON(200, gin.H{"data": sale})
+	}
+}
+
+func (s *Sales) Patch() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			apperr.Write(ctx, sales.ErrServiceInvalidSaleID.Wrap(err))
+			return
+		}
+
+		sale, err := s.s.Read(ctx, id)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		err = ctx.ShouldBindJSON(sale)
+		if err != nil {
+			apperr.Write(ctx, bindError(err))
+			return
+		}
+		sale.Id = id
+
+		err = s.s.Update(ctx, sale)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		ctx.JSON(200, gin.H{"data": sale})
+	}
+}
+
+func (s *Sales) Delete() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			apperr.Write(ctx, sales.ErrServiceInvalidSaleID.Wrap(err))
+			return
+		}
+
+		err = s.s.Delete(ctx, id)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		ctx.Status(204)
+	}
+}
+
 func (s *Sales) PostManyFromJSON() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		sales, err := s.s.CreateManyFromJSON(ctx)
+		opts, err := batchOptions(ctx)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		src, format, err := importSource(ctx, "sales")
+		if err != nil {
+			apperr.Write(ctx, apperr.Ensure(err, filemanager.ErrInvalidSource))
+			return
+		}
+		defer src.Close()
+
+		report, err := s.s.CreateManyFromJSON(ctx, src, format, opts)
 		if err != nil {
-			ctx.JSON(500, gin.H{"error": err.Error()})
+			apperr.WriteWithData(ctx, err, report)
 			return
 		}
 
-		ctx.JSON(201, gin.H{"data": sales})
+		ctx.JSON(201, gin.H{"data": report})
 	}
 }
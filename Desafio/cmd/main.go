@@ -1,13 +1,17 @@
 package main
 
 import (
-	"database/sql"
+	"errors"
+	"fmt"
 	"os"
+	"strconv"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
 	"github.com/joho/godotenv"
 
 	"desafio/cmd/router"
+	"desafio/internal/storage"
+	"desafio/pkg/auth"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,15 +21,92 @@ func main() {
 		panic(err)
 	}
 
-	db, err := sql.Open(os.Getenv("DATA_BASE"), os.Getenv("MYSQL_DATA_SOURCE"))
+	driver := os.Getenv("DATA_BASE")
+	dataSource := os.Getenv("MYSQL_DATA_SOURCE")
+	if driver == storage.SQLite {
+		dataSource = os.Getenv("SQLITE_DATA_SOURCE")
+	}
+
+	backend, err := storage.Open(driver, dataSource)
+	if err != nil {
+		panic(err)
+	}
+	defer backend.Close()
+
+	middleware, err := authentication(backend)
 	if err != nil {
 		panic(err)
 	}
 
 	server := gin.Default()
 
-	router.NewRouter(server, db).MapRoutes()
+	router.NewRouter(server, backend, middleware...).MapRoutes()
+	if missing := router.Policy.Missing(server.Routes(), "/api/v1"); len(missing) > 0 {
+		panic(fmt.Sprintf("routes without a permission in router.Policy: %v", missing))
+	}
 
 	server.Run()
 
 }
+
+// authentication builds the auth middleware from the environment: API keys
+// from API_KEYS and from the api_keys table, and bearer tokens verified with
+// the key in JWT_KEY_FILE, followed by the authorization of router.Policy.
+// AUTH_DISABLED=true leaves the API open, for local runs.
+func authentication(backend *storage.Backend) ([]gin.HandlerFunc, error) {
+	if disabled, _ := strconv.ParseBool(os.Getenv("AUTH_DISABLED")); disabled {
+		return nil, nil
+	}
+
+	var stores []auth.KeyStore
+	if spec := os.Getenv("API_KEYS"); spec != "" {
+		keys, err := auth.ParseKeys(spec)
+		if err != nil {
+			return nil, err
+		}
+		stores = append(stores, keys)
+	}
+	if backend.APIKeys != nil {
+		stores = append(stores, backend.APIKeys)
+	}
+
+	var authenticators []auth.Authenticator
+	if len(stores) > 0 {
+		authenticators = append(authenticators, auth.NewAPIKeyAuthenticator(stores...))
+	}
+
+	if path := os.Getenv("JWT_KEY_FILE"); path != "" {
+		algorithm := os.Getenv("JWT_ALGORITHM")
+		if algorithm == "" {
+			algorithm = auth.HS256
+		}
+		key, err := auth.LoadJWTKey(algorithm, path)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg := auth.JWTConfig{
+			Algorithm: algorithm,
+			Key:       key,
+			Issuer:    os.Getenv("JWT_ISSUER"),
+			Audience:  os.Getenv("JWT_AUDIENCE"),
+		}
+		if leeway := os.Getenv("JWT_LEEWAY"); leeway != "" {
+			if cfg.Leeway, err = time.ParseDuration(leeway); err != nil {
+				return nil, err
+			}
+		}
+
+		authenticator, err := auth.NewJWTAuthenticator(cfg)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, authenticator)
+	}
+
+	if len(authenticators) == 0 {
+		return nil, errors.New("no authentication configured, set API_KEYS or JWT_KEY_FILE, or AUTH_DISABLED=true")
+	}
+
+	return []gin.HandlerFunc{auth.Middleware(authenticators...), router.Policy.Authorize(nil)}, nil
+}
@@ -1,29 +1,77 @@
 package router
 
 import (
-	"database/sql"
-
 	"desafio/cmd/handler"
+	"desafio/internal/audit"
+	"desafio/internal/checkout"
 	"desafio/internal/customers"
 	"desafio/internal/invoices"
 	"desafio/internal/products"
 	"desafio/internal/sales"
+	"desafio/internal/storage"
+	"desafio/pkg/auth"
 
 	"github.com/gin-gonic/gin"
 )
 
+// Policy is the permission each route in MapRoutes requires. Readers can call
+// every GET and clerks record the day to day sales, creating invoices and
+// sales. Everything else, like editing customers, prices, stock or recorded
+// invoices and sales, bulk imports, recomputing totals, deletes and the audit
+// log, is for admins.
+var Policy = auth.Policy{
+	"GET /api/v1/customers/":                                auth.Read,
+	"POST /api/v1/customers/":                               auth.Admin,
+	"GET /api/v1/customers/:id":                             auth.Read,
+	"PATCH /api/v1/customers/:id":                           auth.Admin,
+	"DELETE /api/v1/customers/:id":                          auth.Admin,
+	"POST /api/v1/customers/json":                           auth.Admin,
+	"GET /api/v1/customers/totals":                          auth.Read,
+	"GET /api/v1/customers/top5-actives-who-spent-the-most": auth.Read,
+
+	"GET /api/v1/invoices/":          auth.Read,
+	"POST /api/v1/invoices/":         auth.Write,
+	"GET /api/v1/invoices/:id":       auth.Read,
+	"PATCH /api/v1/invoices/:id":     auth.Admin,
+	"DELETE /api/v1/invoices/:id":    auth.Admin,
+	"POST /api/v1/invoices/json":     auth.Admin,
+	"POST /api/v1/invoices/checkout": auth.Write,
+	"PUT /api/v1/invoices/totals":    auth.Admin,
+
+	"GET /api/v1/products/":               auth.Read,
+	"POST /api/v1/products/":              auth.Admin,
+	"GET /api/v1/products/:id":            auth.Read,
+	"PATCH /api/v1/products/:id":          auth.Admin,
+	"DELETE /api/v1/products/:id":         auth.Admin,
+	"POST /api/v1/products/json":          auth.Admin,
+	"GET /api/v1/products/top5-qty-saled": auth.Read,
+	"GET /api/v1/products/low-stock":      auth.Read,
+
+	"GET /api/v1/sales/":       auth.Read,
+	"POST /api/v1/sales/":      auth.Write,
+	"GET /api/v1/sales/:id":    auth.Read,
+	"PATCH /api/v1/sales/:id":  auth.Admin,
+	"DELETE /api/v1/sales/:id": auth.Admin,
+	"POST /api/v1/sales/json":  auth.Admin,
+
+	"GET /api/v1/audit/": auth.Admin,
+}
+
 type Router interface {
 	MapRoutes()
 }
 
 type router struct {
-	r  *gin.Engine
-	rg *gin.RouterGroup
-	db *sql.DB
+	r       *gin.Engine
+	rg      *gin.RouterGroup
+	backend *storage.Backend
+	audit   audit.Service
 }
 
-func NewRouter(r *gin.Engine, db *sql.DB) Router {
-	return &router{r, r.Group("/api/v1"), db}
+// NewRouter maps the routes under /api/v1, behind middleware when given,
+// like the authentication.
+func NewRouter(r *gin.Engine, backend *storage.Backend, middleware ...gin.HandlerFunc) Router {
+	return &router{r, r.Group("/api/v1", middleware...), backend, audit.NewService(backend.Audit)}
 }
 
 func (r *router) MapRoutes() {
@@ -31,17 +79,21 @@ func (r *router) MapRoutes() {
 	r.buildInvoicesRoutes()
 	r.buildProductsRoutes()
 	r.buildSalesRoutes()
+	r.buildAuditRoutes()
 }
 
 func (r *router) buildCustomersRoutes() {
-	repo := customers.NewRepository(r.db)
-	service := customers.NewService(repo)
+	repo := r.backend.Customers
+	service := customers.NewService(r.backend.Transactor, repo, r.audit)
 	handler := handler.NewHandlerCustomers(service)
 
 	c := r.rg.Group("/customers")
 	{
 		c.GET("/", handler.GetAll())
 		c.POST("/", handler.Post())
+		c.GET("/:id", handler.Get())
+		c.PATCH("/:id", handler.Patch())
+		c.DELETE("/:id", handler.Delete())
 		c.POST("/json", handler.PostManyFromJSON())
 		c.GET("/totals", handler.GetTotalsGroupedByCondition())
 		c.GET("/top5-actives-who-spent-the-most", handler.GetActivesWhoSpentTheMost())
@@ -49,42 +101,64 @@ func (r *router) buildCustomersRoutes() {
 }
 
 func (r *router) buildInvoicesRoutes() {
-	repo := invoices.NewRepository(r.db)
-	service := invoices.NewService(repo)
+	repo := r.backend.Invoices
+	service := invoices.NewService(r.backend.Transactor, repo, r.backend.Customers, r.audit)
+	checkoutService := checkout.NewService(r.backend.Transactor, repo, r.backend.Sales, r.backend.Products, r.backend.Customers, r.audit)
+	checkoutHandler := handler.NewHandlerCheckout(checkoutService)
 	handler := handler.NewHandlerInvoices(service)
 
 	i := r.rg.Group("/invoices")
 	{
 		i.GET("/", handler.GetAll())
 		i.POST("/", handler.Post())
+		i.GET("/:id", handler.Get())
+		i.PATCH("/:id", handler.Patch())
+		i.DELETE("/:id", handler.Delete())
 		i.POST("/json", handler.PostManyFromJSON())
+		i.POST("/checkout", checkoutHandler.Post())
 		i.PUT("/totals", handler.UpdateTotals())
 	}
 }
 
 func (r *router) buildProductsRoutes() {
-	repo := products.NewRepository(r.db)
-	service := products.NewService(repo)
+	repo := r.backend.Products
+	service := products.NewService(r.backend.Transactor, repo, r.audit)
 	handler := handler.NewHandlerProducts(service)
 
 	p := r.rg.Group("/products")
 	{
 		p.GET("/", handler.GetAll())
 		p.POST("/", handler.Post())
+		p.GET("/:id", handler.Get())
+		p.PATCH("/:id", handler.Patch())
+		p.DELETE("/:id", handler.Delete())
 		p.POST("/json", handler.PostManyFromJSON())
 		p.GET("/top5-qty-saled", handler.GetQtySaledGroupedByDescription())
+		p.GET("/low-stock", handler.GetLowStock())
 	}
 }
 
 func (r *router) buildSalesRoutes() {
-	repo := sales.NewRepository(r.db)
-	service := sales.NewService(repo)
+	repo := r.backend.Sales
+	service := sales.NewService(r.backend.Transactor, repo, r.backend.Products, r.backend.Invoices, r.audit)
 	handler := handler.NewHandlerSales(service)
 
 	s := r.rg.Group("/sales")
 	{
 		s.GET("/", handler.GetAll())
 		s.POST("/", handler.Post())
+		s.GET("/:id", handler.Get())
+		s.PATCH("/:id", handler.Patch())
+		s.DELETE("/:id", handler.Delete())
 		s.POST("/json", handler.PostManyFromJSON())
 	}
 }
+
+func (r *router) buildAuditRoutes() {
+	handler := handler.NewHandlerAudit(r.audit)
+
+	a := r.rg.Group("/audit")
+	{
+		a.GET("/", handler.GetAll())
+	}
+}
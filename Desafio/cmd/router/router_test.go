@@ -0,0 +1,101 @@
+package router_test
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"desafio/cmd/router"
+	"desafio/internal/storage"
+	"desafio/pkg/apperr"
+	"desafio/pkg/auth"
+)
+
+// newServer maps the routes on a memory backend behind the API key of a
+// clerk, authorized by router.Policy.
+func newServer(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	keys := auth.StaticKeys{
+		auth.HashKey("clerk-key"): {Subject: "clara", Method: auth.MethodAPIKey, Roles: []string{auth.RoleClerk}},
+	}
+	middleware := []gin.HandlerFunc{
+		auth.Middleware(auth.NewAPIKeyAuthenticator(keys)),
+		router.Policy.Authorize(log.New(io.Discard, "", 0)),
+	}
+
+	server := gin.New()
+	router.NewRouter(server, storage.NewMemory(), middleware...).MapRoutes()
+	if missing := router.Policy.Missing(server.Routes(), "/api/v1"); len(missing) > 0 {
+		t.Fatalf("routes without a permission: %v", missing)
+	}
+
+	return server
+}
+
+func TestClerkCannotChangeWhatItDoesNotSell(t *testing.T) {
+	server := newServer(t)
+
+	for _, route := range []string{
+		"POST /api/v1/customers/",
+		"PATCH /api/v1/customers/1",
+		"PATCH /api/v1/invoices/1",
+		"POST /api/v1/products/",
+		"PATCH /api/v1/products/1",
+		"PATCH /api/v1/sales/1",
+		"DELETE /api/v1/products/1",
+		"PUT /api/v1/invoices/totals",
+		"GET /api/v1/audit/",
+	} {
+		t.Run(route, func(t *testing.T) {
+			method, path, _ := strings.Cut(route, " ")
+			request := httptest.NewRequest(method, path, strings.NewReader(`{}`))
+			request.Header.Set("Content-Type", "application/json")
+			request.Header.Set(auth.APIKeyHeader, "clerk-key")
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, request)
+
+			if response.Code != http.StatusForbidden {
+				t.Fatalf("got status %d: %s", response.Code, response.Body)
+			}
+			if got := response.Header().Get("Content-Type"); !strings.HasPrefix(got, apperr.ContentType) {
+				t.Fatalf("got content type %q", got)
+			}
+			problem := apperr.Problem{}
+			if err := json.Unmarshal(response.Body.Bytes(), &problem); err != nil || problem.Code != "FORBIDDEN" {
+				t.Fatalf("got problem %+v, %v", problem, err)
+			}
+		})
+	}
+}
+
+func TestClerkRecordsSales(t *testing.T) {
+	server := newServer(t)
+
+	for _, route := range []string{
+		"POST /api/v1/invoices/",
+		"POST /api/v1/invoices/checkout",
+		"POST /api/v1/sales/",
+	} {
+		t.Run(route, func(t *testing.T) {
+			method, path, _ := strings.Cut(route, " ")
+			request := httptest.NewRequest(method, path, strings.NewReader(`{}`))
+			request.Header.Set("Content-Type", "application/json")
+			request.Header.Set(auth.APIKeyHeader, "clerk-key")
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, request)
+
+			// the empty body is refused by the handler, past the authorization
+			if response.Code == http.StatusForbidden || response.Code == http.StatusUnauthorized {
+				t.Fatalf("got status %d: %s", response.Code, response.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+
+	"desafio/internal/domain"
+	"desafio/pkg/database"
+	"desafio/pkg/listing"
+)
+
+// QuerySpec whitelists the filters and sort fields accepted by ReadAll.
+var QuerySpec = listing.Spec{
+	Filters: []listing.Filter{
+		{Param: "entity", Column: "entity", Operator: listing.Equal, Kind: listing.String},
+		{Param: "entity_id", Column: "entity_id", Operator: listing.Equal, Kind: listing.Int},
+		{Param: "action", Column: "action", Operator: listing.Equal, Kind: listing.String},
+		{Param: "actor", Column: "actor", Operator: listing.Equal, Kind: listing.String},
+		{Param: "from", Column: "occurred_at", Operator: listing.GreaterEqual, Kind: listing.Datetime},
+		{Param: "to", Column: "occurred_at", Operator: listing.LessEqual, Kind: listing.Datetime},
+	},
+	Sorts: map[string]string{
+		"id": "id",
+		"at": "occurred_at",
+	},
+	DefaultSort: "id",
+}
+
+// Column returns the value of a column of QuerySpec for the audit entry, as the
+// listing of the memory storage and the page cursors read it.
+func Column(e *domain.AuditEntry, column string) any {
+	switch column {
+	case "entity":
+		return e.Entity
+	case "entity_id":
+		return e.EntityId
+	case "action":
+		return e.Action
+	case "actor":
+		return e.Actor
+	case "occurred_at":
+		return e.At
+	default:
+		return e.Id
+	}
+}
+
+type Repository interface {
+	Create(ctx context.Context, entry *domain.AuditEntry) (int64, error)
+	ReadAll(ctx context.Context, params listing.Params) ([]*domain.AuditEntry, int, error)
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+// NewRepository stores the log in the audit_log table, the queries are the
+// same for MySQL and SQLite.
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db}
+}
+
+// Create inserts entry in the transaction carried by ctx, if any, so the log
+// is committed or rolled back along with the change it records.
+func (r *repository) Create(ctx context.Context, entry *domain.AuditEntry) (int64, error) {
+	query := `INSERT INTO audit_log (entity, entity_id, action, actor, occurred_at, before_state, after_state) VALUES (?, ?, ?, ?, ?, ?, ?);`
+
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, entry.Entity, entry.EntityId, entry.Action, entry.Actor, entry.At, nullable(entry.Before), nullable(entry.After))
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (r *repository) ReadAll(ctx context.Context, params listing.Params) ([]*domain.AuditEntry, int, error) {
+	where, values := params.Where()
+
+	total := 0
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx, `SELECT COUNT(*) FROM audit_log`+where+";", values...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	where, values = params.Seek()
+	orderBy, paging := params.OrderBy()
+	query := `SELECT id, entity, entity_id, action, actor, occurred_at, before_state, after_state FROM audit_log` + where + orderBy + ";"
+
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, append(values, paging...)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := make([]*domain.AuditEntry, 0)
+	for rows.Next() {
+		entry := domain.AuditEntry{}
+		var before, after []byte
+		err := rows.Scan(&entry.Id, &entry.Entity, &entry.EntityId, &entry.Action, &entry.Actor, &entry.At, &before, &after)
+		if err != nil {
+			return nil, 0, err
+		}
+		entry.Before, entry.After = before, after
+		entries = append(entries, &entry)
+	}
+
+	return entries, total, nil
+}
+
+// nullable stores a missing state as NULL rather than an empty string, which
+// is not valid JSON.
+func nullable(state []byte) any {
+	if state == nil {
+		return nil
+	}
+
+	return string(state)
+}
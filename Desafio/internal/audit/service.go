@@ -0,0 +1,145 @@
+package audit
+
+import (
+	"context"
+	"desafio/internal/domain"
+	"desafio/pkg/auth"
+	"desafio/pkg/filemanager"
+	"desafio/pkg/listing"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const datetimeLayout = "2006-01-02 15:04:05"
+
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	// ActionImport is a bulk load, recorded once with its report as the after
+	// state since the inserted rows may have no ids yet. The rows it
+	// overwrites are recorded as updates of their own.
+	ActionImport = "import"
+)
+
+// Anonymous is the actor of the changes made with authentication disabled.
+const Anonymous = "anonymous"
+
+// Recorder is what the other services record their changes with. It must be
+// called with the context of the transaction making the change, so both are
+// committed together.
+type Recorder interface {
+	Record(ctx context.Context, entity string, id int, action string, before, after any) error
+}
+
+type Service interface {
+	Recorder
+	ReadAll(ctx context.Context, params listing.Params) (*listing.Page[*domain.AuditEntry], error)
+}
+
+type service struct {
+	r   Repository
+	now func() time.Time
+}
+
+func NewService(r Repository) Service {
+	return &service{r, time.Now}
+}
+
+// Record logs action on the entity with the given id, made by the principal
+// of ctx. before and after are stored as JSON, nil ones as null.
+func (s *service) Record(ctx context.Context, entity string, id int, action string, before, after any) error {
+	entry := &domain.AuditEntry{
+		Entity:   entity,
+		EntityId: id,
+		Action:   action,
+		Actor:    Anonymous,
+		At:       s.now().Format(datetimeLayout),
+	}
+	if principal, ok := auth.FromContext(ctx); ok {
+		entry.Actor = principal.Subject
+	}
+
+	var err error
+	if entry.Before, err = state(before); err != nil {
+		return err
+	}
+	if entry.After, err = state(after); err != nil {
+		return err
+	}
+
+	insertedId, err := s.r.Create(ctx, entry)
+	if err != nil {
+		return err
+	}
+
+	entry.Id = int(insertedId)
+
+	return nil
+}
+
+func (s *service) ReadAll(ctx context.Context, params listing.Params) (*listing.Page[*domain.AuditEntry], error) {
+	entries, total, err := s.r.ReadAll(ctx, params.WithLookahead())
+	if err != nil {
+		return nil, err
+	}
+
+	return listing.NewPage(entries, total, params, Column), nil
+}
+
+// RecordImport records a bulk load of entity with its report.
+func RecordImport(ctx context.Context, r Recorder, entity string, report *filemanager.Report) error {
+	return r.Record(ctx, entity, 0, ActionImport, nil, report)
+}
+
+// Overwrites returns the database.BatchOptions Overwritten hook of an import
+// of entity. It records every stored row the import replaces as an update,
+// with the states read before and after the write.
+func Overwrites[T any](r Recorder, entity string, read func(ctx context.Context, id int) (T, error)) func(ctx context.Context, keys []any, write func() error) error {
+	return func(ctx context.Context, keys []any, write func() error) error {
+		ids := make([]int, 0, len(keys))
+		befores := make([]T, 0, len(keys))
+		for _, key := range keys {
+			id, ok := key.(int)
+			if !ok {
+				return fmt.Errorf("audit: %s key %v is not an id", entity, key)
+			}
+			before, err := read(ctx, id)
+			if err != nil {
+				return err
+			}
+			ids, befores = append(ids, id), append(befores, before)
+		}
+
+		if err := write(); err != nil {
+			return err
+		}
+
+		for i, id := range ids {
+			after, err := read(ctx, id)
+			if err != nil {
+				return err
+			}
+			if err := r.Record(ctx, entity, id, ActionUpdate, befores[i], after); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// state marshals v, leaving nil values, typed nil pointers included, out.
+func state(v any) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil || string(data) == "null" {
+		return nil, err
+	}
+
+	return data, nil
+}
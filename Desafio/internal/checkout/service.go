@@ -0,0 +1,126 @@
+package checkout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"desafio/internal/audit"
+	"desafio/internal/customers"
+	"desafio/internal/domain"
+	"desafio/internal/invoices"
+	"desafio/internal/products"
+	"desafio/internal/sales"
+	"desafio/pkg/apperr"
+	"desafio/pkg/database"
+	"desafio/pkg/validate"
+)
+
+const datetimeLayout = "2006-01-02 15:04:05"
+
+var (
+	ErrServiceProductNotFound   = apperr.New(apperr.NotFound, "PRODUCT_NOT_FOUND", "product not found")
+	ErrServiceInsufficientStock = apperr.New(apperr.Conflict, "INSUFFICIENT_STOCK", "insufficient stock")
+)
+
+type Service interface {
+	Checkout(ctx context.Context, checkout *domain.Checkout) (*domain.CheckoutResult, error)
+}
+
+type service struct {
+	tx        database.Transactor
+	invoices  invoices.Repository
+	sales     sales.Repository
+	products  products.Repository
+	validator validate.Validator[*domain.Checkout]
+	audit     audit.Recorder
+}
+
+func NewService(tx database.Transactor, i invoices.Repository, s sales.Repository, p products.Repository, c customers.Repository, a audit.Recorder) Service {
+	lines := validate.New(
+		validate.Value("product_id", func(l domain.CheckoutLine) int { return l.ProductId }, validate.Min(1), validate.Exists(p.Read, products.ErrRepositoryProductNotFound)),
+		validate.Value("quantity", func(l domain.CheckoutLine) int { return l.Quantity }, validate.Min(1)),
+	)
+	validator := validate.New(
+		validate.Value("customer_id", func(checkout *domain.Checkout) int { return checkout.CustomerId }, validate.Min(1), validate.Exists(c.Read, customers.ErrRepositoryCustomerNotFound)),
+		validate.Value("lines", func(checkout *domain.Checkout) []domain.CheckoutLine { return checkout.Lines }, validate.NotEmpty[domain.CheckoutLine]()),
+		validate.Each("lines", func(checkout *domain.Checkout) []domain.CheckoutLine { return checkout.Lines }, lines),
+	)
+
+	return &service{tx, i, s, p, validator, a}
+}
+
+// Checkout creates the invoice and one sale per line in a single transaction.
+// The invoice total is computed from the current product prices and every
+// line takes its units from the product stock, so nothing is persisted unless
+// every line can be priced, stocked and inserted.
+func (s *service) Checkout(ctx context.Context, checkout *domain.Checkout) (*domain.CheckoutResult, error) {
+	if err := s.validator.Validate(ctx, checkout); err != nil {
+		return nil, err
+	}
+
+	result := &domain.CheckoutResult{}
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		total := 0.0
+		for _, line := range checkout.Lines {
+			product, err := s.products.Read(ctx, line.ProductId)
+			if err != nil {
+				if errors.Is(err, products.ErrRepositoryProductNotFound) {
+					return fmt.Errorf("%w: %d", ErrServiceProductNotFound, line.ProductId)
+				}
+				return err
+			}
+			total += product.Price * float64(line.Quantity)
+
+			if err := s.products.AddStock(ctx, line.ProductId, -line.Quantity); err != nil {
+				if errors.Is(err, products.ErrRepositoryInsufficientStock) {
+					return fmt.Errorf("%w: product %d", ErrServiceInsufficientStock, line.ProductId)
+				}
+				return err
+			}
+		}
+
+		invoice := &domain.Invoice{
+			CustomerId: checkout.CustomerId,
+			Datetime:   time.Now().Format(datetimeLayout),
+			Total:      math.Round(total*100) / 100,
+		}
+		invoiceId, err := s.invoices.Create(ctx, invoice)
+		if err != nil {
+			return err
+		}
+		invoice.Id = int(invoiceId)
+		if err := s.audit.Record(ctx, "invoices", invoice.Id, audit.ActionCreate, nil, invoice); err != nil {
+			return err
+		}
+
+		sales := make([]*domain.Sale, 0, len(checkout.Lines))
+		for _, line := range checkout.Lines {
+			sale := &domain.Sale{
+				ProductId:  line.ProductId,
+				InvoicesId: invoice.Id,
+				Quantity:   line.Quantity,
+			}
+			saleId, err := s.sales.Create(ctx, sale)
+			if err != nil {
+				return err
+			}
+			sale.Id = int(saleId)
+			if err := s.audit.Record(ctx, "sales", sale.Id, audit.ActionCreate, nil, sale); err != nil {
+				return err
+			}
+			sales = append(sales, sale)
+		}
+
+		result.Invoice = invoice
+		result.Sales = sales
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -3,31 +3,77 @@ package customers
 import (
 	"context"
 	"database/sql"
-	"strings"
+	"errors"
 
 	"desafio/internal/domain"
+	"desafio/pkg/database"
+	"desafio/pkg/listing"
 )
 
+var (
+	ErrRepositoryCustomerNotFound    = errors.New("customer not found")
+	ErrRepositoryCustomerHasInvoices = errors.New("customer has invoices")
+)
+
+// QuerySpec whitelists the filters and sort fields accepted by ReadAll.
+var QuerySpec = listing.Spec{
+	Filters: []listing.Filter{
+		{Param: "condition", Column: "customers.condition", Operator: listing.Equal, Kind: listing.Bool},
+		{Param: "first_name", Column: "first_name", Operator: listing.Contains, Kind: listing.String},
+		{Param: "last_name", Column: "last_name", Operator: listing.Contains, Kind: listing.String},
+	},
+	Sorts: map[string]string{
+		"id":         "id",
+		"first_name": "first_name",
+		"last_name":  "last_name",
+		"condition":  "customers.condition",
+	},
+	DefaultSort: "id",
+}
+
+// Column returns the value of a column of QuerySpec for the customer, as the
+// listing of the memory storage and the page cursors read it.
+func Column(c *domain.Customer, column string) any {
+	switch column {
+	case "first_name":
+		return c.FirstName
+	case "last_name":
+		return c.LastName
+	case "customers.condition":
+		return c.Condition
+	default:
+		return c.Id
+	}
+}
+
 type Repository interface {
 	Create(ctx context.Context, customers *domain.Customer) (int64, error)
-	ReadAll(ctx context.Context) ([]*domain.Customer, error)
-	CreateMany(ctx context.Context, customers []*domain.Customer) error
+	Read(ctx context.Context, id int) (*domain.Customer, error)
+	ReadAll(ctx context.Context, params listing.Params) ([]*domain.Customer, int, error)
+	Update(ctx context.Context, customer *domain.Customer) error
+	Delete(ctx context.Context, id int) error
+	CreateMany(ctx context.Context, next func() (*domain.Customer, error), opts database.BatchOptions) (database.Progress, error)
 	GetTotalsGroupedByCondition(ctx context.Context) ([]map[string]any, error)
 	GetActivesWhoSpentTheMost(ctx context.Context) ([]map[string]any, error)
 }
 
 type repository struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect database.Dialect
 }
 
 func NewRepository(db *sql.DB) Repository {
-	return &repository{db}
+	return &repository{db, database.MySQL}
+}
+
+func NewSQLiteRepository(db *sql.DB) Repository {
+	return &repository{db, database.SQLite}
 }
 
 func (r *repository) Create(ctx context.Context, customers *domain.Customer) (int64, error) {
-	query := `INSERT INTO customers (first_name, last_name, customers.condition) VALUES (?, ?, ?);`
+	query := "INSERT INTO customers (first_name, last_name, `condition`) VALUES (?, ?, ?);"
 
-	stmt, err := r.db.PrepareContext(ctx, query)
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
 	if err != nil {
 		return 0, err
 	}
@@ -46,58 +92,138 @@ func (r *repository) Create(ctx context.Context, customers *domain.Customer) (in
 	return id, nil
 }
 
-func (r *repository) ReadAll(ctx context.Context) ([]*domain.Customer, error) {
-	query := `SELECT id, first_name, last_name, customers.condition FROM customers;`
+func (r *repository) Read(ctx context.Context, id int) (*domain.Customer, error) {
+	query := `SELECT id, first_name, last_name, customers.condition FROM customers WHERE id = ?;`
 
-	stmt, err := r.db.PrepareContext(ctx, query)
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.QueryContext(ctx)
+	customer := domain.Customer{}
+	err = stmt.QueryRowContext(ctx, id).Scan(&customer.Id, &customer.FirstName, &customer.LastName, &customer.Condition)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRepositoryCustomerNotFound
+		}
 		return nil, err
 	}
-	defer rows.Close()
 
-	customers := make([]*domain.Customer, 0)
-	for rows.Next() {
-		customer := domain.Customer{}
-		err := rows.Scan(&customer.Id, &customer.FirstName, &customer.LastName, &customer.Condition)
-		if err != nil {
-			return nil, err
+	return &customer, nil
+}
+
+func (r *repository) Update(ctx context.Context, customer *domain.Customer) error {
+	query := "UPDATE customers SET first_name = ?, last_name = ?, `condition` = ? WHERE id = ?;"
+
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, customer.FirstName, customer.LastName, customer.Condition, customer.Id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// MySQL reports zero affected rows when nothing changed, so tell that
+	// apart from a missing row
+	if rowsAffected == 0 {
+		if _, err := r.Read(ctx, customer.Id); err != nil {
+			return err
 		}
-		customers = append(customers, &customer)
 	}
 
-	return customers, nil
+	return nil
 }
 
-func (r *repository) CreateMany(ctx context.Context, customers []*domain.Customer) error {
-	query := `INSERT INTO customers (id, first_name, last_name, customers.condition) VALUES`
-	values := []any{}
-	for _, customer := range customers {
-		query += " (?, ?, ?, ?),"
-		values = append(values, customer.Id, customer.FirstName, customer.LastName, customer.Condition)
-	}
-	query = strings.TrimSuffix(query, ",")
-	query += ";"
+func (r *repository) Delete(ctx context.Context, id int) error {
+	query := `DELETE FROM customers WHERE id = ?;`
 
-	stmt, err := r.db.PrepareContext(ctx, query)
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
-	_, err = stmt.ExecContext(ctx, values...)
+	result, err := stmt.ExecContext(ctx, id)
 	if err != nil {
+		if r.dialect.RowReferenced(err) {
+			return ErrRepositoryCustomerHasInvoices
+		}
 		return err
 	}
 
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRepositoryCustomerNotFound
+	}
+
 	return nil
 }
 
+func (r *repository) ReadAll(ctx context.Context, params listing.Params) ([]*domain.Customer, int, error) {
+	where, values := params.Where()
+
+	total := 0
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx, `SELECT COUNT(*) FROM customers`+where+";", values...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	where, values = params.Seek()
+	orderBy, paging := params.OrderBy()
+	query := `SELECT id, first_name, last_name, customers.condition FROM customers` + where + orderBy + ";"
+
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, append(values, paging...)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	customers := make([]*domain.Customer, 0)
+	for rows.Next() {
+		customer := domain.Customer{}
+		err := rows.Scan(&customer.Id, &customer.FirstName, &customer.LastName, &customer.Condition)
+		if err != nil {
+			return nil, 0, err
+		}
+		customers = append(customers, &customer)
+	}
+
+	return customers, total, nil
+}
+
+func (r *repository) CreateMany(ctx context.Context, next func() (*domain.Customer, error), opts database.BatchOptions) (database.Progress, error) {
+	table := database.Table{Name: "customers", Key: "id", Columns: []string{"id", "first_name", "last_name", "`condition`"}}
+	writer := database.NewBatchWriter(r.db, r.dialect, table, opts)
+
+	return writer.Write(ctx, func() ([]any, error) {
+		customer, err := next()
+		if err != nil {
+			return nil, err
+		}
+
+		return []any{customer.Id, customer.FirstName, customer.LastName, customer.Condition}, nil
+	})
+}
+
 func (r *repository) GetTotalsGroupedByCondition(ctx context.Context) ([]map[string]any, error) {
 	query := `
 			SELECT c.condition, ROUND(SUM(i.total), 2) AS total FROM customers c
@@ -105,7 +231,7 @@ func (r *repository) GetTotalsGroupedByCondition(ctx context.Context) ([]map[str
 			GROUP BY c.condition;
 	`
 
-	stmt, err := r.db.PrepareContext(ctx, query)
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -146,7 +272,7 @@ func (r *repository) GetActivesWhoSpentTheMost(ctx context.Context) ([]map[strin
 			LIMIT 5;
 	`
 
-	stmt, err := r.db.PrepareContext(ctx, query)
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -2,57 +2,172 @@ package customers
 
 import (
 	"context"
+	"desafio/internal/audit"
 	"desafio/internal/domain"
+	"desafio/pkg/apperr"
+	"desafio/pkg/database"
 	"desafio/pkg/filemanager"
+	"desafio/pkg/listing"
+	"desafio/pkg/validate"
+	"errors"
+	"io"
+)
+
+var (
+	ErrServiceCustomerNotFound    = apperr.New(apperr.NotFound, "CUSTOMER_NOT_FOUND", "customer not found")
+	ErrServiceInvalidCustomerID   = apperr.New(apperr.Invalid, "INVALID_CUSTOMER_ID", "invalid customer identifier")
+	ErrServiceCustomerHasInvoices = apperr.New(apperr.Conflict, "CUSTOMER_HAS_INVOICES", "customer has invoices")
+)
+
+// entity names the customers in the audit log.
+const entity = "customers"
+
+var rules = validate.New(
+	validate.Value("first_name", func(c *domain.Customer) string { return c.FirstName }, validate.NotBlank(), validate.MaxLength(45)),
+	validate.Value("last_name", func(c *domain.Customer) string { return c.LastName }, validate.NotBlank(), validate.MaxLength(45)),
 )
 
 type Service interface {
 	Create(ctx context.Context, customers *domain.Customer) error
-	ReadAll(ctx context.Context) ([]*domain.Customer, error)
-	CreateManyFromJSON(ctx context.Context) ([]*domain.Customer, error)
+	Read(ctx context.Context, id int) (*domain.Customer, error)
+	ReadAll(ctx context.Context, params listing.Params) (*listing.Page[*domain.Customer], error)
+	Update(ctx context.Context, customer *domain.Customer) error
+	Delete(ctx context.Context, id int) error
+	CreateManyFromJSON(ctx context.Context, src io.Reader, format filemanager.Format, opts database.BatchOptions) (*filemanager.Report, error)
 	GetTotalsGroupedByCondition(ctx context.Context) ([]map[string]any, error)
 	GetActivesWhoSpentTheMost(ctx context.Context) ([]map[string]any, error)
 }
 
 type service struct {
-	r Repository
+	tx    database.Transactor
+	r     Repository
+	audit audit.Recorder
 }
 
-func NewService(r Repository) Service {
-	return &service{r}
+func NewService(tx database.Transactor, r Repository, a audit.Recorder) Service {
+	return &service{tx, r, a}
 }
 
 func (s *service) Create(ctx context.Context, customer *domain.Customer) error {
-	insertedId, err := s.r.Create(ctx, customer)
-	if err != nil {
+	if err := rules.Validate(ctx, customer); err != nil {
 		return err
 	}
 
-	customer.Id = int(insertedId)
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		insertedId, err := s.r.Create(ctx, customer)
+		if err != nil {
+			return err
+		}
+
+		customer.Id = int(insertedId)
 
-	return nil
+		return s.audit.Record(ctx, entity, customer.Id, audit.ActionCreate, nil, customer)
+	})
 }
 
-func (s *service) ReadAll(ctx context.Context) ([]*domain.Customer, error) {
-	customers, err := s.r.ReadAll(ctx)
+func (s *service) Read(ctx context.Context, id int) (*domain.Customer, error) {
+	if id < 1 {
+		return nil, ErrServiceInvalidCustomerID
+	}
+
+	customer, err := s.r.Read(ctx, id)
 	if err != nil {
+		if errors.Is(err, ErrRepositoryCustomerNotFound) {
+			return nil, ErrServiceCustomerNotFound
+		}
 		return nil, err
 	}
 
-	return customers, nil
+	return customer, nil
 }
 
-func (s *service) CreateManyFromJSON(ctx context.Context) ([]*domain.Customer, error) {
-	customers := make([]*domain.Customer, 0)
-	if err := filemanager.LoadDataFromJSON("datos/customers.json", &customers); err != nil {
+func (s *service) ReadAll(ctx context.Context, params listing.Params) (*listing.Page[*domain.Customer], error) {
+	customers, total, err := s.r.ReadAll(ctx, params.WithLookahead())
+	if err != nil {
 		return nil, err
 	}
 
-	if err := s.r.CreateMany(ctx, customers); err != nil {
-		return nil, err
+	return listing.NewPage(customers, total, params, Column), nil
+}
+
+func (s *service) Update(ctx context.Context, customer *domain.Customer) error {
+	if customer.Id < 1 {
+		return ErrServiceInvalidCustomerID
+	}
+	if err := rules.Validate(ctx, customer); err != nil {
+		return err
+	}
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := s.r.Read(ctx, customer.Id)
+		if err != nil {
+			if errors.Is(err, ErrRepositoryCustomerNotFound) {
+				return ErrServiceCustomerNotFound
+			}
+			return err
+		}
+
+		if err := s.r.Update(ctx, customer); err != nil {
+			if errors.Is(err, ErrRepositoryCustomerNotFound) {
+				return ErrServiceCustomerNotFound
+			}
+			return err
+		}
+
+		return s.audit.Record(ctx, entity, customer.Id, audit.ActionUpdate, before, customer)
+	})
+}
+
+func (s *service) Delete(ctx context.Context, id int) error {
+	if id < 1 {
+		return ErrServiceInvalidCustomerID
+	}
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := s.r.Read(ctx, id)
+		if err != nil {
+			if errors.Is(err, ErrRepositoryCustomerNotFound) {
+				return ErrServiceCustomerNotFound
+			}
+			return err
+		}
+
+		if err := s.r.Delete(ctx, id); err != nil {
+			switch {
+			case errors.Is(err, ErrRepositoryCustomerNotFound):
+				return ErrServiceCustomerNotFound
+			case errors.Is(err, ErrRepositoryCustomerHasInvoices):
+				return ErrServiceCustomerHasInvoices
+			default:
+				return err
+			}
+		}
+
+		return s.audit.Record(ctx, entity, id, audit.ActionDelete, before, nil)
+	})
+}
+
+// CreateManyFromJSON streams every valid row of src into the repository and
+// reports the rows that were rejected along with the write progress.
+func (s *service) CreateManyFromJSON(ctx context.Context, src io.Reader, format filemanager.Format, opts database.BatchOptions) (*filemanager.Report, error) {
+	report := filemanager.NewReport()
+	next := filemanager.Stream(filemanager.NewDecoder[domain.Customer](src, format), rules.WithContext(ctx), report)
+
+	// every stored row the import overwrites is recorded with its own entry
+	opts.Overwritten = audit.Overwrites(s.audit, entity, s.r.Read)
+	progress, err := s.r.CreateMany(ctx, next, opts)
+	report.Progress = progress
+	// a per chunk import keeps the chunks written before a failure, while an
+	// atomic one rolls back and writes nothing, so only an import that left
+	// rows in the table is recorded
+	if progress.Written > 0 {
+		err = errors.Join(err, audit.RecordImport(ctx, s.audit, entity, report))
+	}
+	if err != nil {
+		return report, err
 	}
 
-	return customers, nil
+	return report, nil
 }
 
 func (s *service) GetTotalsGroupedByCondition(ctx context.Context) ([]map[string]any, error) {
@@ -0,0 +1,17 @@
+package domain
+
+import "encoding/json"
+
+// AuditEntry records one change of an entity: who made it, when, and the
+// entity before and after it. Before is null for creates and After for
+// deletes.
+type AuditEntry struct {
+	Id       int             `json:"id"`
+	Entity   string          `json:"entity"`
+	EntityId int             `json:"entity_id"`
+	Action   string          `json:"action"`
+	Actor    string          `json:"actor"`
+	At       string          `json:"at"`
+	Before   json.RawMessage `json:"before"`
+	After    json.RawMessage `json:"after"`
+}
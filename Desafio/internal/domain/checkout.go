@@ -0,0 +1,16 @@
+package domain
+
+type CheckoutLine struct {
+	ProductId int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+}
+
+type Checkout struct {
+	CustomerId int            `json:"customer_id"`
+	Lines      []CheckoutLine `json:"lines"`
+}
+
+type CheckoutResult struct {
+	Invoice *Invoice `json:"invoice"`
+	Sales   []*Sale  `json:"sales"`
+}
@@ -6,3 +6,24 @@ type Invoice struct {
 	CustomerId int     `json:"customer_id"`
 	Total      float64 `json:"total"`
 }
+
+// InvoiceTotalsFilter narrows the invoices whose totals are recomputed.
+// Zero values are ignored. DatetimeFrom is inclusive and DatetimeTo exclusive.
+type InvoiceTotalsFilter struct {
+	InvoiceId    int
+	DatetimeFrom string
+	DatetimeTo   string
+}
+
+type InvoiceTotalChange struct {
+	Id     int     `json:"id"`
+	Before float64 `json:"before"`
+	After  float64 `json:"after"`
+}
+
+type InvoiceTotalsReport struct {
+	Updated     int                   `json:"updated"`
+	TotalBefore float64               `json:"total_before"`
+	TotalAfter  float64               `json:"total_after"`
+	Changes     []*InvoiceTotalChange `json:"changes"`
+}
@@ -4,4 +4,5 @@ type Product struct {
 	Id          int     `json:"id"`
 	Description string  `json:"description"`
 	Price       float64 `json:"price"`
+	Stock       int     `json:"stock"`
 }
@@ -3,30 +3,79 @@ package invoices
 import (
 	"context"
 	"database/sql"
-	"strings"
+	"errors"
+	"math"
 
 	"desafio/internal/domain"
+	"desafio/pkg/database"
+	"desafio/pkg/listing"
 )
 
+var (
+	ErrRepositoryInvoiceNotFound = errors.New("invoice not found")
+	ErrRepositoryInvoiceHasSales = errors.New("invoice has sales")
+)
+
+// QuerySpec whitelists the filters and sort fields accepted by ReadAll.
+var QuerySpec = listing.Spec{
+	Filters: []listing.Filter{
+		{Param: "customer_id", Column: "customer_id", Operator: listing.Equal, Kind: listing.Int},
+		{Param: "datetime_from", Column: "datetime", Operator: listing.GreaterEqual, Kind: listing.Datetime},
+		{Param: "datetime_to", Column: "datetime", Operator: listing.LessEqual, Kind: listing.Datetime},
+		{Param: "total_min", Column: "total", Operator: listing.GreaterEqual, Kind: listing.Float},
+		{Param: "total_max", Column: "total", Operator: listing.LessEqual, Kind: listing.Float},
+	},
+	Sorts: map[string]string{
+		"id":          "id",
+		"datetime":    "datetime",
+		"customer_id": "customer_id",
+		"total":       "total",
+	},
+	DefaultSort: "id",
+}
+
+// Column returns the value of a column of QuerySpec for the invoice, as the
+// listing of the memory storage and the page cursors read it.
+func Column(i *domain.Invoice, column string) any {
+	switch column {
+	case "datetime":
+		return i.Datetime
+	case "customer_id":
+		return i.CustomerId
+	case "total":
+		return i.Total
+	default:
+		return i.Id
+	}
+}
+
 type Repository interface {
 	Create(ctx context.Context, invoices *domain.Invoice) (int64, error)
-	ReadAll(ctx context.Context) ([]*domain.Invoice, error)
-	CreateMany(ctx context.Context, invoices []*domain.Invoice) error
-	UpdateTotals(ctx context.Context) error
+	Read(ctx context.Context, id int) (*domain.Invoice, error)
+	ReadAll(ctx context.Context, params listing.Params) ([]*domain.Invoice, int, error)
+	Update(ctx context.Context, invoice *domain.Invoice) error
+	Delete(ctx context.Context, id int) error
+	CreateMany(ctx context.Context, next func() (*domain.Invoice, error), opts database.BatchOptions) (database.Progress, error)
+	UpdateTotals(ctx context.Context, filter domain.InvoiceTotalsFilter) ([]*domain.InvoiceTotalChange, error)
 }
 
 type repository struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect database.Dialect
 }
 
 func NewRepository(db *sql.DB) Repository {
-	return &repository{db}
+	return &repository{db, database.MySQL}
+}
+
+func NewSQLiteRepository(db *sql.DB) Repository {
+	return &repository{db, database.SQLite}
 }
 
 func (r *repository) Create(ctx context.Context, invoices *domain.Invoice) (int64, error) {
 	query := `INSERT INTO invoices (customer_id, datetime, total) VALUES (?, ?, ?);`
 
-	stmt, err := r.db.PrepareContext(ctx, query)
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
 	if err != nil {
 		return 0, err
 	}
@@ -45,19 +94,109 @@ func (r *repository) Create(ctx context.Context, invoices *domain.Invoice) (int6
 	return id, nil
 }
 
-func (r *repository) ReadAll(ctx context.Context) ([]*domain.Invoice, error) {
-	query := `SELECT id, customer_id, datetime, total FROM invoices;`
+func (r *repository) Read(ctx context.Context, id int) (*domain.Invoice, error) {
+	query := `SELECT id, customer_id, datetime, total FROM invoices WHERE id = ?;`
 
-	stmt, err := r.db.PrepareContext(ctx, query)
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.QueryContext(ctx)
+	invoice := domain.Invoice{}
+	err = stmt.QueryRowContext(ctx, id).Scan(&invoice.Id, &invoice.CustomerId, &invoice.Datetime, &invoice.Total)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRepositoryInvoiceNotFound
+		}
 		return nil, err
 	}
+
+	return &invoice, nil
+}
+
+func (r *repository) Update(ctx context.Context, invoice *domain.Invoice) error {
+	query := `UPDATE invoices SET customer_id = ?, datetime = ?, total = ? WHERE id = ?;`
+
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, invoice.CustomerId, invoice.Datetime, invoice.Total, invoice.Id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// MySQL reports zero affected rows when nothing changed, so tell that
+	// apart from a missing row
+	if rowsAffected == 0 {
+		if _, err := r.Read(ctx, invoice.Id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *repository) Delete(ctx context.Context, id int) error {
+	query := `DELETE FROM invoices WHERE id = ?;`
+
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, id)
+	if err != nil {
+		if r.dialect.RowReferenced(err) {
+			return ErrRepositoryInvoiceHasSales
+		}
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRepositoryInvoiceNotFound
+	}
+
+	return nil
+}
+
+func (r *repository) ReadAll(ctx context.Context, params listing.Params) ([]*domain.Invoice, int, error) {
+	where, values := params.Where()
+
+	total := 0
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx, `SELECT COUNT(*) FROM invoices`+where+";", values...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	where, values = params.Seek()
+	orderBy, paging := params.OrderBy()
+	query := `SELECT id, customer_id, datetime, total FROM invoices` + where + orderBy + ";"
+
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, append(values, paging...)...)
+	if err != nil {
+		return nil, 0, err
+	}
 	defer rows.Close()
 
 	invoices := make([]*domain.Invoice, 0)
@@ -65,55 +204,93 @@ func (r *repository) ReadAll(ctx context.Context) ([]*domain.Invoice, error) {
 		invoice := domain.Invoice{}
 		err := rows.Scan(&invoice.Id, &invoice.CustomerId, &invoice.Datetime, &invoice.Total)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		invoices = append(invoices, &invoice)
 	}
-	return invoices, nil
+
+	return invoices, total, nil
+}
+
+func (r *repository) CreateMany(ctx context.Context, next func() (*domain.Invoice, error), opts database.BatchOptions) (database.Progress, error) {
+	table := database.Table{Name: "invoices", Key: "id", Columns: []string{"id", "customer_id", "datetime", "total"}}
+	writer := database.NewBatchWriter(r.db, r.dialect, table, opts)
+
+	return writer.Write(ctx, func() ([]any, error) {
+		invoice, err := next()
+		if err != nil {
+			return nil, err
+		}
+
+		return []any{invoice.Id, invoice.CustomerId, invoice.Datetime, invoice.Total}, nil
+	})
 }
 
-func (r *repository) CreateMany(ctx context.Context, invoices []*domain.Invoice) error {
-	query := `INSERT INTO invoices (id, customer_id, datetime, total) VALUES`
+// UpdateTotals recomputes each matching invoice total as SUM(price * quantity)
+// over its sales lines and writes back only the totals that changed. It runs on
+// the transaction of ctx, the service opens it around the audit entries too.
+func (r *repository) UpdateTotals(ctx context.Context, filter domain.InvoiceTotalsFilter) ([]*domain.InvoiceTotalChange, error) {
+	query := `
+			SELECT i.id, COALESCE(i.total, 0), COALESCE(ROUND(SUM(p.price * s.quantity), 2), 0) AS computed
+			FROM invoices i
+			LEFT JOIN sales s ON i.id = s.invoice_id
+			LEFT JOIN products p ON s.product_id = p.id
+			WHERE 1 = 1`
 	values := []any{}
-	for _, invoice := range invoices {
-		query += " (?, ?, ?, ?),"
-		values = append(values, invoice.Id, invoice.CustomerId, invoice.Datetime, invoice.Total)
+	if filter.InvoiceId != 0 {
+		query += " AND i.id = ?"
+		values = append(values, filter.InvoiceId)
+	}
+	if filter.DatetimeFrom != "" {
+		query += " AND i.datetime >= ?"
+		values = append(values, filter.DatetimeFrom)
 	}
-	query = strings.TrimSuffix(query, ",")
-	query += ";"
+	if filter.DatetimeTo != "" {
+		query += " AND i.datetime < ?"
+		values = append(values, filter.DatetimeTo)
+	}
+	query += " GROUP BY i.id, i.total;"
 
-	stmt, err := r.db.PrepareContext(ctx, query)
+	conn := database.Conn(ctx, r.db)
+	stmt, err := conn.PrepareContext(ctx, query)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer stmt.Close()
 
-	_, err = stmt.ExecContext(ctx, values...)
+	rows, err := stmt.QueryContext(ctx, values...)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	return nil
-}
+	changes := make([]*domain.InvoiceTotalChange, 0)
+	for rows.Next() {
+		change := domain.InvoiceTotalChange{}
+		err := rows.Scan(&change.Id, &change.Before, &change.After)
+		if err != nil {
+			return nil, err
+		}
+		// totals are stored as FLOAT, so compare at cent precision
+		if math.Abs(change.Before-change.After) >= 0.005 {
+			changes = append(changes, &change)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-func (r *repository) UpdateTotals(ctx context.Context) error {
-	query := `
-				UPDATE invoices i
-				JOIN sales s ON i.id = s.invoice_id
-				JOIN products p ON s.product_id = p.id
-				SET i.total = p.price * s.quantity
-				WHERE i.total = 0;
-			`
-	stmt, err := r.db.PrepareContext(ctx, query)
+	update, err := conn.PrepareContext(ctx, `UPDATE invoices SET total = ? WHERE id = ?;`)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer stmt.Close()
+	defer update.Close()
 
-	_, err = stmt.ExecContext(ctx)
-	if err != nil {
-		return err
+	for _, change := range changes {
+		if _, err := update.ExecContext(ctx, change.After, change.Id); err != nil {
+			return nil, err
+		}
 	}
 
-	return nil
+	return changes, nil
 }
@@ -2,63 +2,259 @@ package invoices
 
 import (
 	"context"
+	"desafio/internal/audit"
+	"desafio/internal/customers"
 	"desafio/internal/domain"
+	"desafio/pkg/apperr"
+	"desafio/pkg/database"
 	"desafio/pkg/filemanager"
+	"desafio/pkg/listing"
+	"desafio/pkg/validate"
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+const (
+	dateLayout     = "2006-01-02"
+	datetimeLayout = "2006-01-02 15:04:05"
+
+	// entity names the invoices in the audit log
+	entity = "invoices"
+)
+
+var (
+	ErrServiceInvoiceNotFound  = apperr.New(apperr.NotFound, "INVOICE_NOT_FOUND", "invoice not found")
+	ErrServiceInvalidInvoiceID = apperr.New(apperr.Invalid, "INVALID_INVOICE_ID", "invalid invoice identifier")
+	ErrServiceInvoiceHasSales  = apperr.New(apperr.Conflict, "INVOICE_HAS_SALES", "invoice has sales")
+	ErrServiceInvalidDatetime  = apperr.New(apperr.Invalid, "INVALID_DATETIME", "invalid datetime, expected YYYY-MM-DD or YYYY-MM-DD HH:MM:SS")
+)
+
+// rules are the checks of an invoice on its own. Imports apply only these:
+// looking up the customer of every row would need a second connection while
+// the batch holds one, so the foreign key checks it when the row is written.
+var rules = validate.New(
+	validate.Value("datetime", func(i *domain.Invoice) string { return i.Datetime }, validate.Format("YYYY-MM-DD or YYYY-MM-DD HH:MM:SS", func(value string) error {
+		_, _, err := parseDatetime(value)
+		return err
+	})),
+	validate.Value("customer_id", func(i *domain.Invoice) int { return i.CustomerId }, validate.Min(1)),
+	validate.Value("total", func(i *domain.Invoice) float64 { return i.Total }, validate.Min(0.0)),
 )
 
 type Service interface {
 	Create(ctx context.Context, invoices *domain.Invoice) error
-	ReadAll(ctx context.Context) ([]*domain.Invoice, error)
-	CreateManyFromJSON(ctx context.Context) ([]*domain.Invoice, error)
-	UpdateTotals(ctx context.Context) error
+	Read(ctx context.Context, id int) (*domain.Invoice, error)
+	ReadAll(ctx context.Context, params listing.Params) (*listing.Page[*domain.Invoice], error)
+	Update(ctx context.Context, invoice *domain.Invoice) error
+	Delete(ctx context.Context, id int) error
+	CreateManyFromJSON(ctx context.Context, src io.Reader, format filemanager.Format, opts database.BatchOptions) (*filemanager.Report, error)
+	UpdateTotals(ctx context.Context, filter domain.InvoiceTotalsFilter) (*domain.InvoiceTotalsReport, error)
 }
 
 type service struct {
-	r Repository
+	tx        database.Transactor
+	r         Repository
+	validator validate.Validator[*domain.Invoice]
+	audit     audit.Recorder
 }
 
-func NewService(r Repository) Service {
-	return &service{r}
+func NewService(tx database.Transactor, r Repository, c customers.Repository, a audit.Recorder) Service {
+	validator := rules.With(
+		validate.Value("customer_id", func(i *domain.Invoice) int { return i.CustomerId }, validate.Exists(c.Read, customers.ErrRepositoryCustomerNotFound)),
+	)
+
+	return &service{tx, r, validator, a}
 }
 
 func (s *service) Create(ctx context.Context, invoices *domain.Invoice) error {
-	insertedId, err := s.r.Create(ctx, invoices)
-	if err != nil {
+	if err := s.validator.Validate(ctx, invoices); err != nil {
 		return err
 	}
 
-	invoices.Id = int(insertedId)
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		insertedId, err := s.r.Create(ctx, invoices)
+		if err != nil {
+			return err
+		}
 
-	return nil
+		invoices.Id = int(insertedId)
+
+		return s.audit.Record(ctx, entity, invoices.Id, audit.ActionCreate, nil, invoices)
+	})
 }
 
-func (s *service) ReadAll(ctx context.Context) ([]*domain.Invoice, error) {
-	invoices, err := s.r.ReadAll(ctx)
+func (s *service) Read(ctx context.Context, id int) (*domain.Invoice, error) {
+	if id < 1 {
+		return nil, ErrServiceInvalidInvoiceID
+	}
+
+	invoice, err := s.r.Read(ctx, id)
 	if err != nil {
+		if errors.Is(err, ErrRepositoryInvoiceNotFound) {
+			return nil, ErrServiceInvoiceNotFound
+		}
 		return nil, err
 	}
 
-	return invoices, nil
+	return invoice, nil
 }
 
-func (s *service) CreateManyFromJSON(ctx context.Context) ([]*domain.Invoice, error) {
-	invoices := make([]*domain.Invoice, 0)
-	if err := filemanager.LoadDataFromJSON("datos/invoices.json", &invoices); err != nil {
+func (s *service) ReadAll(ctx context.Context, params listing.Params) (*listing.Page[*domain.Invoice], error) {
+	invoices, total, err := s.r.ReadAll(ctx, params.WithLookahead())
+	if err != nil {
 		return nil, err
 	}
 
-	if err := s.r.CreateMany(ctx, invoices); err != nil {
-		return nil, err
+	return listing.NewPage(invoices, total, params, Column), nil
+}
+
+func (s *service) Update(ctx context.Context, invoice *domain.Invoice) error {
+	if invoice.Id < 1 {
+		return ErrServiceInvalidInvoiceID
 	}
+	if err := s.validator.Validate(ctx, invoice); err != nil {
+		return err
+	}
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := s.r.Read(ctx, invoice.Id)
+		if err != nil {
+			if errors.Is(err, ErrRepositoryInvoiceNotFound) {
+				return ErrServiceInvoiceNotFound
+			}
+			return err
+		}
 
-	return invoices, nil
+		if err := s.r.Update(ctx, invoice); err != nil {
+			if errors.Is(err, ErrRepositoryInvoiceNotFound) {
+				return ErrServiceInvoiceNotFound
+			}
+			return err
+		}
+
+		return s.audit.Record(ctx, entity, invoice.Id, audit.ActionUpdate, before, invoice)
+	})
 }
 
-func (s *service) UpdateTotals(ctx context.Context) error {
-	err := s.r.UpdateTotals(ctx)
+func (s *service) Delete(ctx context.Context, id int) error {
+	if id < 1 {
+		return ErrServiceInvalidInvoiceID
+	}
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := s.r.Read(ctx, id)
+		if err != nil {
+			if errors.Is(err, ErrRepositoryInvoiceNotFound) {
+				return ErrServiceInvoiceNotFound
+			}
+			return err
+		}
+
+		if err := s.r.Delete(ctx, id); err != nil {
+			switch {
+			case errors.Is(err, ErrRepositoryInvoiceNotFound):
+				return ErrServiceInvoiceNotFound
+			case errors.Is(err, ErrRepositoryInvoiceHasSales):
+				return ErrServiceInvoiceHasSales
+			default:
+				return err
+			}
+		}
+
+		return s.audit.Record(ctx, entity, id, audit.ActionDelete, before, nil)
+	})
+}
+
+// CreateManyFromJSON streams every valid row of src into the repository and
+// reports the rows that were rejected along with the write progress.
+func (s *service) CreateManyFromJSON(ctx context.Context, src io.Reader, format filemanager.Format, opts database.BatchOptions) (*filemanager.Report, error) {
+	report := filemanager.NewReport()
+	next := filemanager.Stream(filemanager.NewDecoder[domain.Invoice](src, format), rules.WithContext(ctx), report)
+
+	// every stored row the import overwrites is recorded with its own entry
+	opts.Overwritten = audit.Overwrites(s.audit, entity, s.r.Read)
+	progress, err := s.r.CreateMany(ctx, next, opts)
+	report.Progress = progress
+	// a per chunk import keeps the chunks written before a failure, while an
+	// atomic one rolls back and writes nothing, so only an import that left
+	// rows in the table is recorded
+	if progress.Written > 0 {
+		err = errors.Join(err, audit.RecordImport(ctx, s.audit, entity, report))
+	}
 	if err != nil {
-		return err
+		return report, err
+	}
+
+	return report, nil
+}
+
+func (s *service) UpdateTotals(ctx context.Context, filter domain.InvoiceTotalsFilter) (*domain.InvoiceTotalsReport, error) {
+	if filter.InvoiceId < 0 {
+		return nil, ErrServiceInvalidInvoiceID
+	}
+
+	if filter.DatetimeFrom != "" {
+		from, _, err := parseDatetime(filter.DatetimeFrom)
+		if err != nil {
+			return nil, err
+		}
+		filter.DatetimeFrom = from.Format(datetimeLayout)
+	}
+
+	if filter.DatetimeTo != "" {
+		to, dateOnly, err := parseDatetime(filter.DatetimeTo)
+		if err != nil {
+			return nil, err
+		}
+		// a bare date includes the whole day, a datetime is taken as given
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		} else {
+			to = to.Add(time.Second)
+		}
+		filter.DatetimeTo = to.Format(datetimeLayout)
+	}
+
+	var changes []*domain.InvoiceTotalChange
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if changes, err = s.r.UpdateTotals(ctx, filter); err != nil {
+			return err
+		}
+
+		for _, change := range changes {
+			before, after := map[string]float64{"total": change.Before}, map[string]float64{"total": change.After}
+			if err := s.audit.Record(ctx, entity, change.Id, audit.ActionUpdate, before, after); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &domain.InvoiceTotalsReport{Updated: len(changes), Changes: changes}
+	for _, change := range changes {
+		report.TotalBefore += change.Before
+		report.TotalAfter += change.After
+	}
+	report.TotalBefore = math.Round(report.TotalBefore*100) / 100
+	report.TotalAfter = math.Round(report.TotalAfter*100) / 100
+
+	return report, nil
+}
+
+func parseDatetime(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(datetimeLayout, value); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.Parse(dateLayout, value); err == nil {
+		return t, true, nil
 	}
 
-	return nil
+	return time.Time{}, false, ErrServiceInvalidDatetime
 }
@@ -3,36 +3,89 @@ package products
 import (
 	"context"
 	"database/sql"
-	"strings"
+	"errors"
 
 	"desafio/internal/domain"
+	"desafio/pkg/database"
+	"desafio/pkg/listing"
 )
 
+var (
+	ErrRepositoryProductNotFound   = errors.New("product not found")
+	ErrRepositoryInsufficientStock = errors.New("insufficient stock")
+	ErrRepositoryProductHasSales   = errors.New("product has sales")
+)
+
+// QuerySpec whitelists the filters and sort fields accepted by ReadAll.
+var QuerySpec = listing.Spec{
+	Filters: []listing.Filter{
+		{Param: "description", Column: "description", Operator: listing.Contains, Kind: listing.String},
+		{Param: "price_min", Column: "price", Operator: listing.GreaterEqual, Kind: listing.Float},
+		{Param: "price_max", Column: "price", Operator: listing.LessEqual, Kind: listing.Float},
+		{Param: "stock_max", Column: "stock", Operator: listing.LessEqual, Kind: listing.Int},
+	},
+	Sorts: map[string]string{
+		"id":          "id",
+		"description": "description",
+		"price":       "price",
+		"stock":       "stock",
+	},
+	DefaultSort: "id",
+}
+
+// Column returns the value of a column of QuerySpec for the product, as the
+// listing of the memory storage and the page cursors read it.
+func Column(p *domain.Product, column string) any {
+	switch column {
+	case "description":
+		return p.Description
+	case "price":
+		return p.Price
+	case "stock":
+		return p.Stock
+	default:
+		return p.Id
+	}
+}
+
 type Repository interface {
 	Create(ctx context.Context, product *domain.Product) (int64, error)
-	ReadAll(ctx context.Context) ([]*domain.Product, error)
-	CreateMany(ctx context.Context, products []*domain.Product) error
+	Read(ctx context.Context, id int) (*domain.Product, error)
+	ReadAll(ctx context.Context, params listing.Params) ([]*domain.Product, int, error)
+	Update(ctx context.Context, product *domain.Product) error
+	Delete(ctx context.Context, id int) error
+	CreateMany(ctx context.Context, next func() (*domain.Product, error), opts database.BatchOptions) (database.Progress, error)
 	GetQtySaledGroupedByDescription(ctx context.Context) ([]map[string]any, error)
+	// AddStock adds delta to the stock of the product in a single statement, so
+	// concurrent sales can't take the same units. It fails with
+	// ErrRepositoryInsufficientStock when the stock would drop below zero.
+	AddStock(ctx context.Context, id int, delta int) error
+	ReadLowStock(ctx context.Context, threshold int) ([]*domain.Product, error)
 }
 
 type repository struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect database.Dialect
 }
 
 func NewRepository(db *sql.DB) Repository {
-	return &repository{db}
+	return &repository{db, database.MySQL}
+}
+
+func NewSQLiteRepository(db *sql.DB) Repository {
+	return &repository{db, database.SQLite}
 }
 
 func (r *repository) Create(ctx context.Context, product *domain.Product) (int64, error) {
-	query := `INSERT INTO products (description, price) VALUES (?, ?);`
+	query := `INSERT INTO products (description, price, stock) VALUES (?, ?, ?);`
 
-	stmt, err := r.db.PrepareContext(ctx, query)
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
 	if err != nil {
 		return 0, err
 	}
 	defer stmt.Close()
 
-	result, err := stmt.ExecContext(ctx, &product.Description, &product.Price)
+	result, err := stmt.ExecContext(ctx, &product.Description, &product.Price, &product.Stock)
 	if err != nil {
 		return 0, err
 	}
@@ -45,58 +98,138 @@ func (r *repository) Create(ctx context.Context, product *domain.Product) (int64
 	return id, nil
 }
 
-func (r *repository) ReadAll(ctx context.Context) ([]*domain.Product, error) {
-	query := `SELECT id, description, price FROM products;`
+func (r *repository) Read(ctx context.Context, id int) (*domain.Product, error) {
+	query := `SELECT id, description, price, stock FROM products WHERE id = ?;`
 
-	stmt, err := r.db.PrepareContext(ctx, query)
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.QueryContext(ctx)
+	product := domain.Product{}
+	err = stmt.QueryRowContext(ctx, id).Scan(&product.Id, &product.Description, &product.Price, &product.Stock)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRepositoryProductNotFound
+		}
 		return nil, err
 	}
-	defer rows.Close()
 
-	products := make([]*domain.Product, 0)
-	for rows.Next() {
-		product := domain.Product{}
-		err := rows.Scan(&product.Id, &product.Description, &product.Price)
-		if err != nil {
-			return nil, err
+	return &product, nil
+}
+
+func (r *repository) Update(ctx context.Context, product *domain.Product) error {
+	query := `UPDATE products SET description = ?, price = ?, stock = ? WHERE id = ?;`
+
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, product.Description, product.Price, product.Stock, product.Id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// MySQL reports zero affected rows when nothing changed, so tell that
+	// apart from a missing row
+	if rowsAffected == 0 {
+		if _, err := r.Read(ctx, product.Id); err != nil {
+			return err
 		}
-		products = append(products, &product)
 	}
 
-	return products, nil
+	return nil
 }
 
-func (r *repository) CreateMany(ctx context.Context, products []*domain.Product) error {
-	query := `INSERT INTO products (id, description, price) VALUES`
-	values := []any{}
-	for _, product := range products {
-		query += " (?, ?, ?),"
-		values = append(values, product.Id, product.Description, product.Price)
-	}
-	query = strings.TrimSuffix(query, ",")
-	query += ";"
+func (r *repository) Delete(ctx context.Context, id int) error {
+	query := `DELETE FROM products WHERE id = ?;`
 
-	stmt, err := r.db.PrepareContext(ctx, query)
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
-	_, err = stmt.ExecContext(ctx, values...)
+	result, err := stmt.ExecContext(ctx, id)
 	if err != nil {
+		if r.dialect.RowReferenced(err) {
+			return ErrRepositoryProductHasSales
+		}
 		return err
 	}
 
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRepositoryProductNotFound
+	}
+
 	return nil
 }
 
+func (r *repository) ReadAll(ctx context.Context, params listing.Params) ([]*domain.Product, int, error) {
+	where, values := params.Where()
+
+	total := 0
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx, `SELECT COUNT(*) FROM products`+where+";", values...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	where, values = params.Seek()
+	orderBy, paging := params.OrderBy()
+	query := `SELECT id, description, price, stock FROM products` + where + orderBy + ";"
+
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, append(values, paging...)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	products := make([]*domain.Product, 0)
+	for rows.Next() {
+		product := domain.Product{}
+		err := rows.Scan(&product.Id, &product.Description, &product.Price, &product.Stock)
+		if err != nil {
+			return nil, 0, err
+		}
+		products = append(products, &product)
+	}
+
+	return products, total, nil
+}
+
+func (r *repository) CreateMany(ctx context.Context, next func() (*domain.Product, error), opts database.BatchOptions) (database.Progress, error) {
+	table := database.Table{Name: "products", Key: "id", Columns: []string{"id", "description", "price", "stock"}}
+	writer := database.NewBatchWriter(r.db, r.dialect, table, opts)
+
+	return writer.Write(ctx, func() ([]any, error) {
+		product, err := next()
+		if err != nil {
+			return nil, err
+		}
+
+		return []any{product.Id, product.Description, product.Price, product.Stock}, nil
+	})
+}
+
 func (r *repository) GetQtySaledGroupedByDescription(ctx context.Context) ([]map[string]any, error) {
 	query := `
 		SELECT p.description, SUM(s.quantity) AS total FROM products p
@@ -106,7 +239,7 @@ func (r *repository) GetQtySaledGroupedByDescription(ctx context.Context) ([]map
 		LIMIT 5;
 	`
 
-	stmt, err := r.db.PrepareContext(ctx, query)
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -136,3 +269,63 @@ func (r *repository) GetQtySaledGroupedByDescription(ctx context.Context) ([]map
 
 	return qtySaledGrouped, nil
 }
+
+func (r *repository) AddStock(ctx context.Context, id int, delta int) error {
+	query := `UPDATE products SET stock = stock + ? WHERE id = ? AND stock + ? >= 0;`
+
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, delta, id, delta)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// No row matched, either the product is missing or it lacks the units
+	if rowsAffected == 0 {
+		if _, err := r.Read(ctx, id); err != nil {
+			return err
+		}
+		if delta != 0 {
+			return ErrRepositoryInsufficientStock
+		}
+	}
+
+	return nil
+}
+
+func (r *repository) ReadLowStock(ctx context.Context, threshold int) ([]*domain.Product, error) {
+	query := `SELECT id, description, price, stock FROM products WHERE stock <= ? ORDER BY stock, id;`
+
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, threshold)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := make([]*domain.Product, 0)
+	for rows.Next() {
+		product := domain.Product{}
+		err := rows.Scan(&product.Id, &product.Description, &product.Price, &product.Stock)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, &product)
+	}
+
+	return products, nil
+}
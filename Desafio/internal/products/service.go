@@ -2,56 +2,178 @@ package products
 
 import (
 	"context"
+	"desafio/internal/audit"
 	"desafio/internal/domain"
+	"desafio/pkg/apperr"
+	"desafio/pkg/database"
 	"desafio/pkg/filemanager"
+	"desafio/pkg/listing"
+	"desafio/pkg/validate"
+	"errors"
+	"io"
+)
+
+var (
+	ErrServiceProductNotFound       = apperr.New(apperr.NotFound, "PRODUCT_NOT_FOUND", "product not found")
+	ErrServiceInvalidProductID      = apperr.New(apperr.Invalid, "INVALID_PRODUCT_ID", "invalid product identifier")
+	ErrServiceInvalidStockThreshold = apperr.New(apperr.Invalid, "INVALID_STOCK_THRESHOLD", "invalid stock threshold")
+	ErrServiceProductHasSales       = apperr.New(apperr.Conflict, "PRODUCT_HAS_SALES", "product has sales")
+)
+
+// entity names the products in the audit log.
+const entity = "products"
+
+// DefaultLowStockThreshold is the stock at or below which a product is
+// reported as low when the request names no threshold.
+const DefaultLowStockThreshold = 5
+
+var rules = validate.New(
+	validate.Value("description", func(p *domain.Product) string { return p.Description }, validate.NotBlank(), validate.MaxLength(100)),
+	validate.Value("price", func(p *domain.Product) float64 { return p.Price }, validate.Min(0.0)),
+	validate.Value("stock", func(p *domain.Product) int { return p.Stock }, validate.Min(0)),
 )
 
 type Service interface {
 	Create(ctx context.Context, product *domain.Product) error
-	ReadAll(ctx context.Context) ([]*domain.Product, error)
-	CreateManyFromJSON(ctx context.Context) ([]*domain.Product, error)
+	Read(ctx context.Context, id int) (*domain.Product, error)
+	ReadAll(ctx context.Context, params listing.Params) (*listing.Page[*domain.Product], error)
+	Update(ctx context.Context, product *domain.Product) error
+	Delete(ctx context.Context, id int) error
+	CreateManyFromJSON(ctx context.Context, src io.Reader, format filemanager.Format, opts database.BatchOptions) (*filemanager.Report, error)
 	GetQtySaledGroupedByDescription(ctx context.Context) ([]map[string]any, error)
+	GetLowStock(ctx context.Context, threshold int) ([]*domain.Product, error)
 }
 
 type service struct {
-	r Repository
+	tx    database.Transactor
+	r     Repository
+	audit audit.Recorder
 }
 
-func NewService(r Repository) Service {
-	return &service{r}
+func NewService(tx database.Transactor, r Repository, a audit.Recorder) Service {
+	return &service{tx, r, a}
 }
 
 func (s *service) Create(ctx context.Context, product *domain.Product) error {
-	id, err := s.r.Create(ctx, product)
-	if err != nil {
+	if err := rules.Validate(ctx, product); err != nil {
 		return err
 	}
 
-	product.Id = int(id)
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		id, err := s.r.Create(ctx, product)
+		if err != nil {
+			return err
+		}
+
+		product.Id = int(id)
 
-	return nil
+		return s.audit.Record(ctx, entity, product.Id, audit.ActionCreate, nil, product)
+	})
 }
 
-func (s *service) ReadAll(ctx context.Context) ([]*domain.Product, error) {
-	products, err := s.r.ReadAll(ctx)
+func (s *service) Read(ctx context.Context, id int) (*domain.Product, error) {
+	if id < 1 {
+		return nil, ErrServiceInvalidProductID
+	}
+
+	product, err := s.r.Read(ctx, id)
 	if err != nil {
+		if errors.Is(err, ErrRepositoryProductNotFound) {
+			return nil, ErrServiceProductNotFound
+		}
 		return nil, err
 	}
 
-	return products, nil
+	return product, nil
 }
 
-func (s *service) CreateManyFromJSON(ctx context.Context) ([]*domain.Product, error) {
-	products := make([]*domain.Product, 0)
-	if err := filemanager.LoadDataFromJSON("datos/products.json", &products); err != nil {
+func (s *service) ReadAll(ctx context.Context, params listing.Params) (*listing.Page[*domain.Product], error) {
+	products, total, err := s.r.ReadAll(ctx, params.WithLookahead())
+	if err != nil {
 		return nil, err
 	}
 
-	if err := s.r.CreateMany(ctx, products); err != nil {
-		return nil, err
+	return listing.NewPage(products, total, params, Column), nil
+}
+
+func (s *service) Update(ctx context.Context, product *domain.Product) error {
+	if product.Id < 1 {
+		return ErrServiceInvalidProductID
+	}
+	if err := rules.Validate(ctx, product); err != nil {
+		return err
 	}
 
-	return products, nil
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := s.r.Read(ctx, product.Id)
+		if err != nil {
+			if errors.Is(err, ErrRepositoryProductNotFound) {
+				return ErrServiceProductNotFound
+			}
+			return err
+		}
+
+		if err := s.r.Update(ctx, product); err != nil {
+			if errors.Is(err, ErrRepositoryProductNotFound) {
+				return ErrServiceProductNotFound
+			}
+			return err
+		}
+
+		return s.audit.Record(ctx, entity, product.Id, audit.ActionUpdate, before, product)
+	})
+}
+
+func (s *service) Delete(ctx context.Context, id int) error {
+	if id < 1 {
+		return ErrServiceInvalidProductID
+	}
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := s.r.Read(ctx, id)
+		if err != nil {
+			if errors.Is(err, ErrRepositoryProductNotFound) {
+				return ErrServiceProductNotFound
+			}
+			return err
+		}
+
+		if err := s.r.Delete(ctx, id); err != nil {
+			switch {
+			case errors.Is(err, ErrRepositoryProductNotFound):
+				return ErrServiceProductNotFound
+			case errors.Is(err, ErrRepositoryProductHasSales):
+				return ErrServiceProductHasSales
+			default:
+				return err
+			}
+		}
+
+		return s.audit.Record(ctx, entity, id, audit.ActionDelete, before, nil)
+	})
+}
+
+// CreateManyFromJSON streams every valid row of src into the repository and
+// reports the rows that were rejected along with the write progress.
+func (s *service) CreateManyFromJSON(ctx context.Context, src io.Reader, format filemanager.Format, opts database.BatchOptions) (*filemanager.Report, error) {
+	report := filemanager.NewReport()
+	next := filemanager.Stream(filemanager.NewDecoder[domain.Product](src, format), rules.WithContext(ctx), report)
+
+	// every stored row the import overwrites is recorded with its own entry
+	opts.Overwritten = audit.Overwrites(s.audit, entity, s.r.Read)
+	progress, err := s.r.CreateMany(ctx, next, opts)
+	report.Progress = progress
+	// a per chunk import keeps the chunks written before a failure, while an
+	// atomic one rolls back and writes nothing, so only an import that left
+	// rows in the table is recorded
+	if progress.Written > 0 {
+		err = errors.Join(err, audit.RecordImport(ctx, s.audit, entity, report))
+	}
+	if err != nil {
+		return report, err
+	}
+
+	return report, nil
 }
 
 func (s *service) GetQtySaledGroupedByDescription(ctx context.Context) ([]map[string]any, error) {
@@ -62,3 +184,16 @@ func (s *service) GetQtySaledGroupedByDescription(ctx context.Context) ([]map[st
 
 	return qtySaledGrouped, nil
 }
+
+func (s *service) GetLowStock(ctx context.Context, threshold int) ([]*domain.Product, error) {
+	if threshold < 0 {
+		return nil, ErrServiceInvalidStockThreshold
+	}
+
+	lowStock, err := s.r.ReadLowStock(ctx, threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	return lowStock, nil
+}
@@ -3,29 +3,75 @@ package sales
 import (
 	"context"
 	"database/sql"
-	"strings"
+	"errors"
 
 	"desafio/internal/domain"
+	"desafio/pkg/database"
+	"desafio/pkg/listing"
 )
 
+var (
+	ErrRepositorySaleNotFound = errors.New("sale not found")
+)
+
+// QuerySpec whitelists the filters and sort fields accepted by ReadAll.
+var QuerySpec = listing.Spec{
+	Filters: []listing.Filter{
+		{Param: "product_id", Column: "product_id", Operator: listing.Equal, Kind: listing.Int},
+		{Param: "invoice_id", Column: "invoice_id", Operator: listing.Equal, Kind: listing.Int},
+		{Param: "quantity_min", Column: "quantity", Operator: listing.GreaterEqual, Kind: listing.Int},
+		{Param: "quantity_max", Column: "quantity", Operator: listing.LessEqual, Kind: listing.Int},
+	},
+	Sorts: map[string]string{
+		"id":         "id",
+		"product_id": "product_id",
+		"invoice_id": "invoice_id",
+		"quantity":   "quantity",
+	},
+	DefaultSort: "id",
+}
+
+// Column returns the value of a column of QuerySpec for the sale, as the
+// listing of the memory storage and the page cursors read it.
+func Column(s *domain.Sale, column string) any {
+	switch column {
+	case "product_id":
+		return s.ProductId
+	case "invoice_id":
+		return s.InvoicesId
+	case "quantity":
+		return s.Quantity
+	default:
+		return s.Id
+	}
+}
+
 type Repository interface {
 	Create(ctx context.Context, sales *domain.Sale) (int64, error)
-	ReadAll(ctx context.Context) ([]*domain.Sale, error)
-	CreateMany(ctx context.Context, sales []*domain.Sale) error
+	Read(ctx context.Context, id int) (*domain.Sale, error)
+	ReadAll(ctx context.Context, params listing.Params) ([]*domain.Sale, int, error)
+	Update(ctx context.Context, sale *domain.Sale) error
+	Delete(ctx context.Context, id int) error
+	CreateMany(ctx context.Context, next func() (*domain.Sale, error), opts database.BatchOptions) (database.Progress, error)
 }
 
 type repository struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect database.Dialect
 }
 
 func NewRepository(db *sql.DB) Repository {
-	return &repository{db}
+	return &repository{db, database.MySQL}
+}
+
+func NewSQLiteRepository(db *sql.DB) Repository {
+	return &repository{db, database.SQLite}
 }
 
 func (r *repository) Create(ctx context.Context, sales *domain.Sale) (int64, error) {
 	query := `INSERT INTO sales (product_id, invoice_id, quantity) VALUES (?, ?, ?);`
 
-	stmt, err := r.db.PrepareContext(ctx, query)
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
 	if err != nil {
 		return 0, err
 	}
@@ -44,53 +90,131 @@ func (r *repository) Create(ctx context.Context, sales *domain.Sale) (int64, err
 	return id, nil
 }
 
-func (r *repository) ReadAll(ctx context.Context) ([]*domain.Sale, error) {
-	query := `SELECT id, product_id, invoice_id, quantity FROM sales`
+func (r *repository) Read(ctx context.Context, id int) (*domain.Sale, error) {
+	query := `SELECT id, product_id, invoice_id, quantity FROM sales WHERE id = ?;`
 
-	stmt, err := r.db.PrepareContext(ctx, query)
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.QueryContext(ctx)
+	sale := domain.Sale{}
+	err = stmt.QueryRowContext(ctx, id).Scan(&sale.Id, &sale.ProductId, &sale.InvoicesId, &sale.Quantity)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRepositorySaleNotFound
+		}
 		return nil, err
 	}
-	defer rows.Close()
 
-	sales := make([]*domain.Sale, 0)
-	for rows.Next() {
-		sale := domain.Sale{}
-		err := rows.Scan(&sale.Id, &sale.ProductId, &sale.InvoicesId, &sale.Quantity)
-		if err != nil {
-			return nil, err
+	return &sale, nil
+}
+
+func (r *repository) Update(ctx context.Context, sale *domain.Sale) error {
+	query := `UPDATE sales SET product_id = ?, invoice_id = ?, quantity = ? WHERE id = ?;`
+
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, sale.ProductId, sale.InvoicesId, sale.Quantity, sale.Id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// MySQL reports zero affected rows when nothing changed, so tell that
+	// apart from a missing row
+	if rowsAffected == 0 {
+		if _, err := r.Read(ctx, sale.Id); err != nil {
+			return err
 		}
-		sales = append(sales, &sale)
 	}
-	return sales, nil
+
+	return nil
 }
 
-func (r *repository) CreateMany(ctx context.Context, sales []*domain.Sale) error {
-	query := `INSERT INTO sales (id, product_id, invoice_id, quantity) VALUES`
-	values := []any{}
-	for _, sale := range sales {
-		query += " (?, ?, ?, ?),"
-		values = append(values, sale.Id, sale.ProductId, sale.InvoicesId, sale.Quantity)
-	}
-	query = strings.TrimSuffix(query, ",")
-	query += ";"
+func (r *repository) Delete(ctx context.Context, id int) error {
+	query := `DELETE FROM sales WHERE id = ?;`
 
-	stmt, err := r.db.PrepareContext(ctx, query)
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
-	_, err = stmt.ExecContext(ctx, values...)
+	result, err := stmt.ExecContext(ctx, id)
 	if err != nil {
 		return err
 	}
 
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRepositorySaleNotFound
+	}
+
 	return nil
 }
+
+func (r *repository) ReadAll(ctx context.Context, params listing.Params) ([]*domain.Sale, int, error) {
+	where, values := params.Where()
+
+	total := 0
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx, `SELECT COUNT(*) FROM sales`+where+";", values...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	where, values = params.Seek()
+	orderBy, paging := params.OrderBy()
+	query := `SELECT id, product_id, invoice_id, quantity FROM sales` + where + orderBy + ";"
+
+	stmt, err := database.Conn(ctx, r.db).PrepareContext(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, append(values, paging...)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	sales := make([]*domain.Sale, 0)
+	for rows.Next() {
+		sale := domain.Sale{}
+		err := rows.Scan(&sale.Id, &sale.ProductId, &sale.InvoicesId, &sale.Quantity)
+		if err != nil {
+			return nil, 0, err
+		}
+		sales = append(sales, &sale)
+	}
+
+	return sales, total, nil
+}
+
+func (r *repository) CreateMany(ctx context.Context, next func() (*domain.Sale, error), opts database.BatchOptions) (database.Progress, error) {
+	table := database.Table{Name: "sales", Key: "id", Columns: []string{"id", "product_id", "invoice_id", "quantity"}}
+	writer := database.NewBatchWriter(r.db, r.dialect, table, opts)
+
+	return writer.Write(ctx, func() ([]any, error) {
+		sale, err := next()
+		if err != nil {
+			return nil, err
+		}
+
+		return []any{sale.Id, sale.ProductId, sale.InvoicesId, sale.Quantity}, nil
+	})
+}
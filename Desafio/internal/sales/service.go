@@ -2,53 +2,225 @@ package sales
 
 import (
 	"context"
+	"desafio/internal/audit"
 	"desafio/internal/domain"
+	"desafio/internal/invoices"
+	"desafio/internal/products"
+	"desafio/pkg/apperr"
+	"desafio/pkg/database"
 	"desafio/pkg/filemanager"
+	"desafio/pkg/listing"
+	"desafio/pkg/validate"
+	"errors"
+	"io"
+)
+
+var (
+	ErrServiceSaleNotFound        = apperr.New(apperr.NotFound, "SALE_NOT_FOUND", "sale not found")
+	ErrServiceInvalidSaleID       = apperr.New(apperr.Invalid, "INVALID_SALE_ID", "invalid sale identifier")
+	ErrServiceSaleProductNotFound = apperr.New(apperr.NotFound, "PRODUCT_NOT_FOUND", "sale product not found")
+	ErrServiceInsufficientStock   = apperr.New(apperr.Conflict, "INSUFFICIENT_STOCK", "insufficient stock")
+)
+
+// entity names the sales in the audit log. The stock they move is not logged
+// apart, the sale entries record it.
+const entity = "sales"
+
+// rules are the checks of a sale on its own. Imports apply only these:
+// looking up the product and invoice of every row would need a second
+// connection while the batch holds one, so the foreign keys check them when
+// the row is written.
+var rules = validate.New(
+	validate.Value("product_id", func(s *domain.Sale) int { return s.ProductId }, validate.Min(1)),
+	validate.Value("invoice_id", func(s *domain.Sale) int { return s.InvoicesId }, validate.Min(1)),
+	validate.Value("quantity", func(s *domain.Sale) int { return s.Quantity }, validate.Min(1)),
 )
 
 type Service interface {
 	Create(ctx context.Context, sales *domain.Sale) error
-	ReadAll(ctx context.Context) ([]*domain.Sale, error)
-	CreateManyFromJSON(ctx context.Context) ([]*domain.Sale, error)
+	Read(ctx context.Context, id int) (*domain.Sale, error)
+	ReadAll(ctx context.Context, params listing.Params) (*listing.Page[*domain.Sale], error)
+	Update(ctx context.Context, sale *domain.Sale) error
+	Delete(ctx context.Context, id int) error
+	CreateManyFromJSON(ctx context.Context, src io.Reader, format filemanager.Format, opts database.BatchOptions) (*filemanager.Report, error)
 }
 
 type service struct {
-	r Repository
+	tx        database.Transactor
+	r         Repository
+	products  products.Repository
+	validator validate.Validator[*domain.Sale]
+	audit     audit.Recorder
 }
 
-func NewService(r Repository) Service {
-	return &service{r}
+func NewService(tx database.Transactor, r Repository, p products.Repository, i invoices.Repository, a audit.Recorder) Service {
+	validator := rules.With(
+		validate.Value("product_id", func(s *domain.Sale) int { return s.ProductId }, validate.Exists(p.Read, products.ErrRepositoryProductNotFound)),
+		validate.Value("invoice_id", func(s *domain.Sale) int { return s.InvoicesId }, validate.Exists(i.Read, invoices.ErrRepositoryInvoiceNotFound)),
+	)
+
+	return &service{tx, r, p, validator, a}
 }
 
+// Create takes the sold units from the product stock and inserts the sale in
+// the same transaction, so a sale is never stored without its stock.
 func (s *service) Create(ctx context.Context, sales *domain.Sale) error {
-	id, err := s.r.Create(ctx, sales)
-	if err != nil {
+	if err := s.validator.Validate(ctx, sales); err != nil {
 		return err
 	}
 
-	sales.Id = int(id)
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.products.AddStock(ctx, sales.ProductId, -sales.Quantity); err != nil {
+			return stockError(err)
+		}
 
-	return nil
+		id, err := s.r.Create(ctx, sales)
+		if err != nil {
+			return err
+		}
+
+		sales.Id = int(id)
+
+		return s.audit.Record(ctx, entity, sales.Id, audit.ActionCreate, nil, sales)
+	})
 }
 
-func (s *service) ReadAll(ctx context.Context) ([]*domain.Sale, error) {
-	sales, err := s.r.ReadAll(ctx)
+func (s *service) Read(ctx context.Context, id int) (*domain.Sale, error) {
+	if id < 1 {
+		return nil, ErrServiceInvalidSaleID
+	}
+
+	sale, err := s.r.Read(ctx, id)
 	if err != nil {
+		if errors.Is(err, ErrRepositorySaleNotFound) {
+			return nil, ErrServiceSaleNotFound
+		}
 		return nil, err
 	}
 
-	return sales, nil
+	return sale, nil
 }
 
-func (s *service) CreateManyFromJSON(ctx context.Context) ([]*domain.Sale, error) {
-	sales := make([]*domain.Sale, 0)
-	if err := filemanager.LoadDataFromJSON("datos/sales.json", &sales); err != nil {
+func (s *service) ReadAll(ctx context.Context, params listing.Params) (*listing.Page[*domain.Sale], error) {
+	sales, total, err := s.r.ReadAll(ctx, params.WithLookahead())
+	if err != nil {
 		return nil, err
 	}
 
-	if err := s.r.CreateMany(ctx, sales); err != nil {
-		return nil, err
+	return listing.NewPage(sales, total, params, Column), nil
+}
+
+// Update gives the units of the stored sale back and takes the new ones, in
+// one transaction so a sale moved to another product moves its stock too.
+func (s *service) Update(ctx context.Context, sale *domain.Sale) error {
+	if sale.Id < 1 {
+		return ErrServiceInvalidSaleID
+	}
+	if err := s.validator.Validate(ctx, sale); err != nil {
+		return err
 	}
 
-	return sales, nil
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		current, err := s.r.Read(ctx, sale.Id)
+		if err != nil {
+			if errors.Is(err, ErrRepositorySaleNotFound) {
+				return ErrServiceSaleNotFound
+			}
+			return err
+		}
+
+		if err := s.restoreStock(ctx, current); err != nil {
+			return err
+		}
+		if err := s.products.AddStock(ctx, sale.ProductId, -sale.Quantity); err != nil {
+			return stockError(err)
+		}
+
+		if err := s.r.Update(ctx, sale); err != nil {
+			if errors.Is(err, ErrRepositorySaleNotFound) {
+				return ErrServiceSaleNotFound
+			}
+			return err
+		}
+
+		return s.audit.Record(ctx, entity, sale.Id, audit.ActionUpdate, current, sale)
+	})
+}
+
+// Delete removes the sale and gives its units back to the product stock.
+func (s *service) Delete(ctx context.Context, id int) error {
+	if id < 1 {
+		return ErrServiceInvalidSaleID
+	}
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		sale, err := s.r.Read(ctx, id)
+		if err != nil {
+			if errors.Is(err, ErrRepositorySaleNotFound) {
+				return ErrServiceSaleNotFound
+			}
+			return err
+		}
+
+		if err := s.r.Delete(ctx, id); err != nil {
+			if errors.Is(err, ErrRepositorySaleNotFound) {
+				return ErrServiceSaleNotFound
+			}
+			return err
+		}
+
+		if err := s.restoreStock(ctx, sale); err != nil {
+			return err
+		}
+
+		return s.audit.Record(ctx, entity, id, audit.ActionDelete, sale, nil)
+	})
+}
+
+// restoreStock gives the units of sale back to its product. A product that is
+// gone has nothing to restore.
+func (s *service) restoreStock(ctx context.Context, sale *domain.Sale) error {
+	err := s.products.AddStock(ctx, sale.ProductId, sale.Quantity)
+	if err != nil && !errors.Is(err, products.ErrRepositoryProductNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// CreateManyFromJSON streams every valid row of src into the repository and
+// reports the rows that were rejected along with the write progress. Imported
+// sales are historical records and leave the product stock untouched.
+func (s *service) CreateManyFromJSON(ctx context.Context, src io.Reader, format filemanager.Format, opts database.BatchOptions) (*filemanager.Report, error) {
+	report := filemanager.NewReport()
+	next := filemanager.Stream(filemanager.NewDecoder[domain.Sale](src, format), rules.WithContext(ctx), report)
+
+	// every stored row the import overwrites is recorded with its own entry
+	opts.Overwritten = audit.Overwrites(s.audit, entity, s.r.Read)
+	progress, err := s.r.CreateMany(ctx, next, opts)
+	report.Progress = progress
+	// a per chunk import keeps the chunks written before a failure, while an
+	// atomic one rolls back and writes nothing, so only an import that left
+	// rows in the table is recorded
+	if progress.Written > 0 {
+		err = errors.Join(err, audit.RecordImport(ctx, s.audit, entity, report))
+	}
+	if err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+// stockError maps the errors of taking units from a product to the service
+// errors of the sale.
+func stockError(err error) error {
+	switch {
+	case errors.Is(err, products.ErrRepositoryProductNotFound):
+		return ErrServiceSaleProductNotFound
+	case errors.Is(err, products.ErrRepositoryInsufficientStock):
+		return ErrServiceInsufficientStock
+	default:
+		return err
+	}
 }
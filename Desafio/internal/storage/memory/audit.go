@@ -0,0 +1,37 @@
+package memory
+
+import (
+	"context"
+
+	"desafio/internal/audit"
+	"desafio/internal/domain"
+	"desafio/pkg/listing"
+)
+
+type auditRepository struct {
+	s *Store
+}
+
+func (s *Store) Audit() audit.Repository {
+	return &auditRepository{s}
+}
+
+func (r *auditRepository) Create(ctx context.Context, entry *domain.AuditEntry) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	row := *entry
+	row.Id = r.s.audit.lastID + 1
+	r.s.audit.insert(row.Id, row)
+
+	return int64(row.Id), nil
+}
+
+func (r *auditRepository) ReadAll(ctx context.Context, params listing.Params) ([]*domain.AuditEntry, int, error) {
+	r.s.mu.RLock()
+	rows := r.s.audit.all()
+	r.s.mu.RUnlock()
+
+	page, total := listing.Apply(rows, params, audit.Column)
+	return page, total, nil
+}
@@ -0,0 +1,168 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"desafio/pkg/database"
+)
+
+// createMany mirrors database.BatchWriter: rows are pulled in chunks, the
+// conflict policy is applied per chunk and Atomic mode rolls everything back
+// on the first failure.
+func createMany[T any](ctx context.Context, s *Store, t *table[T], key func(*T) *int, next func() (*T, error), opts database.BatchOptions) (database.Progress, error) {
+	if opts.Mode == "" {
+		opts.Mode = database.Atomic
+	}
+	if opts.Conflict == "" {
+		opts.Conflict = database.Fail
+	}
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = database.DefaultChunkSize
+	}
+
+	progress := database.Progress{}
+	write := func(ctx context.Context) error {
+		chunk := make([]*T, 0, opts.ChunkSize)
+		for {
+			row, err := next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			progress.Rows++
+			chunk = append(chunk, row)
+			if len(chunk) == opts.ChunkSize {
+				if err := flush(ctx, s, t, key, chunk, opts, &progress); err != nil {
+					return err
+				}
+				chunk = chunk[:0]
+			}
+		}
+
+		if len(chunk) > 0 {
+			return flush(ctx, s, t, key, chunk, opts, &progress)
+		}
+
+		return nil
+	}
+
+	if opts.Mode == database.PerChunk {
+		err := write(ctx)
+		return progress, err
+	}
+
+	if err := s.WithinTx(ctx, write); err != nil {
+		// the rollback undoes the chunks already written, so none of their
+		// rows count
+		progress.Failed = progress.Rows
+		progress.Written, progress.Inserted, progress.Updated, progress.Skipped = 0, 0, 0, 0
+		return progress, err
+	}
+
+	return progress, nil
+}
+
+func flush[T any](ctx context.Context, s *Store, t *table[T], key func(*T) *int, chunk []*T, opts database.BatchOptions, progress *database.Progress) error {
+	progress.Chunks++
+
+	var inserted, updated, skipped int
+	write := func() (err error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		inserted, updated, skipped, err = apply(t, key, chunk, opts.Conflict)
+		return err
+	}
+
+	var err error
+	if opts.Overwritten != nil && opts.Conflict == database.Overwrite {
+		// the hook reads the store, so it runs without the lock, inside a
+		// transaction that undoes the chunk when it fails
+		err = s.WithinTx(ctx, func(ctx context.Context) error {
+			s.mu.RLock()
+			keys := overwritten(t, key, chunk)
+			s.mu.RUnlock()
+
+			if len(keys) == 0 {
+				return write()
+			}
+			return opts.Overwritten(ctx, keys, write)
+		})
+	} else {
+		err = write()
+	}
+
+	if err != nil {
+		if opts.Mode == database.Atomic {
+			return err
+		}
+		progress.Failed += len(chunk)
+		progress.FailedChunks++
+		progress.Errors = append(progress.Errors, fmt.Sprintf("chunk %d: %s", progress.Chunks, err))
+		return nil
+	}
+
+	progress.Inserted += inserted
+	progress.Updated += updated
+	progress.Skipped += skipped
+	progress.Written += inserted + updated
+	return nil
+}
+
+// overwritten returns the keys of the stored rows chunk replaces, once each.
+func overwritten[T any](t *table[T], key func(*T) *int, chunk []*T) []any {
+	keys := make([]any, 0)
+	seen := make(map[int]bool, len(chunk))
+	for _, row := range chunk {
+		id := *key(row)
+		if _, exists := t.rows[id]; exists && id != 0 && !seen[id] {
+			keys = append(keys, id)
+		}
+		seen[id] = true
+	}
+
+	return keys
+}
+
+// apply writes chunk as a single statement would, so nothing is written when
+// the Fail policy meets a duplicate key.
+func apply[T any](t *table[T], key func(*T) *int, chunk []*T, conflict database.ConflictPolicy) (inserted, updated, skipped int, err error) {
+	seen := make(map[int]bool, len(chunk))
+	for _, row := range chunk {
+		id := *key(row)
+		if id == 0 {
+			continue
+		}
+		if _, exists := t.rows[id]; (exists || seen[id]) && conflict == database.Fail {
+			return 0, 0, 0, fmt.Errorf("duplicate entry '%d' for key 'PRIMARY'", id)
+		}
+		seen[id] = true
+	}
+
+	seen = make(map[int]bool, len(chunk))
+	for _, row := range chunk {
+		id := *key(row)
+		_, exists := t.rows[id]
+		duplicate := id != 0 && (exists || seen[id])
+		seen[id] = true
+
+		switch {
+		case duplicate && conflict == database.Skip:
+			skipped++
+			continue
+		case duplicate:
+			updated++
+		default:
+			inserted++
+		}
+		t.insert(id, *row)
+	}
+
+	return inserted, updated, skipped, nil
+}
@@ -0,0 +1,139 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"desafio/internal/customers"
+	"desafio/internal/domain"
+	"desafio/pkg/database"
+	"desafio/pkg/listing"
+)
+
+type customerRepository struct {
+	s *Store
+}
+
+func (s *Store) Customers() customers.Repository {
+	return &customerRepository{s}
+}
+
+func (r *customerRepository) Create(ctx context.Context, customer *domain.Customer) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	row := *customer
+	row.Id = r.s.customers.lastID + 1
+	r.s.customers.insert(row.Id, row)
+
+	return int64(row.Id), nil
+}
+
+func (r *customerRepository) Read(ctx context.Context, id int) (*domain.Customer, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	customer, ok := r.s.customers.rows[id]
+	if !ok {
+		return nil, customers.ErrRepositoryCustomerNotFound
+	}
+
+	return &customer, nil
+}
+
+func (r *customerRepository) ReadAll(ctx context.Context, params listing.Params) ([]*domain.Customer, int, error) {
+	r.s.mu.RLock()
+	rows := r.s.customers.all()
+	r.s.mu.RUnlock()
+
+	page, total := listing.Apply(rows, params, customers.Column)
+	return page, total, nil
+}
+
+func (r *customerRepository) Update(ctx context.Context, customer *domain.Customer) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.customers.rows[customer.Id]; !ok {
+		return customers.ErrRepositoryCustomerNotFound
+	}
+	r.s.customers.rows[customer.Id] = *customer
+
+	return nil
+}
+
+func (r *customerRepository) Delete(ctx context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.customers.rows[id]; !ok {
+		return customers.ErrRepositoryCustomerNotFound
+	}
+	// the invoices restrict the delete, as the foreign key does
+	for _, invoice := range r.s.invoices.rows {
+		if invoice.CustomerId == id {
+			return customers.ErrRepositoryCustomerHasInvoices
+		}
+	}
+	delete(r.s.customers.rows, id)
+
+	return nil
+}
+
+func (r *customerRepository) CreateMany(ctx context.Context, next func() (*domain.Customer, error), opts database.BatchOptions) (database.Progress, error) {
+	return createMany(ctx, r.s, &r.s.customers, func(c *domain.Customer) *int { return &c.Id }, next, opts)
+}
+
+func (r *customerRepository) GetTotalsGroupedByCondition(ctx context.Context) ([]map[string]any, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	totals := map[bool]float64{}
+	for _, invoice := range r.s.invoices.rows {
+		customer, ok := r.s.customers.rows[invoice.CustomerId]
+		if ok {
+			totals[customer.Condition] += invoice.Total
+		}
+	}
+
+	totalsGrouped := make([]map[string]any, 0)
+	for _, condition := range []bool{false, true} {
+		if total, ok := totals[condition]; ok {
+			totalsGrouped = append(totalsGrouped, map[string]any{"condition": condition, "total": round(total)})
+		}
+	}
+
+	return totalsGrouped, nil
+}
+
+func (r *customerRepository) GetActivesWhoSpentTheMost(ctx context.Context) ([]map[string]any, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	type name struct{ last, first string }
+	amounts := map[name]float64{}
+	for _, invoice := range r.s.invoices.rows {
+		customer, ok := r.s.customers.rows[invoice.CustomerId]
+		if ok && customer.Condition {
+			amounts[name{customer.LastName, customer.FirstName}] += invoice.Total
+		}
+	}
+
+	spentTheMost := make([]map[string]any, 0, len(amounts))
+	for n, amount := range amounts {
+		spentTheMost = append(spentTheMost, map[string]any{"last_name": n.last, "first_name": n.first, "amount": round(amount)})
+	}
+	sort.SliceStable(spentTheMost, func(i, j int) bool {
+		return spentTheMost[i]["amount"].(float64) > spentTheMost[j]["amount"].(float64)
+	})
+	if len(spentTheMost) > 5 {
+		spentTheMost = spentTheMost[:5]
+	}
+
+	return spentTheMost, nil
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
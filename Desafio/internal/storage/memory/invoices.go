@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"context"
+	"math"
+
+	"desafio/internal/domain"
+	"desafio/internal/invoices"
+	"desafio/pkg/database"
+	"desafio/pkg/listing"
+)
+
+type invoiceRepository struct {
+	s *Store
+}
+
+func (s *Store) Invoices() invoices.Repository {
+	return &invoiceRepository{s}
+}
+
+func (r *invoiceRepository) Create(ctx context.Context, invoice *domain.Invoice) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	row := *invoice
+	row.Id = r.s.invoices.lastID + 1
+	r.s.invoices.insert(row.Id, row)
+
+	return int64(row.Id), nil
+}
+
+func (r *invoiceRepository) Read(ctx context.Context, id int) (*domain.Invoice, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	invoice, ok := r.s.invoices.rows[id]
+	if !ok {
+		return nil, invoices.ErrRepositoryInvoiceNotFound
+	}
+
+	return &invoice, nil
+}
+
+func (r *invoiceRepository) ReadAll(ctx context.Context, params listing.Params) ([]*domain.Invoice, int, error) {
+	r.s.mu.RLock()
+	rows := r.s.invoices.all()
+	r.s.mu.RUnlock()
+
+	page, total := listing.Apply(rows, params, invoices.Column)
+	return page, total, nil
+}
+
+func (r *invoiceRepository) Update(ctx context.Context, invoice *domain.Invoice) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.invoices.rows[invoice.Id]; !ok {
+		return invoices.ErrRepositoryInvoiceNotFound
+	}
+	r.s.invoices.rows[invoice.Id] = *invoice
+
+	return nil
+}
+
+func (r *invoiceRepository) Delete(ctx context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.invoices.rows[id]; !ok {
+		return invoices.ErrRepositoryInvoiceNotFound
+	}
+	// the sales restrict the delete, as the foreign key does
+	for _, sale := range r.s.sales.rows {
+		if sale.InvoicesId == id {
+			return invoices.ErrRepositoryInvoiceHasSales
+		}
+	}
+	delete(r.s.invoices.rows, id)
+
+	return nil
+}
+
+func (r *invoiceRepository) CreateMany(ctx context.Context, next func() (*domain.Invoice, error), opts database.BatchOptions) (database.Progress, error) {
+	return createMany(ctx, r.s, &r.s.invoices, func(i *domain.Invoice) *int { return &i.Id }, next, opts)
+}
+
+func (r *invoiceRepository) UpdateTotals(ctx context.Context, filter domain.InvoiceTotalsFilter) ([]*domain.InvoiceTotalChange, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	computed := map[int]float64{}
+	for _, sale := range r.s.sales.rows {
+		if product, ok := r.s.products.rows[sale.ProductId]; ok {
+			computed[sale.InvoicesId] += product.Price * float64(sale.Quantity)
+		}
+	}
+
+	changes := make([]*domain.InvoiceTotalChange, 0)
+	for _, invoice := range r.s.invoices.all() {
+		if filter.InvoiceId != 0 && invoice.Id != filter.InvoiceId ||
+			filter.DatetimeFrom != "" && invoice.Datetime < filter.DatetimeFrom ||
+			filter.DatetimeTo != "" && invoice.Datetime >= filter.DatetimeTo {
+			continue
+		}
+
+		change := domain.InvoiceTotalChange{Id: invoice.Id, Before: invoice.Total, After: round(computed[invoice.Id])}
+		if math.Abs(change.Before-change.After) >= 0.005 {
+			invoice.Total = change.After
+			r.s.invoices.rows[invoice.Id] = *invoice
+			changes = append(changes, &change)
+		}
+	}
+
+	return changes, nil
+}
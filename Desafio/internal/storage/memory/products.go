@@ -0,0 +1,145 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"desafio/internal/domain"
+	"desafio/internal/products"
+	"desafio/pkg/database"
+	"desafio/pkg/listing"
+)
+
+type productRepository struct {
+	s *Store
+}
+
+func (s *Store) Products() products.Repository {
+	return &productRepository{s}
+}
+
+func (r *productRepository) Create(ctx context.Context, product *domain.Product) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	row := *product
+	row.Id = r.s.products.lastID + 1
+	r.s.products.insert(row.Id, row)
+
+	return int64(row.Id), nil
+}
+
+func (r *productRepository) Read(ctx context.Context, id int) (*domain.Product, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	product, ok := r.s.products.rows[id]
+	if !ok {
+		return nil, products.ErrRepositoryProductNotFound
+	}
+
+	return &product, nil
+}
+
+func (r *productRepository) ReadAll(ctx context.Context, params listing.Params) ([]*domain.Product, int, error) {
+	r.s.mu.RLock()
+	rows := r.s.products.all()
+	r.s.mu.RUnlock()
+
+	page, total := listing.Apply(rows, params, products.Column)
+	return page, total, nil
+}
+
+func (r *productRepository) Update(ctx context.Context, product *domain.Product) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.products.rows[product.Id]; !ok {
+		return products.ErrRepositoryProductNotFound
+	}
+	r.s.products.rows[product.Id] = *product
+
+	return nil
+}
+
+func (r *productRepository) Delete(ctx context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.products.rows[id]; !ok {
+		return products.ErrRepositoryProductNotFound
+	}
+	// the sales restrict the delete, as the foreign key does
+	for _, sale := range r.s.sales.rows {
+		if sale.ProductId == id {
+			return products.ErrRepositoryProductHasSales
+		}
+	}
+	delete(r.s.products.rows, id)
+
+	return nil
+}
+
+func (r *productRepository) CreateMany(ctx context.Context, next func() (*domain.Product, error), opts database.BatchOptions) (database.Progress, error) {
+	return createMany(ctx, r.s, &r.s.products, func(p *domain.Product) *int { return &p.Id }, next, opts)
+}
+
+func (r *productRepository) GetQtySaledGroupedByDescription(ctx context.Context) ([]map[string]any, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	totals := map[string]float64{}
+	for _, sale := range r.s.sales.rows {
+		if product, ok := r.s.products.rows[sale.ProductId]; ok {
+			totals[product.Description] += float64(sale.Quantity)
+		}
+	}
+
+	qtySaled := make([]map[string]any, 0, len(totals))
+	for description, total := range totals {
+		qtySaled = append(qtySaled, map[string]any{"description": description, "total": total})
+	}
+	sort.SliceStable(qtySaled, func(i, j int) bool {
+		return qtySaled[i]["total"].(float64) > qtySaled[j]["total"].(float64)
+	})
+	if len(qtySaled) > 5 {
+		qtySaled = qtySaled[:5]
+	}
+
+	return qtySaled, nil
+}
+
+func (r *productRepository) AddStock(ctx context.Context, id int, delta int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	product, ok := r.s.products.rows[id]
+	if !ok {
+		return products.ErrRepositoryProductNotFound
+	}
+	if product.Stock+delta < 0 {
+		return products.ErrRepositoryInsufficientStock
+	}
+	product.Stock += delta
+	r.s.products.rows[id] = product
+
+	return nil
+}
+
+func (r *productRepository) ReadLowStock(ctx context.Context, threshold int) ([]*domain.Product, error) {
+	r.s.mu.RLock()
+	rows := r.s.products.all()
+	r.s.mu.RUnlock()
+
+	low := make([]*domain.Product, 0)
+	for _, product := range rows {
+		if product.Stock <= threshold {
+			low = append(low, product)
+		}
+	}
+	sort.SliceStable(low, func(i, j int) bool {
+		return low[i].Stock < low[j].Stock
+	})
+
+	return low, nil
+}
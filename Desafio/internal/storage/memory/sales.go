@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"context"
+
+	"desafio/internal/domain"
+	"desafio/internal/sales"
+	"desafio/pkg/database"
+	"desafio/pkg/listing"
+)
+
+type saleRepository struct {
+	s *Store
+}
+
+func (s *Store) Sales() sales.Repository {
+	return &saleRepository{s}
+}
+
+func (r *saleRepository) Create(ctx context.Context, sale *domain.Sale) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	row := *sale
+	row.Id = r.s.sales.lastID + 1
+	r.s.sales.insert(row.Id, row)
+
+	return int64(row.Id), nil
+}
+
+func (r *saleRepository) Read(ctx context.Context, id int) (*domain.Sale, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	sale, ok := r.s.sales.rows[id]
+	if !ok {
+		return nil, sales.ErrRepositorySaleNotFound
+	}
+
+	return &sale, nil
+}
+
+func (r *saleRepository) ReadAll(ctx context.Context, params listing.Params) ([]*domain.Sale, int, error) {
+	r.s.mu.RLock()
+	rows := r.s.sales.all()
+	r.s.mu.RUnlock()
+
+	page, total := listing.Apply(rows, params, sales.Column)
+	return page, total, nil
+}
+
+func (r *saleRepository) Update(ctx context.Context, sale *domain.Sale) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.sales.rows[sale.Id]; !ok {
+		return sales.ErrRepositorySaleNotFound
+	}
+	r.s.sales.rows[sale.Id] = *sale
+
+	return nil
+}
+
+func (r *saleRepository) Delete(ctx context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.sales.rows[id]; !ok {
+		return sales.ErrRepositorySaleNotFound
+	}
+	delete(r.s.sales.rows, id)
+
+	return nil
+}
+
+func (r *saleRepository) CreateMany(ctx context.Context, next func() (*domain.Sale, error), opts database.BatchOptions) (database.Progress, error) {
+	return createMany(ctx, r.s, &r.s.sales, func(s *domain.Sale) *int { return &s.Id }, next, opts)
+}
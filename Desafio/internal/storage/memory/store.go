@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"desafio/internal/domain"
+)
+
+// Store keeps the Desafio tables and the audit log in memory. It is meant for
+// local runs and tests: data is lost on exit and foreign keys are not
+// enforced on insert, although deletes cascade like the SQL schema does.
+type Store struct {
+	mu sync.RWMutex
+	// tx serializes transactions, each of which can be rolled back to the
+	// snapshot taken when it began
+	tx sync.Mutex
+
+	customers table[domain.Customer]
+	invoices  table[domain.Invoice]
+	products  table[domain.Product]
+	sales     table[domain.Sale]
+	audit     table[domain.AuditEntry]
+}
+
+func New() *Store {
+	return &Store{
+		customers: newTable[domain.Customer](),
+		invoices:  newTable[domain.Invoice](),
+		products:  newTable[domain.Product](),
+		sales:     newTable[domain.Sale](),
+		audit:     newTable[domain.AuditEntry](),
+	}
+}
+
+type txKey struct{}
+
+// WithinTx runs fn and restores every table to its prior state when fn fails.
+// Nested calls join the outer transaction.
+func (s *Store) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(txKey{}) != nil {
+		return fn(ctx)
+	}
+
+	s.tx.Lock()
+	defer s.tx.Unlock()
+
+	s.mu.RLock()
+	customers, invoices, products, sales, audit := s.customers.clone(), s.invoices.clone(), s.products.clone(), s.sales.clone(), s.audit.clone()
+	s.mu.RUnlock()
+
+	if err := fn(context.WithValue(ctx, txKey{}, true)); err != nil {
+		s.mu.Lock()
+		s.customers, s.invoices, s.products, s.sales, s.audit = customers, invoices, products, sales, audit
+		s.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+type table[T any] struct {
+	rows   map[int]T
+	lastID int
+}
+
+func newTable[T any]() table[T] {
+	return table[T]{rows: map[int]T{}}
+}
+
+func (t *table[T]) clone() table[T] {
+	rows := make(map[int]T, len(t.rows))
+	for id, row := range t.rows {
+		rows[id] = row
+	}
+
+	return table[T]{rows, t.lastID}
+}
+
+// insert stores row under id, allocating the next id when it is zero like
+// AUTO_INCREMENT does.
+func (t *table[T]) insert(id int, row T) int {
+	if id == 0 {
+		id = t.lastID + 1
+	}
+	if id > t.lastID {
+		t.lastID = id
+	}
+	t.rows[id] = row
+
+	return id
+}
+
+// all returns copies of the rows ordered by id.
+func (t *table[T]) all() []*T {
+	ids := make([]int, 0, len(t.rows))
+	for id := range t.rows {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	rows := make([]*T, 0, len(ids))
+	for _, id := range ids {
+		row := t.rows[id]
+		rows = append(rows, &row)
+	}
+
+	return rows
+}
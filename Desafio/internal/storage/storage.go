@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "modernc.org/sqlite"
+
+	"desafio/internal/audit"
+	"desafio/internal/customers"
+	"desafio/internal/invoices"
+	"desafio/internal/products"
+	"desafio/internal/sales"
+	"desafio/internal/storage/memory"
+	"desafio/migrations"
+	"desafio/pkg/auth"
+	"desafio/pkg/database"
+)
+
+const (
+	MySQL  = "mysql"
+	SQLite = "sqlite"
+	Memory = "memory"
+)
+
+var (
+	ErrUnknownBackend = errors.New("unknown storage backend, expected mysql, sqlite or memory")
+)
+
+// Backend bundles the repositories of one storage engine so the router can
+// be wired without knowing which engine is behind them.
+type Backend struct {
+	Customers  customers.Repository
+	Invoices   invoices.Repository
+	Products   products.Repository
+	Sales      sales.Repository
+	Audit      audit.Repository
+	Transactor database.Transactor
+	// APIKeys is nil when the engine has no api_keys table
+	APIKeys auth.KeyStore
+
+	db *sql.DB
+}
+
+// Open connects to the backend named by driver and builds its repositories.
+func Open(driver, dataSource string) (*Backend, error) {
+	if driver == Memory {
+		return NewMemory(), nil
+	}
+
+	db, _, err := OpenDB(driver, dataSource)
+	if err != nil {
+		return nil, err
+	}
+
+	switch driver {
+	case SQLite:
+		return NewSQLite(db), nil
+	default:
+		return NewMySQL(db), nil
+	}
+}
+
+// OpenDB connects to the database named by driver and returns it with the
+// migrations written for its dialect.
+func OpenDB(driver, dataSource string) (*sql.DB, fs.FS, error) {
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	switch driver {
+	case MySQL:
+		db, err = sql.Open("mysql", dataSource)
+	case SQLite:
+		db, err = sql.Open("sqlite", sqliteDataSource(dataSource))
+		if err == nil {
+			// SQLite allows a single writer, serialize access instead of
+			// surfacing SQLITE_BUSY to the handlers
+			db.SetMaxOpenConns(1)
+		}
+	default:
+		return nil, nil, fmt.Errorf("%w: %q", ErrUnknownBackend, driver)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files, err := fs.Sub(migrations.Files, driver)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	return db, files, nil
+}
+
+func NewMySQL(db *sql.DB) *Backend {
+	return &Backend{
+		Customers:  customers.NewRepository(db),
+		Invoices:   invoices.NewRepository(db),
+		Products:   products.NewRepository(db),
+		Sales:      sales.NewRepository(db),
+		Audit:      audit.NewRepository(db),
+		Transactor: database.NewTransactor(db),
+		APIKeys:    auth.NewSQLKeyStore(db),
+		db:         db,
+	}
+}
+
+func NewSQLite(db *sql.DB) *Backend {
+	return &Backend{
+		Customers:  customers.NewSQLiteRepository(db),
+		Invoices:   invoices.NewSQLiteRepository(db),
+		Products:   products.NewSQLiteRepository(db),
+		Sales:      sales.NewSQLiteRepository(db),
+		Audit:      audit.NewRepository(db),
+		Transactor: database.NewTransactor(db),
+		APIKeys:    auth.NewSQLKeyStore(db),
+		db:         db,
+	}
+}
+
+// NewMemory builds a backend that keeps everything in process memory, for
+// local runs and tests. Nothing survives a restart.
+func NewMemory() *Backend {
+	store := memory.New()
+
+	return &Backend{
+		Customers:  store.Customers(),
+		Invoices:   store.Invoices(),
+		Products:   store.Products(),
+		Sales:      store.Sales(),
+		Audit:      store.Audit(),
+		Transactor: store,
+	}
+}
+
+func (b *Backend) Close() error {
+	if b.db == nil {
+		return nil
+	}
+
+	return b.db.Close()
+}
+
+// sqliteDataSource turns foreign keys on, since SQLite ignores the cascades
+// in the schema otherwise, and waits on locks instead of failing.
+func sqliteDataSource(dataSource string) string {
+	if strings.Contains(dataSource, "_pragma=") {
+		return dataSource
+	}
+
+	separator := "?"
+	if strings.Contains(dataSource, "?") {
+		separator = "&"
+	}
+
+	return dataSource + separator + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+}
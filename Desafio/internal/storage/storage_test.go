@@ -0,0 +1,80 @@
+package storage_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"desafio/internal/storage"
+	"desafio/internal/storage/storagetest"
+	"desafio/pkg/migrate"
+)
+
+func TestMemory(t *testing.T) {
+	storagetest.TestBackend(t, func(t *testing.T) *storage.Backend {
+		return storage.NewMemory()
+	})
+}
+
+func TestSQLite(t *testing.T) {
+	storagetest.TestBackend(t, func(t *testing.T) *storage.Backend {
+		db := open(t, storage.SQLite, filepath.Join(t.TempDir(), "test.db"))
+		return storage.NewSQLite(db)
+	})
+}
+
+// TestMySQL runs against the server of TEST_MYSQL_DATA_SOURCE, in a database
+// created for each test and dropped after it.
+func TestMySQL(t *testing.T) {
+	dataSource := os.Getenv("TEST_MYSQL_DATA_SOURCE")
+	if dataSource == "" {
+		t.Skip("TEST_MYSQL_DATA_SOURCE is not set")
+	}
+	cfg, err := mysql.ParseDSN(dataSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := sql.Open(storage.MySQL, dataSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	storagetest.TestBackend(t, func(t *testing.T) *storage.Backend {
+		name := fmt.Sprintf("contract_%d", time.Now().UnixNano())
+		if _, err := server.Exec("CREATE DATABASE " + name); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { server.Exec("DROP DATABASE " + name) })
+
+		cfg := cfg.Clone()
+		cfg.DBName = name
+		return storage.NewMySQL(open(t, storage.MySQL, cfg.FormatDSN()))
+	})
+}
+
+// open connects to dataSource and applies every migration.
+func open(t *testing.T, driver, dataSource string) *sql.DB {
+	db, files, err := storage.OpenDB(driver, dataSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrator, err := migrate.New(db, files)
+	if err != nil {
+		db.Close()
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		db.Close()
+		t.Fatal(err)
+	}
+
+	return db
+}
@@ -0,0 +1,693 @@
+// Package storagetest holds the behaviour every storage backend must share,
+// so the MySQL, SQLite and in-memory repositories can be checked against the
+// same expectations.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/url"
+	"testing"
+
+	"desafio/internal/audit"
+	"desafio/internal/customers"
+	"desafio/internal/domain"
+	"desafio/internal/invoices"
+	"desafio/internal/products"
+	"desafio/internal/sales"
+	"desafio/internal/storage"
+	"desafio/pkg/database"
+	"desafio/pkg/listing"
+)
+
+// TestBackend runs the contract against backends built by open, which must
+// return an empty, migrated backend on every call.
+func TestBackend(t *testing.T, open func(t *testing.T) *storage.Backend) {
+	tests := map[string]func(t *testing.T, b *storage.Backend){
+		"customers crud":            testCustomersCRUD,
+		"products crud":             testProductsCRUD,
+		"invoices and sales":        testInvoicesAndSales,
+		"read all":                  testReadAll,
+		"read all by cursor":        testReadAllByCursor,
+		"create many":               testCreateMany,
+		"overwritten":               testOverwritten,
+		"rollback":                  testRollback,
+		"restrict":                  testRestrict,
+		"update totals":             testUpdateTotals,
+		"update totals by date":     testUpdateTotalsByDate,
+		"update totals to the cent": testUpdateTotalsToTheCent,
+		"aggregates":                testAggregates,
+		"stock":                     testStock,
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			b := open(t)
+			t.Cleanup(func() { b.Close() })
+			test(t, b)
+		})
+	}
+}
+
+func testCustomersCRUD(t *testing.T, b *storage.Backend) {
+	ctx := context.Background()
+
+	customer := &domain.Customer{FirstName: "Ada", LastName: "Lovelace", Condition: true}
+	id, err := b.Customers.Create(ctx, customer)
+	if err != nil || id <= 0 {
+		t.Fatalf("create: id %d, err %v", id, err)
+	}
+	customer.Id = int(id)
+
+	read, err := b.Customers.Read(ctx, customer.Id)
+	if err != nil || *read != *customer {
+		t.Fatalf("read: got %+v, %v, want %+v", read, err, customer)
+	}
+
+	customer.Condition = false
+	if err := b.Customers.Update(ctx, customer); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	// updating with identical values must not report a missing row
+	if err := b.Customers.Update(ctx, customer); err != nil {
+		t.Fatalf("update unchanged: %v", err)
+	}
+	if read, _ := b.Customers.Read(ctx, customer.Id); read == nil || read.Condition {
+		t.Fatalf("update not stored: %+v", read)
+	}
+
+	if err := b.Customers.Delete(ctx, customer.Id); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := b.Customers.Read(ctx, customer.Id); !errors.Is(err, customers.ErrRepositoryCustomerNotFound) {
+		t.Fatalf("read deleted: got %v", err)
+	}
+	if err := b.Customers.Update(ctx, customer); !errors.Is(err, customers.ErrRepositoryCustomerNotFound) {
+		t.Fatalf("update deleted: got %v", err)
+	}
+	if err := b.Customers.Delete(ctx, customer.Id); !errors.Is(err, customers.ErrRepositoryCustomerNotFound) {
+		t.Fatalf("delete deleted: got %v", err)
+	}
+}
+
+func testProductsCRUD(t *testing.T, b *storage.Backend) {
+	ctx := context.Background()
+
+	product := &domain.Product{Description: "Tea", Price: 2.5, Stock: 4}
+	id, err := b.Products.Create(ctx, product)
+	if err != nil || id <= 0 {
+		t.Fatalf("create: id %d, err %v", id, err)
+	}
+	product.Id = int(id)
+
+	product.Price, product.Stock = 3.25, 7
+	if err := b.Products.Update(ctx, product); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	read, err := b.Products.Read(ctx, product.Id)
+	if err != nil || *read != *product {
+		t.Fatalf("read: got %+v, %v, want %+v", read, err, product)
+	}
+
+	if err := b.Products.Delete(ctx, product.Id); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := b.Products.Read(ctx, product.Id); !errors.Is(err, products.ErrRepositoryProductNotFound) {
+		t.Fatalf("read deleted: got %v", err)
+	}
+}
+
+func testInvoicesAndSales(t *testing.T, b *storage.Backend) {
+	ctx := context.Background()
+	customer, product := seedCustomer(t, b, true), seedProduct(t, b, "Tea", 2.5)
+
+	invoice := &domain.Invoice{CustomerId: customer, Datetime: "2021-03-01 10:00:00", Total: 5}
+	id, err := b.Invoices.Create(ctx, invoice)
+	if err != nil {
+		t.Fatalf("create invoice: %v", err)
+	}
+	invoice.Id = int(id)
+	if read, err := b.Invoices.Read(ctx, invoice.Id); err != nil || *read != *invoice {
+		t.Fatalf("read invoice: got %+v, %v, want %+v", read, err, invoice)
+	}
+
+	sale := &domain.Sale{ProductId: product, InvoicesId: invoice.Id, Quantity: 2}
+	id, err = b.Sales.Create(ctx, sale)
+	if err != nil {
+		t.Fatalf("create sale: %v", err)
+	}
+	sale.Id = int(id)
+
+	sale.Quantity = 4
+	if err := b.Sales.Update(ctx, sale); err != nil {
+		t.Fatalf("update sale: %v", err)
+	}
+	if read, err := b.Sales.Read(ctx, sale.Id); err != nil || *read != *sale {
+		t.Fatalf("read sale: got %+v, %v, want %+v", read, err, sale)
+	}
+
+	if err := b.Sales.Delete(ctx, sale.Id); err != nil {
+		t.Fatalf("delete sale: %v", err)
+	}
+	if _, err := b.Sales.Read(ctx, sale.Id); !errors.Is(err, sales.ErrRepositorySaleNotFound) {
+		t.Fatalf("read deleted sale: got %v", err)
+	}
+	if err := b.Invoices.Delete(ctx, invoice.Id); err != nil {
+		t.Fatalf("delete invoice: %v", err)
+	}
+	if _, err := b.Invoices.Read(ctx, invoice.Id); !errors.Is(err, invoices.ErrRepositoryInvoiceNotFound) {
+		t.Fatalf("read deleted invoice: got %v", err)
+	}
+}
+
+func testReadAll(t *testing.T, b *storage.Backend) {
+	ctx := context.Background()
+	for _, p := range []struct {
+		description string
+		price       float64
+	}{{"Green tea", 4}, {"Coffee", 6}, {"Black tea", 2}, {"Tea_cup", 5}} {
+		seedProduct(t, b, p.description, p.price)
+	}
+
+	params := parse(t, url.Values{"sort": {"price"}, "direction": {"desc"}, "limit": {"2"}})
+	items, total, err := b.Products.ReadAll(ctx, params)
+	if err != nil {
+		t.Fatalf("read all: %v", err)
+	}
+	if total != 4 || len(items) != 2 || items[0].Description != "Coffee" || items[1].Description != "Tea_cup" {
+		t.Fatalf("sorted page: got %d items of %d: %+v", len(items), total, items)
+	}
+
+	params = parse(t, url.Values{"description": {"TEA"}, "price_max": {"4"}})
+	items, total, err = b.Products.ReadAll(ctx, params)
+	if err != nil {
+		t.Fatalf("read all filtered: %v", err)
+	}
+	if total != 2 || len(items) != 2 || items[0].Description != "Green tea" || items[1].Description != "Black tea" {
+		t.Fatalf("filtered page: got %d items of %d: %+v", len(items), total, items)
+	}
+
+	// underscores are literal, not LIKE wildcards
+	params = parse(t, url.Values{"description": {"a_c"}})
+	if _, total, err = b.Products.ReadAll(ctx, params); err != nil || total != 1 {
+		t.Fatalf("escaped filter: got %d, %v", total, err)
+	}
+
+	params = parse(t, url.Values{"page": {"3"}, "size": {"2"}})
+	if items, total, err = b.Products.ReadAll(ctx, params); err != nil || total != 4 || len(items) != 0 {
+		t.Fatalf("page past the end: got %d items of %d, %v", len(items), total, err)
+	}
+}
+
+// testReadAllByCursor pages the products the way the services do, adding a
+// row ahead of the cursor between pages, which must not shift the next page.
+func testReadAllByCursor(t *testing.T, b *storage.Backend) {
+	ctx := context.Background()
+	for _, p := range []struct {
+		description string
+		price       float64
+	}{{"Green tea", 4}, {"Coffee", 6}, {"Black tea", 2}, {"Tea_cup", 4}} {
+		seedProduct(t, b, p.description, p.price)
+	}
+
+	read := func(values url.Values) *listing.Page[*domain.Product] {
+		t.Helper()
+		params := parse(t, values)
+		items, total, err := b.Products.ReadAll(ctx, params.WithLookahead())
+		if err != nil {
+			t.Fatalf("read all %v: %v", values, err)
+		}
+		return listing.NewPage(items, total, params, products.Column)
+	}
+	descriptions := func(page *listing.Page[*domain.Product]) []string {
+		names := make([]string, 0, len(page.Items))
+		for _, item := range page.Items {
+			names = append(names, item.Description)
+		}
+		return names
+	}
+
+	values := url.Values{"sort": {"price"}, "direction": {"desc"}, "limit": {"2"}}
+	page := read(values)
+	if got := descriptions(page); len(got) != 2 || got[0] != "Coffee" || got[1] != "Tea_cup" || page.NextCursor == "" {
+		t.Fatalf("first page: got %v, cursor %q", got, page.NextCursor)
+	}
+
+	seedProduct(t, b, "Espresso", 7)
+
+	values.Set("cursor", page.NextCursor)
+	page = read(values)
+	if got := descriptions(page); len(got) != 2 || got[0] != "Green tea" || got[1] != "Black tea" || page.Total != 5 {
+		t.Fatalf("page after the cursor: got %v of %d", got, page.Total)
+	}
+	if page.NextCursor != "" {
+		t.Fatalf("last page: got cursor %q", page.NextCursor)
+	}
+
+	values.Set("sort", "stock")
+	if _, err := listing.Parse(values, products.QuerySpec); !errors.Is(err, listing.ErrInvalidParameter) {
+		t.Fatalf("cursor of another sort: got %v", err)
+	}
+}
+
+func testCreateMany(t *testing.T, b *storage.Backend) {
+	ctx := context.Background()
+
+	progress, err := b.Products.CreateMany(ctx, rowsOf(
+		domain.Product{Id: 1, Description: "Tea", Price: 2},
+		domain.Product{Id: 2, Description: "Coffee", Price: 3},
+	), database.BatchOptions{})
+	if err != nil || progress.Inserted != 2 || progress.Written != 2 {
+		t.Fatalf("insert: got %+v, %v", progress, err)
+	}
+
+	progress, err = b.Products.CreateMany(ctx, rowsOf(
+		domain.Product{Id: 3, Description: "Milk", Price: 1},
+		domain.Product{Id: 1, Description: "Tea", Price: 9},
+	), database.BatchOptions{Conflict: database.Fail})
+	if err == nil || progress.Written != 0 {
+		t.Fatalf("fail policy: got %+v, %v", progress, err)
+	}
+	if _, err := b.Products.Read(ctx, 3); !errors.Is(err, products.ErrRepositoryProductNotFound) {
+		t.Fatalf("atomic batch left rows behind: %v", err)
+	}
+
+	progress, err = b.Products.CreateMany(ctx, rowsOf(
+		domain.Product{Id: 3, Description: "Milk", Price: 1},
+		domain.Product{Id: 1, Description: "Tea", Price: 9},
+	), database.BatchOptions{Conflict: database.Skip})
+	if err != nil || progress.Inserted != 1 || progress.Skipped != 1 {
+		t.Fatalf("skip policy: got %+v, %v", progress, err)
+	}
+	if p, _ := b.Products.Read(ctx, 1); p == nil || p.Price != 2 {
+		t.Fatalf("skip policy overwrote: %+v", p)
+	}
+
+	progress, err = b.Products.CreateMany(ctx, rowsOf(
+		domain.Product{Id: 4, Description: "Sugar", Price: 1},
+		domain.Product{Id: 1, Description: "Tea", Price: 9},
+	), database.BatchOptions{Conflict: database.Overwrite})
+	if err != nil || progress.Inserted != 1 || progress.Updated != 1 {
+		t.Fatalf("overwrite policy: got %+v, %v", progress, err)
+	}
+	if p, _ := b.Products.Read(ctx, 1); p == nil || p.Price != 9 {
+		t.Fatalf("overwrite policy kept: %+v", p)
+	}
+
+	// the first chunk is written before the second fails, and rolled back
+	progress, err = b.Products.CreateMany(ctx, rowsOf(
+		domain.Product{Id: 7, Description: "Flour", Price: 1},
+		domain.Product{Id: 8, Description: "Oil", Price: 4},
+		domain.Product{Id: 2, Description: "Coffee", Price: 3},
+	), database.BatchOptions{Mode: database.Atomic, ChunkSize: 2})
+	if err == nil || progress.Chunks != 2 || progress.Failed != 3 ||
+		progress.Written != 0 || progress.Inserted != 0 || progress.Updated != 0 || progress.Skipped != 0 {
+		t.Fatalf("atomic mode failing in the last chunk: got %+v, %v", progress, err)
+	}
+	if _, err := b.Products.Read(ctx, 7); !errors.Is(err, products.ErrRepositoryProductNotFound) {
+		t.Fatalf("atomic mode kept the first chunk: %v", err)
+	}
+
+	progress, err = b.Products.CreateMany(ctx, rowsOf(
+		domain.Product{Id: 5, Description: "Salt", Price: 1},
+		domain.Product{Id: 2, Description: "Coffee", Price: 3},
+		domain.Product{Id: 6, Description: "Rice", Price: 1},
+	), database.BatchOptions{Mode: database.PerChunk, ChunkSize: 2})
+	if err != nil || progress.Chunks != 2 || progress.FailedChunks != 1 || progress.Written != 1 {
+		t.Fatalf("per chunk mode: got %+v, %v", progress, err)
+	}
+	if _, err := b.Products.Read(ctx, 6); err != nil {
+		t.Fatalf("per chunk mode lost the second chunk: %v", err)
+	}
+}
+
+// testOverwritten checks that the Overwritten hook of an import sees the
+// stored rows it replaces before and after the write, and that a failing hook
+// undoes its chunk.
+func testOverwritten(t *testing.T, b *storage.Backend) {
+	ctx := context.Background()
+	seedProduct(t, b, "Tea", 2)
+
+	var keys []any
+	var before, after float64
+	opts := database.BatchOptions{Conflict: database.Overwrite, Overwritten: func(ctx context.Context, k []any, write func() error) error {
+		keys = k
+		p, err := b.Products.Read(ctx, 1)
+		if err != nil {
+			return err
+		}
+		before = p.Price
+		if err := write(); err != nil {
+			return err
+		}
+		if p, err = b.Products.Read(ctx, 1); err != nil {
+			return err
+		}
+		after = p.Price
+		return nil
+	}}
+	next := rowsOf(domain.Product{Id: 1, Description: "Tea", Price: 9}, domain.Product{Id: 1, Description: "Tea", Price: 9}, domain.Product{Id: 2, Description: "Milk", Price: 1})
+	if _, err := b.Products.CreateMany(ctx, next, opts); err != nil {
+		t.Fatalf("overwrite: %v", err)
+	}
+	if len(keys) != 1 || keys[0] != 1 || before != 2 || after != 9 {
+		t.Fatalf("hook: got keys %v, before %v, after %v", keys, before, after)
+	}
+
+	failure := errors.New("abort")
+	opts = database.BatchOptions{Mode: database.PerChunk, Conflict: database.Overwrite, Overwritten: func(ctx context.Context, k []any, write func() error) error {
+		if err := write(); err != nil {
+			return err
+		}
+		return failure
+	}}
+	progress, err := b.Products.CreateMany(ctx, rowsOf(domain.Product{Id: 1, Description: "Tea", Price: 5}, domain.Product{Id: 3, Description: "Salt", Price: 1}), opts)
+	if err != nil || progress.FailedChunks != 1 || progress.Written != 0 {
+		t.Fatalf("failing hook: got %+v, %v", progress, err)
+	}
+	if p, _ := b.Products.Read(ctx, 1); p == nil || p.Price != 9 {
+		t.Fatalf("failing hook kept the overwrite: %+v", p)
+	}
+	if _, err := b.Products.Read(ctx, 3); !errors.Is(err, products.ErrRepositoryProductNotFound) {
+		t.Fatalf("failing hook kept the chunk: %v", err)
+	}
+}
+
+// rowsOf streams products as a CreateMany source.
+func rowsOf(products ...domain.Product) func() (*domain.Product, error) {
+	return func() (*domain.Product, error) {
+		if len(products) == 0 {
+			return nil, io.EOF
+		}
+		p := products[0]
+		products = products[1:]
+		return &p, nil
+	}
+}
+
+func testRollback(t *testing.T, b *storage.Backend) {
+	ctx := context.Background()
+	failure := errors.New("abort")
+
+	var id int64
+	err := b.Transactor.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		id, err = b.Products.Create(ctx, &domain.Product{Description: "Tea", Price: 2})
+		if err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("within tx: got %v", err)
+	}
+	if _, err := b.Products.Read(ctx, int(id)); !errors.Is(err, products.ErrRepositoryProductNotFound) {
+		t.Fatalf("rolled back row still readable: %v", err)
+	}
+
+	err = b.Transactor.WithinTx(ctx, func(ctx context.Context) error {
+		id, err = b.Products.Create(ctx, &domain.Product{Description: "Coffee", Price: 3})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if _, err := b.Products.Read(ctx, int(id)); err != nil {
+		t.Fatalf("committed row: %v", err)
+	}
+}
+
+// testRestrict checks that the rows the sales history references cannot be
+// deleted, and that refusing leaves every row in place.
+func testRestrict(t *testing.T, b *storage.Backend) {
+	ctx := context.Background()
+	customer, product := seedCustomer(t, b, true), seedProduct(t, b, "Tea", 2)
+	invoice := seedInvoice(t, b, customer, "2021-03-01 10:00:00", 0)
+	sale := seedSale(t, b, product, invoice, 1)
+
+	if err := b.Customers.Delete(ctx, customer); !errors.Is(err, customers.ErrRepositoryCustomerHasInvoices) {
+		t.Fatalf("delete customer with invoices: got %v", err)
+	}
+	if err := b.Invoices.Delete(ctx, invoice); !errors.Is(err, invoices.ErrRepositoryInvoiceHasSales) {
+		t.Fatalf("delete invoice with sales: got %v", err)
+	}
+	if err := b.Products.Delete(ctx, product); !errors.Is(err, products.ErrRepositoryProductHasSales) {
+		t.Fatalf("delete product with sales: got %v", err)
+	}
+	if _, err := b.Sales.Read(ctx, sale); err != nil {
+		t.Fatalf("sale of the kept rows: %v", err)
+	}
+
+	// once the sale is gone its invoice, customer and product can go too
+	if err := b.Sales.Delete(ctx, sale); err != nil {
+		t.Fatalf("delete sale: %v", err)
+	}
+	if err := b.Invoices.Delete(ctx, invoice); err != nil {
+		t.Fatalf("delete invoice: %v", err)
+	}
+	if err := b.Customers.Delete(ctx, customer); err != nil {
+		t.Fatalf("delete customer: %v", err)
+	}
+	if err := b.Products.Delete(ctx, product); err != nil {
+		t.Fatalf("delete product: %v", err)
+	}
+}
+
+func testUpdateTotals(t *testing.T, b *storage.Backend) {
+	ctx := context.Background()
+	customer := seedCustomer(t, b, true)
+	tea, coffee := seedProduct(t, b, "Tea", 2.5), seedProduct(t, b, "Coffee", 4)
+
+	stale := seedInvoice(t, b, customer, "2021-03-01 10:00:00", 1)
+	seedSale(t, b, tea, stale, 2)
+	seedSale(t, b, coffee, stale, 1)
+	correct := seedInvoice(t, b, customer, "2021-03-02 10:00:00", 5)
+	seedSale(t, b, tea, correct, 2)
+	empty := seedInvoice(t, b, customer, "2021-04-01 10:00:00", 7)
+
+	changes, err := b.Invoices.UpdateTotals(ctx, domain.InvoiceTotalsFilter{DatetimeTo: "2021-04-01"})
+	if err != nil {
+		t.Fatalf("update totals: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Id != stale || changes[0].Before != 1 || changes[0].After != 9 {
+		t.Fatalf("changes: got %+v", changes)
+	}
+	if i, _ := b.Invoices.Read(ctx, empty); i == nil || i.Total != 7 {
+		t.Fatalf("invoice outside the range changed: %+v", i)
+	}
+
+	changes, err = b.Invoices.UpdateTotals(ctx, domain.InvoiceTotalsFilter{InvoiceId: empty})
+	if err != nil || len(changes) != 1 || changes[0].After != 0 {
+		t.Fatalf("invoice without sales: got %+v, %v", changes, err)
+	}
+}
+
+// testUpdateTotalsByDate goes through the service, which turns the range into
+// the bounds the repository takes: a bare to date covers its whole day and a
+// datetime one includes that second.
+func testUpdateTotalsByDate(t *testing.T, b *storage.Backend) {
+	ctx := context.Background()
+	service := invoices.NewService(b.Transactor, b.Invoices, b.Customers, audit.NewService(b.Audit))
+	customer, tea := seedCustomer(t, b, true), seedProduct(t, b, "Tea", 2.5)
+
+	ids := map[string]int{}
+	for _, datetime := range []string{
+		"2021-03-30 23:59:59",
+		"2021-03-31 00:00:00",
+		"2021-03-31 10:00:00",
+		"2021-03-31 10:00:01",
+		"2021-03-31 23:59:59",
+		"2021-04-01 00:00:00",
+	} {
+		ids[datetime] = seedInvoice(t, b, customer, datetime, 0)
+		seedSale(t, b, tea, ids[datetime], 2)
+	}
+	updated := func(filter domain.InvoiceTotalsFilter, want ...string) {
+		t.Helper()
+		report, err := service.UpdateTotals(ctx, filter)
+		if err != nil {
+			t.Fatalf("update totals %+v: %v", filter, err)
+		}
+		got := map[int]bool{}
+		for _, change := range report.Changes {
+			got[change.Id] = true
+		}
+		if report.Updated != len(want) || len(got) != len(want) {
+			t.Fatalf("update totals %+v: got %+v, want %v", filter, report.Changes, want)
+		}
+		for _, datetime := range want {
+			if !got[ids[datetime]] {
+				t.Fatalf("update totals %+v: invoice of %s not updated", filter, datetime)
+			}
+		}
+	}
+
+	updated(domain.InvoiceTotalsFilter{DatetimeFrom: "2021-03-31", DatetimeTo: "2021-03-31 10:00:00"},
+		"2021-03-31 00:00:00", "2021-03-31 10:00:00")
+	updated(domain.InvoiceTotalsFilter{DatetimeFrom: "2021-03-31", DatetimeTo: "2021-03-31"},
+		"2021-03-31 10:00:01", "2021-03-31 23:59:59")
+
+	// the invoices on either side of the day keep their totals
+	for _, datetime := range []string{"2021-03-30 23:59:59", "2021-04-01 00:00:00"} {
+		if i, _ := b.Invoices.Read(ctx, ids[datetime]); i == nil || i.Total != 0 {
+			t.Fatalf("invoice of %s outside the range changed: %+v", datetime, i)
+		}
+	}
+}
+
+// testUpdateTotalsToTheCent checks that a total differing from its sales by
+// less than half a cent is left alone, floating point noise included.
+func testUpdateTotalsToTheCent(t *testing.T, b *storage.Backend) {
+	ctx := context.Background()
+	customer, candy := seedCustomer(t, b, true), seedProduct(t, b, "Candy", 0.1)
+
+	// three candies add up to 0.30000000000000004 in floating point
+	exact := seedInvoice(t, b, customer, "2021-03-01 10:00:00", 0.3)
+	seedSale(t, b, candy, exact, 3)
+	within := seedInvoice(t, b, customer, "2021-03-01 11:00:00", 0.304)
+	seedSale(t, b, candy, within, 3)
+	off := seedInvoice(t, b, customer, "2021-03-01 12:00:00", 0.29)
+	seedSale(t, b, candy, off, 3)
+
+	changes, err := b.Invoices.UpdateTotals(ctx, domain.InvoiceTotalsFilter{})
+	if err != nil {
+		t.Fatalf("update totals: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Id != off || math.Abs(changes[0].After-0.3) > 1e-9 {
+		t.Fatalf("changes: got %+v", changes)
+	}
+}
+
+func testAggregates(t *testing.T, b *storage.Backend) {
+	ctx := context.Background()
+	active, inactive := seedCustomer(t, b, true), seedCustomer(t, b, false)
+	tea, coffee := seedProduct(t, b, "Tea", 2), seedProduct(t, b, "Coffee", 3)
+
+	first := seedInvoice(t, b, active, "2021-03-01 10:00:00", 10)
+	seedSale(t, b, tea, first, 5)
+	second := seedInvoice(t, b, inactive, "2021-03-02 10:00:00", 4.5)
+	seedSale(t, b, coffee, second, 1)
+	seedSale(t, b, tea, second, 2)
+
+	totals, err := b.Customers.GetTotalsGroupedByCondition(ctx)
+	if err != nil || len(totals) != 2 {
+		t.Fatalf("totals by condition: got %v, %v", totals, err)
+	}
+	for _, total := range totals {
+		want := 4.5
+		if condition, _ := total["condition"].(bool); condition {
+			want = 10
+		}
+		if total["total"] != want {
+			t.Fatalf("totals by condition: got %v", totals)
+		}
+	}
+
+	spent, err := b.Customers.GetActivesWhoSpentTheMost(ctx)
+	if err != nil || len(spent) != 1 || spent[0]["amount"] != 10.0 {
+		t.Fatalf("actives who spent the most: got %v, %v", spent, err)
+	}
+
+	qty, err := b.Products.GetQtySaledGroupedByDescription(ctx)
+	if err != nil || len(qty) != 2 || qty[0]["description"] != "Tea" || qty[0]["total"] != 7.0 {
+		t.Fatalf("quantity sold: got %v, %v", qty, err)
+	}
+}
+
+func parse(t *testing.T, values url.Values) listing.Params {
+	t.Helper()
+
+	params, err := listing.Parse(values, products.QuerySpec)
+	if err != nil {
+		t.Fatalf("parse %v: %v", values, err)
+	}
+
+	return params
+}
+
+func seedCustomer(t *testing.T, b *storage.Backend, condition bool) int {
+	t.Helper()
+
+	id, err := b.Customers.Create(context.Background(), &domain.Customer{FirstName: "Ada", LastName: "Lovelace", Condition: condition})
+	if err != nil {
+		t.Fatalf("seed customer: %v", err)
+	}
+
+	return int(id)
+}
+
+func testStock(t *testing.T, b *storage.Backend) {
+	ctx := context.Background()
+
+	ids := map[string]int{}
+	for _, p := range []struct {
+		description string
+		stock       int
+	}{{"Tea", 3}, {"Coffee", 20}, {"Mate", 0}} {
+		id, err := b.Products.Create(ctx, &domain.Product{Description: p.description, Price: 1, Stock: p.stock})
+		if err != nil {
+			t.Fatalf("create %s: %v", p.description, err)
+		}
+		ids[p.description] = int(id)
+	}
+
+	if err := b.Products.AddStock(ctx, ids["Tea"], -3); err != nil {
+		t.Fatalf("take all: %v", err)
+	}
+	if err := b.Products.AddStock(ctx, ids["Tea"], -1); !errors.Is(err, products.ErrRepositoryInsufficientStock) {
+		t.Fatalf("take from empty: got %v", err)
+	}
+	if err := b.Products.AddStock(ctx, ids["Mate"], 2); err != nil {
+		t.Fatalf("restock: %v", err)
+	}
+	if err := b.Products.AddStock(ctx, ids["Mate"], 0); err != nil {
+		t.Fatalf("add nothing: %v", err)
+	}
+	if err := b.Products.AddStock(ctx, ids["Mate"]+ids["Coffee"]+ids["Tea"], 1); !errors.Is(err, products.ErrRepositoryProductNotFound) {
+		t.Fatalf("missing: got %v", err)
+	}
+
+	low, err := b.Products.ReadLowStock(ctx, 5)
+	if err != nil || len(low) != 2 {
+		t.Fatalf("low stock: got %v, %v", low, err)
+	}
+	if low[0].Id != ids["Tea"] || low[0].Stock != 0 || low[1].Id != ids["Mate"] || low[1].Stock != 2 {
+		t.Fatalf("low stock: got %+v, %+v", low[0], low[1])
+	}
+}
+
+func seedProduct(t *testing.T, b *storage.Backend, description string, price float64) int {
+	t.Helper()
+
+	id, err := b.Products.Create(context.Background(), &domain.Product{Description: description, Price: price})
+	if err != nil {
+		t.Fatalf("seed product: %v", err)
+	}
+
+	return int(id)
+}
+
+func seedInvoice(t *testing.T, b *storage.Backend, customer int, datetime string, total float64) int {
+	t.Helper()
+
+	id, err := b.Invoices.Create(context.Background(), &domain.Invoice{CustomerId: customer, Datetime: datetime, Total: total})
+	if err != nil {
+		t.Fatalf("seed invoice: %v", err)
+	}
+
+	return int(id)
+}
+
+func seedSale(t *testing.T, b *storage.Backend, product, invoice, quantity int) int {
+	t.Helper()
+
+	id, err := b.Sales.Create(context.Background(), &domain.Sale{ProductId: product, InvoicesId: invoice, Quantity: quantity})
+	if err != nil {
+		t.Fatalf("seed sale: %v", err)
+	}
+
+	return int(id)
+}
@@ -0,0 +1,8 @@
+package migrations
+
+import "embed"
+
+// Files holds the versioned schema migrations, one directory per dialect.
+//
+//go:embed mysql/*.sql sqlite/*.sql
+var Files embed.FS
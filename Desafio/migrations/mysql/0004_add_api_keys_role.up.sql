@@ -0,0 +1 @@
+ALTER TABLE `api_keys` ADD COLUMN `role` varchar(16) NOT NULL DEFAULT 'reader';
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `audit_log` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `entity` varchar(32) NOT NULL,
+  `entity_id` int NOT NULL,
+  `action` varchar(16) NOT NULL,
+  `actor` varchar(100) NOT NULL,
+  `occurred_at` datetime NOT NULL,
+  `before_state` json DEFAULT NULL,
+  `after_state` json DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `audit_log_entity_idx` (`entity`, `entity_id`),
+  KEY `audit_log_actor_idx` (`actor`),
+  KEY `audit_log_occurred_at_idx` (`occurred_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
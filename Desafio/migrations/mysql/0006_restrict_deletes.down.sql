@@ -0,0 +1,6 @@
+ALTER TABLE `invoices` DROP FOREIGN KEY `fk_invoices_1`;
+ALTER TABLE `invoices` ADD CONSTRAINT `fk_invoices_1` FOREIGN KEY (`customer_id`) REFERENCES `customers` (`id`) ON DELETE CASCADE ON UPDATE CASCADE;
+ALTER TABLE `sales` DROP FOREIGN KEY `fk_sales_1`;
+ALTER TABLE `sales` ADD CONSTRAINT `fk_sales_1` FOREIGN KEY (`product_id`) REFERENCES `products` (`id`) ON DELETE CASCADE ON UPDATE CASCADE;
+ALTER TABLE `sales` DROP FOREIGN KEY `fk_sales_2`;
+ALTER TABLE `sales` ADD CONSTRAINT `fk_sales_2` FOREIGN KEY (`invoice_id`) REFERENCES `invoices` (`id`) ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,8 @@
+-- A customer with invoices, an invoice with sales or a product with sales can
+-- no longer be deleted, the cascades wiped the sales history with them
+ALTER TABLE `invoices` DROP FOREIGN KEY `fk_invoices_1`;
+ALTER TABLE `invoices` ADD CONSTRAINT `fk_invoices_1` FOREIGN KEY (`customer_id`) REFERENCES `customers` (`id`) ON DELETE RESTRICT ON UPDATE CASCADE;
+ALTER TABLE `sales` DROP FOREIGN KEY `fk_sales_1`;
+ALTER TABLE `sales` ADD CONSTRAINT `fk_sales_1` FOREIGN KEY (`product_id`) REFERENCES `products` (`id`) ON DELETE RESTRICT ON UPDATE CASCADE;
+ALTER TABLE `sales` DROP FOREIGN KEY `fk_sales_2`;
+ALTER TABLE `sales` ADD CONSTRAINT `fk_sales_2` FOREIGN KEY (`invoice_id`) REFERENCES `invoices` (`id`) ON DELETE RESTRICT ON UPDATE CASCADE;
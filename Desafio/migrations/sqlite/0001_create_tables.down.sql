@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS `sales`;
+DROP TABLE IF EXISTS `invoices`;
+DROP TABLE IF EXISTS `products`;
+DROP TABLE IF EXISTS `customers`;
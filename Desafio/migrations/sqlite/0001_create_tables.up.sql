@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS `customers` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `last_name` TEXT DEFAULT NULL,
+  `first_name` TEXT DEFAULT NULL,
+  `condition` INTEGER DEFAULT NULL
+);
+
+CREATE TABLE IF NOT EXISTS `products` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `description` TEXT DEFAULT NULL,
+  `price` REAL DEFAULT NULL
+);
+
+-- datetime is TEXT rather than DATETIME so the driver hands it back as the
+-- same "YYYY-MM-DD HH:MM:SS" string MySQL does
+CREATE TABLE IF NOT EXISTS `invoices` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `datetime` TEXT DEFAULT NULL,
+  `customer_id` INTEGER DEFAULT NULL REFERENCES `customers` (`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+  `total` REAL DEFAULT NULL
+);
+
+CREATE INDEX IF NOT EXISTS `fk_invoices_1_idx` ON `invoices` (`customer_id`);
+
+CREATE TABLE IF NOT EXISTS `sales` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `product_id` INTEGER DEFAULT NULL REFERENCES `products` (`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+  `invoice_id` INTEGER DEFAULT NULL REFERENCES `invoices` (`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+  `quantity` INTEGER DEFAULT NULL
+);
+
+CREATE INDEX IF NOT EXISTS `fk_sales_1_idx` ON `sales` (`product_id`);
+CREATE INDEX IF NOT EXISTS `fk_sales_2_idx` ON `sales` (`invoice_id`);
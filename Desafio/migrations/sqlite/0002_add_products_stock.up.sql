@@ -0,0 +1 @@
+ALTER TABLE `products` ADD COLUMN `stock` INTEGER NOT NULL DEFAULT 0;
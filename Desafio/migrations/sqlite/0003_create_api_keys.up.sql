@@ -0,0 +1,8 @@
+-- Only the SHA-256 of each key is kept, the key is shown once when it is created
+CREATE TABLE IF NOT EXISTS `api_keys` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `subject` TEXT NOT NULL,
+  `key_hash` TEXT NOT NULL UNIQUE,
+  `created_at` TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `revoked_at` TEXT DEFAULT NULL
+);
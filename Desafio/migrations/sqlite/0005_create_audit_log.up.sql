@@ -0,0 +1,16 @@
+-- occurred_at is TEXT like invoices.datetime, so it sorts and compares as
+-- "YYYY-MM-DD HH:MM:SS" on both engines
+CREATE TABLE IF NOT EXISTS `audit_log` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `entity` TEXT NOT NULL,
+  `entity_id` INTEGER NOT NULL,
+  `action` TEXT NOT NULL,
+  `actor` TEXT NOT NULL,
+  `occurred_at` TEXT NOT NULL,
+  `before_state` TEXT DEFAULT NULL,
+  `after_state` TEXT DEFAULT NULL
+);
+
+CREATE INDEX IF NOT EXISTS `audit_log_entity_idx` ON `audit_log` (`entity`, `entity_id`);
+CREATE INDEX IF NOT EXISTS `audit_log_actor_idx` ON `audit_log` (`actor`);
+CREATE INDEX IF NOT EXISTS `audit_log_occurred_at_idx` ON `audit_log` (`occurred_at`);
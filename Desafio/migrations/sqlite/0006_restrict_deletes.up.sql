@@ -0,0 +1,30 @@
+-- SQLite cannot alter a foreign key, so invoices and sales are rebuilt. The
+-- checks are off meanwhile, dropping a parent table would otherwise run its
+-- delete actions; the migrator runs on the single SQLite connection, so the
+-- pragma applies to every statement below
+PRAGMA foreign_keys = OFF;
+
+CREATE TABLE `invoices_new` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `datetime` TEXT DEFAULT NULL,
+  `customer_id` INTEGER DEFAULT NULL REFERENCES `customers` (`id`) ON DELETE RESTRICT ON UPDATE CASCADE,
+  `total` REAL DEFAULT NULL
+);
+INSERT INTO `invoices_new` (`id`, `datetime`, `customer_id`, `total`) SELECT `id`, `datetime`, `customer_id`, `total` FROM `invoices`;
+DROP TABLE `invoices`;
+ALTER TABLE `invoices_new` RENAME TO `invoices`;
+CREATE INDEX IF NOT EXISTS `fk_invoices_1_idx` ON `invoices` (`customer_id`);
+
+CREATE TABLE `sales_new` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `product_id` INTEGER DEFAULT NULL REFERENCES `products` (`id`) ON DELETE RESTRICT ON UPDATE CASCADE,
+  `invoice_id` INTEGER DEFAULT NULL REFERENCES `invoices` (`id`) ON DELETE RESTRICT ON UPDATE CASCADE,
+  `quantity` INTEGER DEFAULT NULL
+);
+INSERT INTO `sales_new` (`id`, `product_id`, `invoice_id`, `quantity`) SELECT `id`, `product_id`, `invoice_id`, `quantity` FROM `sales`;
+DROP TABLE `sales`;
+ALTER TABLE `sales_new` RENAME TO `sales`;
+CREATE INDEX IF NOT EXISTS `fk_sales_1_idx` ON `sales` (`product_id`);
+CREATE INDEX IF NOT EXISTS `fk_sales_2_idx` ON `sales` (`invoice_id`);
+
+PRAGMA foreign_keys = ON;
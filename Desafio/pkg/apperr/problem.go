@@ -0,0 +1,65 @@
+package apperr
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContentType is the media type of the RFC 7807 error responses.
+const ContentType = "application/problem+json"
+
+// Problem is an RFC 7807 error body. The code and the fields of the error are
+// extension members, and so is data, the partial result of a failed request.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+	Data     any          `json:"data,omitempty"`
+}
+
+// NewProblem describes err for the instance resource. The code already tells
+// problems apart, so the type is about:blank and the title the status text.
+func NewProblem(err error, instance string) Problem {
+	e := From(err)
+	status := e.Kind.Status()
+
+	problem := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   err.Error(),
+		Instance: instance,
+		Code:     e.Code,
+		Errors:   e.Fields,
+	}
+	// the detail of an internal error could expose the server
+	if e.Kind == Internal {
+		problem.Detail = e.Message
+	}
+	return problem
+}
+
+// Write writes err as application/problem+json. Internal errors are attached
+// to the context so the logger prints them.
+func Write(ctx *gin.Context, err error) {
+	WriteWithData(ctx, err, nil)
+}
+
+// WriteWithData writes err like Write, with data as the partial result of
+// the failed request.
+func WriteWithData(ctx *gin.Context, err error, data any) {
+	problem := NewProblem(err, ctx.Request.URL.Path)
+	problem.Data = data
+	if problem.Status >= http.StatusInternalServerError {
+		_ = ctx.Error(err)
+	}
+
+	// JSON keeps the Content-Type the response already has
+	ctx.Header("Content-Type", ContentType)
+	ctx.JSON(problem.Status, problem)
+}
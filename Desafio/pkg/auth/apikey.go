@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIKeyHeader is the request header that carries an API key.
+const APIKeyHeader = "X-API-Key"
+
+var ErrKeyNotFound = errors.New("api key not found")
+
+// KeyStore finds the principal that owns an API key by the hash of the key,
+// the keys themselves are never stored.
+type KeyStore interface {
+	LookupKey(ctx context.Context, hash string) (Principal, error)
+}
+
+// NewKey returns a random API key.
+func NewKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashKey returns the hex SHA-256 of key. The keys are random, so a slow
+// password hash would only make every request slower.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// StaticKeys are API keys from the configuration, by hash.
+type StaticKeys map[string]Principal
+
+// ParseKeys parses a comma separated list of subject:hash:role entries. The
+// role can be left out, the key is then a reader one.
+func ParseKeys(spec string) (StaticKeys, error) {
+	keys := StaticKeys{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, ":")
+		if len(parts) == 2 {
+			parts = append(parts, RoleReader)
+		}
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid api key %q, expected subject:sha256:role", entry)
+		}
+		subject, hash, role := parts[0], parts[1], parts[2]
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != sha256.Size*2 {
+			return nil, fmt.Errorf("invalid api key %q, the hash is not a hex sha256", entry)
+		}
+		if _, ok := Roles[role]; !ok {
+			return nil, fmt.Errorf("invalid api key %q, unknown role %q", entry, role)
+		}
+		keys[strings.ToLower(hash)] = Principal{Subject: subject, Method: MethodAPIKey, Roles: []string{role}}
+	}
+
+	return keys, nil
+}
+
+func (k StaticKeys) LookupKey(ctx context.Context, hash string) (Principal, error) {
+	p, ok := k[hash]
+	if !ok {
+		return Principal{}, ErrKeyNotFound
+	}
+
+	return p, nil
+}
+
+type sqlKeyStore struct {
+	db *sql.DB
+}
+
+// NewSQLKeyStore finds the keys in the api_keys table, skipping revoked ones.
+func NewSQLKeyStore(db *sql.DB) KeyStore {
+	return &sqlKeyStore{db}
+}
+
+func (s *sqlKeyStore) LookupKey(ctx context.Context, hash string) (Principal, error) {
+	var subject, role string
+	err := s.db.QueryRowContext(ctx, "SELECT subject, role FROM api_keys WHERE key_hash = ? AND revoked_at IS NULL", hash).Scan(&subject, &role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Principal{}, ErrKeyNotFound
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+
+	return Principal{Subject: subject, Method: MethodAPIKey, Roles: []string{role}}, nil
+}
+
+type apiKeyAuthenticator struct {
+	stores []KeyStore
+}
+
+// NewAPIKeyAuthenticator authenticates the key in the X-API-Key header
+// against each store in turn.
+func NewAPIKeyAuthenticator(stores ...KeyStore) Authenticator {
+	return &apiKeyAuthenticator{stores}
+}
+
+func (a *apiKeyAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		return Principal{}, ErrNoCredentials
+	}
+
+	hash := HashKey(key)
+	for _, store := range a.stores {
+		p, err := store.LookupKey(r.Context(), hash)
+		if errors.Is(err, ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return Principal{}, err
+		}
+		return p, nil
+	}
+
+	return Principal{}, ErrInvalidCredentials.Wrap(ErrKeyNotFound)
+}
@@ -0,0 +1,20 @@
+package auth
+
+import "time"
+
+// NewJWTAuthenticatorAt is NewJWTAuthenticator with its clock stopped at now.
+func NewJWTAuthenticatorAt(cfg JWTConfig, now time.Time) (Authenticator, error) {
+	a, err := NewJWTAuthenticator(cfg)
+	if err != nil {
+		return nil, err
+	}
+	a.(*jwtAuthenticator).now = func() time.Time { return now }
+	return a, nil
+}
+
+// Verify checks token with the JWT authenticator a and returns why it is
+// refused, nil when it is not.
+func Verify(a Authenticator, token string) error {
+	_, err := a.(*jwtAuthenticator).verify(token)
+	return err
+}
@@ -0,0 +1,223 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"desafio/pkg/auth"
+)
+
+var (
+	// now is the time the authenticators of the tests live in.
+	now    = time.Date(2025, time.January, 1, 12, 0, 0, 0, time.UTC)
+	secret = []byte("0123456789abcdef0123456789abcdef")
+)
+
+// claims returns valid claims for the configuration of newHS256, with the
+// changes applied: a nil value removes the claim.
+func claims(changes map[string]any) map[string]any {
+	c := map[string]any{
+		"sub":   "ana",
+		"iss":   "gostorage",
+		"aud":   "api",
+		"roles": []string{auth.RoleAdmin},
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+	for name, value := range changes {
+		if value == nil {
+			delete(c, name)
+			continue
+		}
+		c[name] = value
+	}
+	return c
+}
+
+// sign returns a token with the claims, its header saying alg and signed
+// with key: a []byte secret for HS256 or an *rsa.PrivateKey for RS256.
+func sign(t *testing.T, alg string, key any, c map[string]any) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var signature []byte
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(input))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(input))
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// newHS256 returns an HS256 authenticator expecting the gostorage issuer and
+// the api audience, with 30 seconds of leeway.
+func newHS256(t *testing.T) auth.Authenticator {
+	t.Helper()
+	a, err := auth.NewJWTAuthenticatorAt(auth.JWTConfig{
+		Algorithm: auth.HS256,
+		Key:       secret,
+		Issuer:    "gostorage",
+		Audience:  "api",
+		Leeway:    30 * time.Second,
+	}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name  string
+		token func(t *testing.T) string
+		// wantErr is the reason of the refusal, empty when the token is valid.
+		wantErr string
+	}{
+		{"valid", hs256(claims(nil)), ""},
+		{"signed with another secret", func(t *testing.T) string {
+			return sign(t, auth.HS256, []byte("another secret of thirty-two bytes"), claims(nil))
+		}, "invalid token signature"},
+		{"claims changed after signing", func(t *testing.T) string {
+			header, _, signature := cut(sign(t, auth.HS256, secret, claims(nil)))
+			_, payload, _ := cut(sign(t, auth.HS256, secret, claims(map[string]any{"sub": "root"})))
+			return header + "." + payload + "." + signature
+		}, "invalid token signature"},
+		{"unsigned", func(t *testing.T) string {
+			token := sign(t, "none", secret, claims(nil))
+			header, payload, _ := cut(token)
+			return header + "." + payload + "."
+		}, `unexpected algorithm "none"`},
+		{"two segments", func(t *testing.T) string { return "a.b" }, "malformed token"},
+		{"header that is not JSON", func(t *testing.T) string { return "bm90IGpzb24.e30.c2ln" }, "malformed token"},
+
+		{"no subject", hs256(claims(map[string]any{"sub": nil})), "token has no subject"},
+		{"no expiration", hs256(claims(map[string]any{"exp": nil})), "token has no expiration"},
+		{"expired past the leeway", hs256(claims(map[string]any{"exp": now.Add(-31 * time.Second).Unix()})), "token is expired"},
+		{"expired within the leeway", hs256(claims(map[string]any{"exp": now.Add(-30 * time.Second).Unix()})), ""},
+		{"expiring with a fraction", hs256(claims(map[string]any{"exp": float64(now.Unix()) + 0.5})), ""},
+		{"not valid yet past the leeway", hs256(claims(map[string]any{"nbf": now.Add(31 * time.Second).Unix()})), "token is not valid yet"},
+		{"not valid yet within the leeway", hs256(claims(map[string]any{"nbf": now.Add(30 * time.Second).Unix()})), ""},
+		{"valid since before", hs256(claims(map[string]any{"nbf": now.Add(-time.Hour).Unix()})), ""},
+
+		{"another issuer", hs256(claims(map[string]any{"iss": "elsewhere"})), "unexpected token issuer"},
+		{"no issuer", hs256(claims(map[string]any{"iss": nil})), "unexpected token issuer"},
+		{"audience in a list", hs256(claims(map[string]any{"aud": []string{"web", "api"}})), ""},
+		{"another audience", hs256(claims(map[string]any{"aud": "web"})), "unexpected token audience"},
+		{"list without the audience", hs256(claims(map[string]any{"aud": []string{"web", "mobile"}})), "unexpected token audience"},
+		{"no audience", hs256(claims(map[string]any{"aud": nil})), "unexpected token audience"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := auth.Verify(newHS256(t), tt.token(t))
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("got %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyRS256(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := auth.NewJWTAuthenticatorAt(auth.JWTConfig{Algorithm: auth.RS256, Key: &private.PublicKey}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The public key is no secret, an HS256 token signed with it must not pass.
+	public, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public})
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{"valid", sign(t, auth.RS256, private, claims(nil)), ""},
+		{"signed with another key", sign(t, auth.RS256, other, claims(nil)), "invalid token signature"},
+		{"HS256 signed with the public key", sign(t, auth.HS256, pemKey, claims(nil)), `unexpected algorithm "HS256"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := auth.Verify(a, tt.token)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("got %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// And an RS256 token does not pass an HS256 authenticator.
+	if err := auth.Verify(newHS256(t), sign(t, auth.RS256, private, claims(nil))); err == nil || err.Error() != `unexpected algorithm "RS256"` {
+		t.Fatalf("RS256 token on HS256: got %v", err)
+	}
+}
+
+func TestJWTAuthenticate(t *testing.T) {
+	a := newHS256(t)
+
+	request := httptest.NewRequest("GET", "/", nil)
+	if _, err := a.Authenticate(request); !errors.Is(err, auth.ErrNoCredentials) {
+		t.Fatalf("without a token: got %v", err)
+	}
+
+	request.Header.Set("Authorization", "bearer "+sign(t, auth.HS256, secret, claims(map[string]any{"roles": auth.RoleClerk})))
+	p, err := a.Authenticate(request)
+	want := auth.Principal{Subject: "ana", Method: auth.MethodJWT, Roles: []string{auth.RoleClerk}}
+	if err != nil || !reflect.DeepEqual(p, want) {
+		t.Fatalf("got %+v, %v, want %+v", p, err, want)
+	}
+
+	request.Header.Set("Authorization", "Bearer "+sign(t, auth.HS256, secret, claims(map[string]any{"sub": nil})))
+	if _, err := a.Authenticate(request); !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Fatalf("with an invalid token: got %v", err)
+	}
+}
+
+// hs256 returns a token with the claims signed with the secret of newHS256.
+func hs256(c map[string]any) func(t *testing.T) string {
+	return func(t *testing.T) string {
+		return sign(t, auth.HS256, secret, c)
+	}
+}
+
+// cut splits a token into its three segments.
+func cut(token string) (string, string, string) {
+	header, rest, _ := strings.Cut(token, ".")
+	payload, signature, _ := strings.Cut(rest, ".")
+	return header, payload, signature
+}
@@ -0,0 +1,369 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"desafio/pkg/apperr"
+)
+
+const (
+	DefaultChunkSize = 500
+	// maxPlaceholders is the MySQL limit on placeholders per statement.
+	maxPlaceholders = 65535
+)
+
+type BatchMode string
+
+const (
+	// Atomic writes every chunk inside one transaction, all or nothing.
+	Atomic BatchMode = "atomic"
+	// PerChunk commits each chunk on its own. A failing chunk is counted and
+	// skipped and the remaining chunks are still written.
+	PerChunk BatchMode = "chunk"
+)
+
+// ConflictPolicy decides what happens to a row whose key already exists.
+type ConflictPolicy string
+
+const (
+	// Fail lets the duplicate key error abort the chunk.
+	Fail ConflictPolicy = "fail"
+	// Skip keeps the existing row and drops the incoming one.
+	Skip ConflictPolicy = "skip"
+	// Overwrite replaces the existing row with the incoming one.
+	Overwrite ConflictPolicy = "overwrite"
+)
+
+var (
+	ErrInvalidBatchOptions = apperr.New(apperr.Invalid, "INVALID_BATCH_OPTIONS", "invalid batch options")
+)
+
+// Table describes the rows written by a BatchWriter. Key must be one of Columns.
+type Table struct {
+	Name    string
+	Key     string
+	Columns []string
+}
+
+type BatchOptions struct {
+	ChunkSize int
+	Mode      BatchMode
+	Conflict  ConflictPolicy
+	// Overwritten, when set, runs the write of every chunk that overwrites
+	// stored rows, inside the transaction of the chunk and with the keys of
+	// those rows, so the caller can look at them before and after write. An
+	// error fails the chunk like a failed write.
+	Overwritten func(ctx context.Context, keys []any, write func() error) error
+}
+
+// ParseBatchOptions reads the mode, chunk size and conflict policy, where
+// empty values fall back to an atomic write of DefaultChunkSize rows per
+// statement that fails on duplicate keys.
+func ParseBatchOptions(mode string, chunkSize int, conflict string) (BatchOptions, error) {
+	opts := BatchOptions{ChunkSize: chunkSize, Mode: BatchMode(mode), Conflict: ConflictPolicy(conflict)}
+	if opts.Mode == "" {
+		opts.Mode = Atomic
+	}
+	if opts.Mode != Atomic && opts.Mode != PerChunk {
+		return BatchOptions{}, fmt.Errorf("%w: mode must be %s or %s", ErrInvalidBatchOptions, Atomic, PerChunk)
+	}
+	if opts.Conflict == "" {
+		opts.Conflict = Fail
+	}
+	if opts.Conflict != Fail && opts.Conflict != Skip && opts.Conflict != Overwrite {
+		return BatchOptions{}, fmt.Errorf("%w: on_conflict must be %s, %s or %s", ErrInvalidBatchOptions, Fail, Skip, Overwrite)
+	}
+	if opts.ChunkSize < 0 {
+		return BatchOptions{}, fmt.Errorf("%w: chunk size must be positive", ErrInvalidBatchOptions)
+	}
+
+	return opts, nil
+}
+
+type Progress struct {
+	Rows         int      `json:"rows"`
+	Written      int      `json:"written"`
+	Inserted     int      `json:"inserted"`
+	Updated      int      `json:"updated"`
+	Skipped      int      `json:"skipped"`
+	Failed       int      `json:"failed"`
+	Chunks       int      `json:"chunks"`
+	FailedChunks int      `json:"failed_chunks"`
+	Errors       []string `json:"errors,omitempty"`
+}
+
+// BatchWriter inserts rows pulled from a stream in multi-row INSERT
+// statements of at most ChunkSize rows, so the input never has to be held in
+// memory and no statement exceeds the placeholder limit.
+type BatchWriter struct {
+	db      *sql.DB
+	dialect Dialect
+	table   Table
+	key     int
+	opts    BatchOptions
+}
+
+func NewBatchWriter(db *sql.DB, dialect Dialect, table Table, opts BatchOptions) *BatchWriter {
+	if opts.Mode == "" {
+		opts.Mode = Atomic
+	}
+	if opts.Conflict == "" {
+		opts.Conflict = Fail
+	}
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = DefaultChunkSize
+	}
+	if limit := maxPlaceholders / len(table.Columns); opts.ChunkSize > limit {
+		opts.ChunkSize = limit
+	}
+
+	key := 0
+	for i, column := range table.Columns {
+		if column == table.Key {
+			key = i
+		}
+	}
+
+	return &BatchWriter{db, dialect, table, key, opts}
+}
+
+// Write pulls rows from next until it returns io.EOF. Any other error from
+// next stops the write; in Atomic mode that rolls back everything written.
+func (w *BatchWriter) Write(ctx context.Context, next func() ([]any, error)) (Progress, error) {
+	progress := Progress{}
+
+	if w.opts.Mode == PerChunk {
+		err := w.write(ctx, next, &progress)
+		return progress, err
+	}
+
+	err := NewTransactor(w.db).WithinTx(ctx, func(ctx context.Context) error {
+		return w.write(ctx, next, &progress)
+	})
+	if err != nil {
+		// the rollback undoes the chunks already written, so none of their
+		// rows count
+		progress.Failed = progress.Rows
+		progress.Written, progress.Inserted, progress.Updated, progress.Skipped = 0, 0, 0, 0
+		return progress, err
+	}
+
+	return progress, nil
+}
+
+func (w *BatchWriter) write(ctx context.Context, next func() ([]any, error), progress *Progress) error {
+	chunk := make([][]any, 0, w.opts.ChunkSize)
+
+	for {
+		row, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if len(row) != len(w.table.Columns) {
+			return fmt.Errorf("%s: expected %d values, got %d", w.table.Name, len(w.table.Columns), len(row))
+		}
+
+		progress.Rows++
+		chunk = append(chunk, row)
+		if len(chunk) == w.opts.ChunkSize {
+			if err := w.flush(ctx, chunk, progress); err != nil {
+				return err
+			}
+			chunk = chunk[:0]
+		}
+	}
+
+	if len(chunk) > 0 {
+		return w.flush(ctx, chunk, progress)
+	}
+
+	return nil
+}
+
+func (w *BatchWriter) flush(ctx context.Context, chunk [][]any, progress *Progress) error {
+	progress.Chunks++
+
+	var inserted, updated, skipped int
+	err := NewTransactor(w.db).WithinTx(ctx, func(ctx context.Context) error {
+		inserted, updated, skipped = len(chunk), 0, 0
+
+		// rows without a key get one from the table, so only the keyed ones
+		// can conflict
+		keyed, unkeyed := make([][]any, 0, len(chunk)), make([][]any, 0)
+		for _, row := range chunk {
+			if missingKey(row[w.key]) {
+				unkeyed = append(unkeyed, row)
+			} else {
+				keyed = append(keyed, row)
+			}
+		}
+
+		// overwritten holds the keys of the stored rows the chunk replaces
+		var overwritten []any
+		if w.opts.Conflict != Fail && len(keyed) > 0 {
+			existing, err := w.existingKeys(ctx, keyed)
+			if err != nil {
+				return err
+			}
+
+			rows := make([][]any, 0, len(keyed))
+			seen := make(map[any]bool, len(keyed))
+			for _, row := range keyed {
+				key := keyOf(row[w.key])
+				duplicate := existing[key] || seen[key]
+				if existing[key] && !seen[key] && w.opts.Conflict == Overwrite {
+					overwritten = append(overwritten, row[w.key])
+				}
+				seen[key] = true
+
+				switch {
+				case duplicate && w.opts.Conflict == Skip:
+					skipped++
+					inserted--
+					continue
+				case duplicate:
+					updated++
+					inserted--
+				}
+				rows = append(rows, row)
+			}
+			keyed = rows
+		}
+
+		write := func() error {
+			if len(keyed) > 0 {
+				if _, err := Conn(ctx, w.db).ExecContext(ctx, w.statement(w.table.Columns, len(keyed)), flatten(keyed)...); err != nil {
+					return err
+				}
+			}
+			if len(unkeyed) > 0 {
+				columns, values := w.withoutKey(unkeyed)
+				if _, err := Conn(ctx, w.db).ExecContext(ctx, w.statement(columns, len(unkeyed)), values...); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+
+		if len(overwritten) > 0 && w.opts.Overwritten != nil {
+			return w.opts.Overwritten(ctx, overwritten, write)
+		}
+		return write()
+	})
+	if err != nil {
+		if w.opts.Mode == Atomic {
+			return err
+		}
+		progress.Failed += len(chunk)
+		progress.FailedChunks++
+		progress.Errors = append(progress.Errors, fmt.Sprintf("chunk %d: %s", progress.Chunks, err))
+		return nil
+	}
+
+	progress.Inserted += inserted
+	progress.Updated += updated
+	progress.Skipped += skipped
+	progress.Written += inserted + updated
+	return nil
+}
+
+// existingKeys returns the keys of chunk that are already stored.
+func (w *BatchWriter) existingKeys(ctx context.Context, chunk [][]any) (map[any]bool, error) {
+	keys := make([]any, 0, len(chunk))
+	for _, row := range chunk {
+		keys = append(keys, row[w.key])
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s IN (%s);",
+		w.table.Key, w.table.Name, w.table.Key, strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", "))
+	rows, err := Conn(ctx, w.db).QueryContext(ctx, query, keys...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := make(map[any]bool, len(keys))
+	for rows.Next() {
+		var key any
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		existing[keyOf(key)] = true
+	}
+
+	return existing, rows.Err()
+}
+
+func (w *BatchWriter) statement(columns []string, rows int) string {
+	placeholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+	values := strings.TrimSuffix(strings.Repeat(placeholders+", ", rows), ", ")
+	statement := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", w.table.Name, strings.Join(columns, ", "), values)
+
+	if w.opts.Conflict == Overwrite && len(columns) == len(w.table.Columns) {
+		statement += w.dialect.Upsert(w.table.Key, columns)
+	}
+
+	return statement + ";"
+}
+
+// withoutKey returns the columns and the flattened values of rows without the
+// key, so the table assigns it.
+func (w *BatchWriter) withoutKey(rows [][]any) ([]string, []any) {
+	columns := make([]string, 0, len(w.table.Columns)-1)
+	for i, column := range w.table.Columns {
+		if i != w.key {
+			columns = append(columns, column)
+		}
+	}
+
+	values := make([]any, 0, len(rows)*len(columns))
+	for _, row := range rows {
+		values = append(values, row[:w.key]...)
+		values = append(values, row[w.key+1:]...)
+	}
+
+	return columns, values
+}
+
+// missingKey reports whether a key was left for the table to assign, which
+// the rows decoded without one carry as zero.
+func missingKey(key any) bool {
+	switch k := key.(type) {
+	case nil:
+		return true
+	case int:
+		return k == 0
+	case int64:
+		return k == 0
+	default:
+		return false
+	}
+}
+
+// keyOf normalizes a key so values bound from Go and scanned back from the
+// driver compare equal.
+func keyOf(key any) any {
+	if k, ok := key.([]byte); ok {
+		return string(k)
+	}
+
+	return fmt.Sprint(key)
+}
+
+func flatten(chunk [][]any) []any {
+	values := make([]any, 0, len(chunk)*len(chunk[0]))
+	for _, row := range chunk {
+		values = append(values, row...)
+	}
+
+	return values
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Dialect holds the SQL that differs between the supported backends. Queries
+// shared by every backend stick to the common subset: ? placeholders,
+// LIMIT/OFFSET and backticked identifiers, which SQLite also accepts.
+type Dialect interface {
+	Name() string
+	// Upsert renders the clause that makes a multi-row INSERT overwrite the
+	// rows whose key already exists.
+	Upsert(key string, columns []string) string
+	// RowReferenced reports whether err is the refusal to delete a row that
+	// another table still references.
+	RowReferenced(err error) bool
+}
+
+const (
+	// mysqlRowReferenced is ER_ROW_IS_REFERENCED_2.
+	mysqlRowReferenced = 1451
+	// sqliteConstraint is SQLITE_CONSTRAINT. The extended code is not enough:
+	// a RESTRICT action fails as SQLITE_CONSTRAINT_TRIGGER, not _FOREIGNKEY.
+	sqliteConstraint = 19
+)
+
+var (
+	MySQL  Dialect = mysqlDialect{}
+	SQLite Dialect = sqliteDialect{}
+)
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string {
+	return "mysql"
+}
+
+func (mysqlDialect) Upsert(key string, columns []string) string {
+	return " ON DUPLICATE KEY UPDATE " + assignments(key, columns, "VALUES(%s)")
+}
+
+func (mysqlDialect) RowReferenced(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlRowReferenced
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string {
+	return "sqlite"
+}
+
+func (sqliteDialect) Upsert(key string, columns []string) string {
+	return fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET ", key) + assignments(key, columns, "excluded.%s")
+}
+
+func (sqliteDialect) RowReferenced(err error) bool {
+	var sqliteErr interface{ Code() int }
+	return errors.As(err, &sqliteErr) && sqliteErr.Code()&0xff == sqliteConstraint &&
+		strings.Contains(err.Error(), "FOREIGN KEY constraint failed")
+}
+
+func assignments(key string, columns []string, value string) string {
+	set := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if column != key {
+			set = append(set, column+" = "+fmt.Sprintf(value, column))
+		}
+	}
+
+	return strings.Join(set, ", ")
+}
@@ -1,19 +1,285 @@
 package filemanager
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
 	"encoding/json"
-	"os"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"desafio/pkg/apperr"
 )
 
-func LoadDataFromJSON(path string, destiny any) error {
-	data, err := os.ReadFile(path)
+type Format string
+
+const (
+	JSON   Format = "json"
+	NDJSON Format = "ndjson"
+	CSV    Format = "csv"
+)
+
+var (
+	ErrUnknownFormat = apperr.New(apperr.Invalid, "UNKNOWN_IMPORT_FORMAT", "unknown import format, expected json, ndjson or csv")
+)
+
+// RowError reports a row that could not be decoded. Decoding can continue
+// with the next row after it.
+type RowError struct {
+	Row int
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.Row, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// ParseFormat accepts a format name such as "csv" or a file extension such as ".jsonl".
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(strings.TrimPrefix(name, ".")) {
+	case "json":
+		return JSON, nil
+	case "ndjson", "jsonl":
+		return NDJSON, nil
+	case "csv":
+		return CSV, nil
+	default:
+		return "", ErrUnknownFormat
+	}
+}
+
+// FormatFromFilename detects the format from the file extension.
+func FormatFromFilename(name string) (Format, error) {
+	return ParseFormat(filepath.Ext(name))
+}
+
+// FormatFromContentType detects the format from a Content-Type header.
+func FormatFromContentType(contentType string) (Format, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", ErrUnknownFormat
+	}
+
+	switch mediaType {
+	case "application/json":
+		return JSON, nil
+	case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines":
+		return NDJSON, nil
+	case "text/csv", "application/csv":
+		return CSV, nil
+	default:
+		return "", ErrUnknownFormat
+	}
+}
+
+// Decoder streams rows of type T out of a JSON array, NDJSON or CSV source.
+type Decoder[T any] struct {
+	format Format
+	row    int
+
+	json   *json.Decoder
+	opened bool
+
+	lines *bufio.Scanner
+
+	csv    *csv.Reader
+	header []string
+	kinds  map[string]reflect.Kind
+}
+
+func NewDecoder[T any](r io.Reader, format Format) *Decoder[T] {
+	d := &Decoder[T]{format: format}
+
+	switch format {
+	case NDJSON:
+		d.lines = bufio.NewScanner(r)
+		d.lines.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	case CSV:
+		d.csv = csv.NewReader(r)
+		d.csv.FieldsPerRecord = -1
+		d.csv.TrimLeadingSpace = true
+		d.kinds = jsonFieldKinds(reflect.TypeOf((*T)(nil)).Elem())
+	default:
+		d.json = json.NewDecoder(r)
+	}
+
+	return d
+}
+
+// Next returns the next row, io.EOF once the source is exhausted, a *RowError
+// for a malformed row, or any other error when the source itself is unreadable.
+func (d *Decoder[T]) Next() (*T, error) {
+	switch d.format {
+	case NDJSON:
+		return d.nextLine()
+	case CSV:
+		return d.nextRecord()
+	case JSON:
+		return d.nextElement()
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+// Row is the 1-based number of the row returned by the last call to Next.
+func (d *Decoder[T]) Row() int {
+	return d.row
+}
+
+func (d *Decoder[T]) nextElement() (*T, error) {
+	if !d.opened {
+		token, err := d.json.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			return nil, errors.New("json import must be an array")
+		}
+		d.opened = true
+	}
+
+	if !d.json.More() {
+		return nil, io.EOF
+	}
+
+	d.row++
+	item := new(T)
+	if err := d.json.Decode(item); err != nil {
+		// type mismatches leave the decoder positioned after the element,
+		// syntax errors do not
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, &RowError{d.row, err}
+		}
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func (d *Decoder[T]) nextLine() (*T, error) {
+	for d.lines.Scan() {
+		d.row++
+		line := bytes.TrimSpace(d.lines.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		item := new(T)
+		if err := json.Unmarshal(line, item); err != nil {
+			return nil, &RowError{d.row, err}
+		}
+		return item, nil
+	}
+
+	if err := d.lines.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, io.EOF
+}
+
+func (d *Decoder[T]) nextRecord() (*T, error) {
+	if d.header == nil {
+		header, err := d.csv.Read()
+		if err != nil {
+			return nil, err
+		}
+		for i := range header {
+			header[i] = strings.TrimSpace(header[i])
+		}
+		d.header = header
+	}
+
+	record, err := d.csv.Read()
 	if err != nil {
-		return err
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		d.row++
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &RowError{d.row, err}
+		}
+		return nil, err
+	}
+	d.row++
+
+	if len(record) != len(d.header) {
+		return nil, &RowError{d.row, fmt.Errorf("expected %d fields, got %d", len(d.header), len(record))}
+	}
+
+	fields := make(map[string]any, len(record))
+	for i, column := range d.header {
+		value, err := convert(record[i], d.kinds[column])
+		if err != nil {
+			return nil, &RowError{d.row, fmt.Errorf("%s: %w", column, err)}
+		}
+		fields[column] = value
+	}
+
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return nil, &RowError{d.row, err}
+	}
+
+	item := new(T)
+	if err := json.Unmarshal(raw, item); err != nil {
+		return nil, &RowError{d.row, err}
+	}
+
+	return item, nil
+}
+
+// jsonFieldKinds maps the json names of the fields of t to their kinds, so CSV
+// cells can be converted before being decoded like any other row.
+func jsonFieldKinds(t reflect.Type) map[string]reflect.Kind {
+	kinds := map[string]reflect.Kind{}
+	if t.Kind() != reflect.Struct {
+		return kinds
 	}
 
-	if err = json.Unmarshal(data, &destiny); err != nil {
-		return err
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		kinds[name] = field.Type.Kind()
 	}
 
-	return nil
+	return kinds
+}
+
+func convert(cell string, kind reflect.Kind) (any, error) {
+	cell = strings.TrimSpace(cell)
+
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(cell, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(cell, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(cell, 64)
+	case reflect.Bool:
+		return strconv.ParseBool(cell)
+	default:
+		return cell, nil
+	}
 }
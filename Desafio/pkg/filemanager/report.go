@@ -0,0 +1,63 @@
+package filemanager
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"desafio/pkg/apperr"
+	"desafio/pkg/database"
+)
+
+var (
+	ErrInvalidSource = apperr.New(apperr.Invalid, "INVALID_IMPORT_SOURCE", "invalid import source")
+)
+
+type Rejection struct {
+	Row    int    `json:"row"`
+	Reason string `json:"reason"`
+}
+
+// Report counts the rows of an import that passed validation, lists the ones
+// that were rejected with the reason for each rejection, and carries the
+// progress of writing the accepted rows.
+type Report struct {
+	Accepted int               `json:"accepted"`
+	Rejected []Rejection       `json:"rejected"`
+	Progress database.Progress `json:"progress"`
+}
+
+func NewReport() *Report {
+	return &Report{Rejected: make([]Rejection, 0)}
+}
+
+// Stream returns a pull function over the rows of d that pass validate,
+// recording every other row as a rejection in report. It returns io.EOF once
+// d is exhausted, and ErrInvalidSource when the source is unreadable or
+// malformed beyond a single row.
+func Stream[T any](d *Decoder[T], validate func(*T) error, report *Report) func() (*T, error) {
+	return func() (*T, error) {
+		for {
+			item, err := d.Next()
+			if errors.Is(err, io.EOF) {
+				return nil, io.EOF
+			}
+			if err != nil {
+				var rowErr *RowError
+				if errors.As(err, &rowErr) {
+					report.Rejected = append(report.Rejected, Rejection{rowErr.Row, rowErr.Err.Error()})
+					continue
+				}
+				return nil, fmt.Errorf("%w: %v", ErrInvalidSource, err)
+			}
+
+			if err := validate(item); err != nil {
+				report.Rejected = append(report.Rejected, Rejection{d.Row(), err.Error()})
+				continue
+			}
+
+			report.Accepted++
+			return item, nil
+		}
+	}
+}
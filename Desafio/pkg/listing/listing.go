@@ -0,0 +1,351 @@
+package listing
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"desafio/pkg/apperr"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+
+	dateLayout     = "2006-01-02"
+	datetimeLayout = "2006-01-02 15:04:05"
+)
+
+var (
+	ErrInvalidParameter = apperr.New(apperr.Invalid, "INVALID_QUERY_PARAMETER", "invalid query parameter")
+)
+
+type Kind int
+
+const (
+	String Kind = iota
+	Int
+	Float
+	Bool
+	Datetime
+)
+
+type Operator string
+
+const (
+	Equal        Operator = "="
+	GreaterEqual Operator = ">="
+	LessEqual    Operator = "<="
+	Contains     Operator = "LIKE"
+)
+
+// Filter whitelists a query parameter and the column comparison it maps to.
+// Only filters declared in a Spec ever reach the SQL text, and values are
+// always bound as placeholders.
+type Filter struct {
+	Param    string
+	Column   string
+	Operator Operator
+	Kind     Kind
+}
+
+// Spec declares what a list endpoint can be filtered and sorted by.
+type Spec struct {
+	Filters []Filter
+	// Sorts maps the accepted values of the sort parameter to columns.
+	Sorts map[string]string
+	// DefaultSort is the column used when no sort is requested. It must be
+	// unique per row so pages are stable.
+	DefaultSort string
+}
+
+type Condition struct {
+	Column   string
+	Operator Operator
+	Value    any
+}
+
+type Params struct {
+	Limit      int
+	Offset     int
+	Sort       string
+	Desc       bool
+	Conditions []Condition
+
+	tieBreaker string
+	after      *cursor
+}
+
+// cursor is the last row of a page: its sort column and the values of the
+// sort column and the tie breaker, so the next page starts right after it
+// however the rows before it change.
+type cursor struct {
+	Sort  string `json:"s"`
+	Value any    `json:"v"`
+	Key   any    `json:"k"`
+}
+
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// invalid reports a malformed parameter, which is also the field of the error.
+func invalid(param, message string) error {
+	return ErrInvalidParameter.Wrap(fmt.Errorf("%s %s", param, message)).WithFields(apperr.FieldError{
+		Field:   param,
+		Code:    "INVALID_VALUE",
+		Message: message,
+	})
+}
+
+// Parse reads limit/cursor or page/size, sort/direction and the filters
+// declared in spec from values. Unknown filters are ignored, malformed values
+// are reported as ErrInvalidParameter.
+func Parse(values url.Values, spec Spec) (Params, error) {
+	params := Params{
+		Limit:      DefaultLimit,
+		Sort:       spec.DefaultSort,
+		tieBreaker: spec.DefaultSort,
+	}
+
+	if limit := firstOf(values, "limit", "size"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > MaxLimit {
+			return Params{}, invalid("limit", fmt.Sprintf("must be between 1 and %d", MaxLimit))
+		}
+		params.Limit = n
+	}
+
+	if sort := values.Get("sort"); sort != "" {
+		column, ok := spec.Sorts[sort]
+		if !ok {
+			return Params{}, invalid("sort", fmt.Sprintf("%q is not a sortable column", sort))
+		}
+		params.Sort = column
+	}
+
+	if raw := values.Get("cursor"); raw != "" {
+		after, err := decodeCursor(raw)
+		if err != nil {
+			return Params{}, invalid("cursor", "is malformed")
+		}
+		// the values of a cursor only order rows by the column they came from
+		if after.Sort != params.Sort {
+			return Params{}, invalid("cursor", "belongs to another sort")
+		}
+		params.after = after
+	} else if page := values.Get("page"); page != "" {
+		n, err := strconv.Atoi(page)
+		if err != nil || n < 1 {
+			return Params{}, invalid("page", "must be a positive integer")
+		}
+		params.Offset = (n - 1) * params.Limit
+	}
+
+	switch strings.ToLower(values.Get("direction")) {
+	case "", "asc":
+	case "desc":
+		params.Desc = true
+	default:
+		return Params{}, invalid("direction", "must be asc or desc")
+	}
+
+	for _, filter := range spec.Filters {
+		raw, ok := values[filter.Param]
+		if !ok || len(raw) == 0 {
+			continue
+		}
+
+		value, err := convert(raw[0], filter.Kind)
+		if err != nil {
+			return Params{}, invalid(filter.Param, "is malformed")
+		}
+		switch {
+		case filter.Operator == Contains:
+			value = "%" + escapeLike(raw[0]) + "%"
+		case filter.Kind == Datetime && filter.Operator == LessEqual && len(raw[0]) == len(dateLayout):
+			// an upper bound given as a bare date includes the whole day
+			value = raw[0] + " 23:59:59"
+		}
+
+		params.Conditions = append(params.Conditions, Condition{filter.Column, filter.Operator, value})
+	}
+
+	return params, nil
+}
+
+// Where renders the conditions as a WHERE clause, or "" when there are none.
+func (p Params) Where() (string, []any) {
+	if len(p.Conditions) == 0 {
+		return "", nil
+	}
+
+	clauses := make([]string, 0, len(p.Conditions))
+	values := make([]any, 0, len(p.Conditions))
+	for _, c := range p.Conditions {
+		if c.Operator == Contains {
+			clauses = append(clauses, fmt.Sprintf("%s LIKE ? ESCAPE '!'", c.Column))
+		} else {
+			clauses = append(clauses, fmt.Sprintf("%s %s ?", c.Column, c.Operator))
+		}
+		values = append(values, c.Value)
+	}
+
+	return " WHERE " + strings.Join(clauses, " AND "), values
+}
+
+// Seek renders the WHERE clause of the page itself: the conditions and, after
+// a cursor, the comparison that starts the page past the row it points at.
+// Unlike Where it narrows the rows, so it is not meant for counting them.
+func (p Params) Seek() (string, []any) {
+	where, values := p.Where()
+	if p.after == nil {
+		return where, values
+	}
+
+	comparison := ">"
+	if p.Desc {
+		comparison = "<"
+	}
+
+	var clause string
+	if p.Sort == p.tieBreaker {
+		clause = fmt.Sprintf("%s %s ?", p.Sort, comparison)
+		values = append(values, p.after.Key)
+	} else {
+		clause = fmt.Sprintf("(%s, %s) %s (?, ?)", p.Sort, p.tieBreaker, comparison)
+		values = append(values, p.after.Value, p.after.Key)
+	}
+
+	if where == "" {
+		return " WHERE " + clause, values
+	}
+	return where + " AND " + clause, values
+}
+
+// OrderBy renders the ORDER BY, LIMIT and OFFSET clauses.
+func (p Params) OrderBy() (string, []any) {
+	direction := "ASC"
+	if p.Desc {
+		direction = "DESC"
+	}
+
+	clause := fmt.Sprintf(" ORDER BY %s %s", p.Sort, direction)
+	if p.tieBreaker != "" && p.Sort != p.tieBreaker {
+		clause += fmt.Sprintf(", %s %s", p.tieBreaker, direction)
+	}
+	clause += " LIMIT ? OFFSET ?"
+
+	return clause, []any{p.Limit, p.Offset}
+}
+
+// WithLookahead asks for one row past the page, which NewPage drops and uses
+// to tell whether another page follows.
+func (p Params) WithLookahead() Params {
+	p.Limit++
+	return p
+}
+
+// NewPage wraps a page of items read with p.WithLookahead(), setting
+// NextCursor to the last row of the page when more rows remain. field reads
+// the columns of an item, as in Apply.
+func NewPage[T any](items []T, total int, p Params, field func(item T, column string) any) *Page[T] {
+	if len(items) <= p.Limit {
+		return &Page[T]{Items: items, Total: total}
+	}
+
+	items = items[:p.Limit]
+	last := items[len(items)-1]
+	next := cursor{Sort: p.Sort, Value: field(last, p.Sort), Key: field(last, p.tieBreaker)}
+
+	return &Page[T]{Items: items, Total: total, NextCursor: encodeCursor(next)}
+}
+
+func firstOf(values url.Values, keys ...string) string {
+	for _, key := range keys {
+		if v := values.Get(key); v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
+
+func convert(raw string, kind Kind) (any, error) {
+	switch kind {
+	case Int:
+		return strconv.Atoi(raw)
+	case Float:
+		return strconv.ParseFloat(raw, 64)
+	case Bool:
+		return strconv.ParseBool(raw)
+	case Datetime:
+		for _, layout := range []string{datetimeLayout, dateLayout} {
+			if t, err := time.Parse(layout, raw); err == nil {
+				return t.Format(datetimeLayout), nil
+			}
+		}
+		return nil, errors.New("invalid datetime")
+	default:
+		return raw, nil
+	}
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`).Replace(value)
+}
+
+func encodeCursor(c cursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(raw string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	c := cursor{}
+	if err := decoder.Decode(&c); err != nil {
+		return nil, err
+	}
+	if c.Sort == "" || c.Key == nil || c.Value == nil {
+		return nil, errors.New("malformed cursor")
+	}
+
+	if c.Value, err = scalar(c.Value); err != nil {
+		return nil, err
+	}
+	if c.Key, err = scalar(c.Key); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// scalar turns a decoded cursor value back into what a column holds: numbers
+// come back as int64 or float64, strings and booleans as they are.
+func scalar(v any) (any, error) {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, nil
+		}
+		return v.Float64()
+	case string, bool:
+		return v, nil
+	default:
+		return nil, errors.New("malformed cursor value")
+	}
+}
@@ -0,0 +1,120 @@
+package listing
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Apply filters, sorts and pages items in memory the way the SQL
+// repositories do, reading columns through field. It returns the page and the
+// number of items matching the conditions.
+func Apply[T any](items []T, p Params, field func(item T, column string) any) ([]T, int) {
+	matched := make([]T, 0, len(items))
+	for _, item := range items {
+		if matches(item, p.Conditions, field) {
+			matched = append(matched, item)
+		}
+	}
+
+	compare := func(sortValue, key any, item T) int {
+		c := Compare(sortValue, field(item, p.Sort))
+		if c == 0 && p.tieBreaker != "" && p.Sort != p.tieBreaker {
+			c = Compare(key, field(item, p.tieBreaker))
+		}
+		if p.Desc {
+			return -c
+		}
+		return c
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return compare(field(matched[i], p.Sort), field(matched[i], p.tieBreaker), matched[j]) < 0
+	})
+
+	total := len(matched)
+	rows := matched
+	if p.after != nil {
+		// the page starts at the first row past the cursor, as Seek does
+		start := sort.Search(len(rows), func(i int) bool {
+			return compare(p.after.Value, p.after.Key, rows[i]) < 0
+		})
+		rows = rows[start:]
+	}
+
+	if p.Offset >= len(rows) {
+		return make([]T, 0), total
+	}
+	end := len(rows)
+	if p.Limit > 0 && p.Offset+p.Limit < end {
+		end = p.Offset + p.Limit
+	}
+
+	return rows[p.Offset:end], total
+}
+
+func matches[T any](item T, conditions []Condition, field func(item T, column string) any) bool {
+	for _, c := range conditions {
+		value := field(item, c.Column)
+
+		switch c.Operator {
+		case Equal:
+			if Compare(value, c.Value) != 0 {
+				return false
+			}
+		case GreaterEqual:
+			if Compare(value, c.Value) < 0 {
+				return false
+			}
+		case LessEqual:
+			if Compare(value, c.Value) > 0 {
+				return false
+			}
+		case Contains:
+			pattern := fmt.Sprint(c.Value)
+			pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "%"), "%")
+			pattern = strings.NewReplacer(`!!`, `!`, `!%`, `%`, `!_`, `_`).Replace(pattern)
+			if !strings.Contains(strings.ToLower(fmt.Sprint(value)), strings.ToLower(pattern)) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Compare orders two column values: numbers and booleans numerically and
+// everything else as case-insensitive text, like the default MySQL collation.
+func Compare(a, b any) int {
+	x, xNumeric := number(a)
+	y, yNumeric := number(b)
+	if xNumeric && yNumeric {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		default:
+			return 0
+		}
+	}
+
+	return strings.Compare(strings.ToLower(fmt.Sprint(a)), strings.ToLower(fmt.Sprint(b)))
+}
+
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case bool:
+		if n {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
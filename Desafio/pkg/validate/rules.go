@@ -0,0 +1,70 @@
+package validate
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Check builds a rule from a predicate, failing with code and message when it
+// does not hold.
+func Check[T any](code, message string, ok func(T) bool) Rule[T] {
+	return func(ctx context.Context, value T) (*Violation, error) {
+		if ok(value) {
+			return nil, nil
+		}
+		return &Violation{code, message}, nil
+	}
+}
+
+func Required[T comparable]() Rule[T] {
+	var zero T
+	return Check("REQUIRED", "is required", func(value T) bool { return value != zero })
+}
+
+// NotBlank requires a string with something other than spaces.
+func NotBlank() Rule[string] {
+	return Check("REQUIRED", "is required", func(value string) bool { return strings.TrimSpace(value) != "" })
+}
+
+func NotEmpty[T any]() Rule[[]T] {
+	return Check("REQUIRED", "must have at least one item", func(value []T) bool { return len(value) > 0 })
+}
+
+func MaxLength(n int) Rule[string] {
+	return Check("MAX_LENGTH", fmt.Sprintf("must be at most %d characters", n), func(value string) bool {
+		return utf8.RuneCountInString(value) <= n
+	})
+}
+
+func Min[T cmp.Ordered](min T) Rule[T] {
+	return Check("MIN", fmt.Sprintf("must be at least %v", min), func(value T) bool { return value >= min })
+}
+
+func Max[T cmp.Ordered](max T) Rule[T] {
+	return Check("MAX", fmt.Sprintf("must be at most %v", max), func(value T) bool { return value <= max })
+}
+
+// Format requires a value parse accepts, described as format in the message.
+func Format[T any](format string, parse func(T) error) Rule[T] {
+	return Check("FORMAT", "must be "+format, func(value T) bool { return parse(value) == nil })
+}
+
+// Exists requires a value read finds, like the id of another entity. An error
+// matching notFound is a violation, any other one is returned as is.
+func Exists[T, R any](read func(context.Context, T) (R, error), notFound error) Rule[T] {
+	return func(ctx context.Context, value T) (*Violation, error) {
+		_, err := read(ctx, value)
+		switch {
+		case err == nil:
+			return nil, nil
+		case errors.Is(err, notFound):
+			return &Violation{"NOT_FOUND", "does not exist"}, nil
+		default:
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package validate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"desafio/pkg/apperr"
+)
+
+var ErrInvalid = apperr.New(apperr.Invalid, "VALIDATION_FAILED", "validation failed")
+
+type Violation struct {
+	Code    string
+	Message string
+}
+
+// Rule checks a value and returns the violation when it fails. The error is
+// reserved for checks that could not run, like a failed lookup.
+type Rule[T any] func(ctx context.Context, value T) (*Violation, error)
+
+// Field declares the rules of one JSON field of E.
+type Field[E any] struct {
+	name  string
+	check func(ctx context.Context, entity E, prefix string) ([]apperr.FieldError, error)
+}
+
+// Validator is the list of fields of E with their rules. Every field is
+// checked in one pass, each one reporting only its first failing rule.
+type Validator[E any] []Field[E]
+
+func New[E any](fields ...Field[E]) Validator[E] {
+	return fields
+}
+
+// With returns a validator with the fields of v followed by fields. A field
+// declared again only runs when the earlier declarations of it passed, so
+// lookups can be added on top of the format rules.
+func (v Validator[E]) With(fields ...Field[E]) Validator[E] {
+	return append(append(Validator[E](nil), v...), fields...)
+}
+
+// Validate returns nil when entity passes every rule, otherwise ErrInvalid
+// with the violations as its fields.
+func (v Validator[E]) Validate(ctx context.Context, entity E) error {
+	violations, err := v.violations(ctx, entity, "")
+	if err != nil {
+		return err
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+
+	messages := make([]string, len(violations))
+	for i, violation := range violations {
+		messages[i] = violation.Field + " " + violation.Message
+	}
+	return ErrInvalid.Wrap(errors.New(strings.Join(messages, ", "))).WithFields(violations...)
+}
+
+// WithContext returns Validate bound to ctx, for the APIs that take a plain
+// check function.
+func (v Validator[E]) WithContext(ctx context.Context) func(E) error {
+	return func(entity E) error {
+		return v.Validate(ctx, entity)
+	}
+}
+
+func (v Validator[E]) violations(ctx context.Context, entity E, prefix string) ([]apperr.FieldError, error) {
+	var violations []apperr.FieldError
+	failed := map[string]bool{}
+	for _, field := range v {
+		if failed[field.name] {
+			continue
+		}
+
+		found, err := field.check(ctx, entity, prefix)
+		if err != nil {
+			return nil, err
+		}
+		if len(found) > 0 {
+			failed[field.name] = true
+			violations = append(violations, found...)
+		}
+	}
+
+	return violations, nil
+}
+
+// Value declares the field name of E, read with get.
+func Value[E, T any](name string, get func(E) T, rules ...Rule[T]) Field[E] {
+	return Field[E]{name, func(ctx context.Context, entity E, prefix string) ([]apperr.FieldError, error) {
+		value := get(entity)
+		for _, rule := range rules {
+			violation, err := rule(ctx, value)
+			if err != nil {
+				return nil, err
+			}
+			if violation != nil {
+				return []apperr.FieldError{{Field: prefix + name, Code: violation.Code, Message: violation.Message}}, nil
+			}
+		}
+		return nil, nil
+	}}
+}
+
+// Each declares the slice field name of E, whose items are checked with v and
+// reported as name[i].field.
+func Each[E, T any](name string, get func(E) []T, v Validator[T]) Field[E] {
+	return Field[E]{name, func(ctx context.Context, entity E, prefix string) ([]apperr.FieldError, error) {
+		var violations []apperr.FieldError
+		for i, item := range get(entity) {
+			found, err := v.violations(ctx, item, fmt.Sprintf("%s%s[%d].", prefix, name, i))
+			if err != nil {
+				return nil, err
+			}
+			violations = append(violations, found...)
+		}
+		return violations, nil
+	}}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gostorage/pkg/auth"
+	"os"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/joho/godotenv"
+)
+
+const usage = "usage: apikey create <subject> [reader | clerk | admin] | revoke <subject> | hash <key>"
+
+func main() {
+	// The .env file is optional here, hash does not need the database
+	_ = godotenv.Load()
+
+	if len(os.Args) < 3 || len(os.Args) > 4 || (len(os.Args) == 4 && os.Args[1] != "create") {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// The hash goes to API_KEYS as subject:hash:role, for the JSON store
+	if os.Args[1] == "hash" {
+		fmt.Println(auth.HashKey(os.Args[2]))
+		return
+	}
+
+	db, err := sql.Open("mysql", os.Getenv("MYSQL_DATA_SOURCE"))
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	subject := os.Args[2]
+	switch os.Args[1] {
+	case "create":
+		// Keys are reader ones unless the role says otherwise
+		role := auth.RoleReader
+		if len(os.Args) == 4 {
+			role = os.Args[3]
+		}
+		if _, ok := auth.Roles[role]; !ok {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+
+		key, err := auth.NewKey()
+		if err != nil {
+			panic(err)
+		}
+		if _, err := db.ExecContext(ctx, "INSERT INTO api_keys (subject, key_hash, role) VALUES (?, ?, ?)", subject, auth.HashKey(key), role); err != nil {
+			panic(err)
+		}
+		// The key is not stored, this is the only time it is shown
+		fmt.Println(key)
+	case "revoke":
+		result, err := db.ExecContext(ctx, "UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE subject = ? AND revoked_at IS NULL", subject)
+		if err != nil {
+			panic(err)
+		}
+		revoked, err := result.RowsAffected()
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("revoked %d key(s) of %s\n", revoked, subject)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gostorage/internal/product"
+	"gostorage/pkg/store"
+	"os"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/joho/godotenv"
+)
+
+const usage = "usage: dates json | mysql [--dry-run]"
+
+func main() {
+	// The .env file is optional here, the store path has a default
+	_ = godotenv.Load()
+
+	if len(os.Args) < 2 || len(os.Args) > 3 || (len(os.Args) == 3 && os.Args[2] != "--dry-run") {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	dryRun := len(os.Args) == 3
+
+	var (
+		changes []product.ExpirationChange
+		target  string
+		err     error
+	)
+	switch os.Args[1] {
+	case "json":
+		// Normalize the JSON store file
+		target = os.Getenv("JSON_STORE_PATH")
+		if target == "" {
+			target = "products.json"
+		}
+		changes, err = store.NormalizeDates(target, dryRun)
+	case "mysql":
+		// Normalize the products table
+		db, openErr := sql.Open("mysql", os.Getenv("MYSQL_DATA_SOURCE"))
+		if openErr != nil {
+			panic(openErr)
+		}
+		defer db.Close()
+
+		target = "products table"
+		changes, err = product.NormalizeMySQLExpirations(context.Background(), db, dryRun)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	for _, c := range changes {
+		fmt.Printf("id %d: %s -> %s\n", c.Id, c.From, c.To)
+	}
+	switch {
+	case len(changes) == 0:
+		fmt.Printf("%s already has canonical dates\n", target)
+	case dryRun:
+		fmt.Printf("dry run, %s left untouched\n", target)
+	default:
+		fmt.Printf("normalized %d dates in %s\n", len(changes), target)
+	}
+}
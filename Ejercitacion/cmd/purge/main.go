@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gostorage/internal/audit"
+	"gostorage/internal/domain"
+	"gostorage/internal/product"
+	"gostorage/pkg/store"
+	"os"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/joho/godotenv"
+)
+
+const usage = "usage: purge json | mysql [--retention <duration>] [--dry-run]"
+
+// defaultRetention is how long deleted products can be restored before they are purged
+const defaultRetention = 30 * 24 * time.Hour
+
+func main() {
+	// The .env file is optional here, the store path and the retention have defaults
+	_ = godotenv.Load()
+
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// The retention comes from the flag, then from PURGE_RETENTION
+	retention := defaultRetention
+	if value := os.Getenv("PURGE_RETENTION"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "invalid PURGE_RETENTION:", err)
+			os.Exit(2)
+		}
+		retention = d
+	}
+	dryRun := false
+	for i := 2; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--dry-run":
+			dryRun = true
+		case "--retention":
+			if i+1 == len(os.Args) {
+				fmt.Fprintln(os.Stderr, usage)
+				os.Exit(2)
+			}
+			d, err := time.ParseDuration(os.Args[i+1])
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "invalid retention:", err)
+				os.Exit(2)
+			}
+			retention = d
+			i++
+		default:
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+	}
+	if retention < 0 {
+		fmt.Fprintln(os.Stderr, "retention cannot be negative")
+		os.Exit(2)
+	}
+
+	var (
+		repository product.Repository
+		target     string
+		// auditLog is where the server keeps the audit log of the store
+		auditLog audit.Repository
+	)
+	switch os.Args[1] {
+	case "json":
+		// Purge the JSON store file
+		target = os.Getenv("JSON_STORE_PATH")
+		if target == "" {
+			target = "products.json"
+		}
+		repository = store.NewJsonStore(target)
+
+		auditPath := os.Getenv("AUDIT_LOG_PATH")
+		if auditPath == "" {
+			auditPath = target + ".audit"
+		}
+		auditLog = audit.NewFileRepository(auditPath)
+	case "mysql":
+		// Purge the products table
+		db, err := sql.Open("mysql", os.Getenv("MYSQL_DATA_SOURCE"))
+		if err != nil {
+			panic(err)
+		}
+		defer db.Close()
+
+		target = "products table"
+		repository = product.NewRepository(db)
+		auditLog = audit.NewMySQLRepository(db)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// Products deleted before the cutoff are out of the retention window
+	ctx := context.Background()
+	before := time.Now().UTC().Add(-retention)
+
+	var (
+		purged []domain.Product
+		err    error
+	)
+	if dryRun {
+		var products []domain.Product
+		products, err = repository.GetAllWithDeleted(ctx)
+		for _, p := range products {
+			if product.DeletedBefore(p, before) {
+				purged = append(purged, p)
+			}
+		}
+	} else {
+		purged, err = product.Purge(ctx, repository, audit.NewRecorder(auditLog), before)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	for _, p := range purged {
+		fmt.Printf("id %d: %s (%s), deleted at %s\n", p.Id, p.Name, p.CodeValue, p.DeletedAt.Format(time.DateTime))
+	}
+	switch {
+	case len(purged) == 0:
+		fmt.Printf("%s has no products deleted more than %s ago\n", target, retention)
+	case dryRun:
+		fmt.Printf("dry run, %s left untouched\n", target)
+	default:
+		fmt.Printf("purged %d products from %s\n", len(purged), target)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"gostorage/pkg/store"
+	"os"
+
+	"github.com/joho/godotenv"
+)
+
+const usage = "usage: repair renumber | dedupe [--dry-run]"
+
+func main() {
+	// The .env file is optional here, the store path has a default
+	_ = godotenv.Load()
+
+	if len(os.Args) < 2 || len(os.Args) > 3 || (len(os.Args) == 3 && os.Args[2] != "--dry-run") {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	dryRun := len(os.Args) == 3
+
+	path := os.Getenv("JSON_STORE_PATH")
+	if path == "" {
+		path = "products.json"
+	}
+
+	changes, err := store.Repair(path, store.RepairMode(os.Args[1]), dryRun)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	for _, c := range changes {
+		if c.NewID == 0 {
+			fmt.Printf("removed    id %d (%s)\n", c.OldID, c.Product.CodeValue)
+			continue
+		}
+		fmt.Printf("renumbered id %d -> %d (%s)\n", c.OldID, c.NewID, c.Product.CodeValue)
+	}
+	switch {
+	case len(changes) == 0:
+		fmt.Printf("%s has no duplicated ids\n", path)
+	case dryRun:
+		fmt.Printf("dry run, %s left untouched\n", path)
+	}
+}
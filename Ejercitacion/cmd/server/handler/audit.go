@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"errors"
+	"gostorage/internal/audit"
+	"gostorage/pkg/web"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errInvalidTime is returned when a time of the query is not in one of the accepted layouts
+var errInvalidTime = errors.New("expected an RFC 3339 time, a date time as 2006-01-02 15:04:05 or a date as 2006-01-02")
+
+type AuditHandler struct {
+	rp audit.Repository
+}
+
+func NewAuditHandler(rp audit.Repository) *AuditHandler {
+	return &AuditHandler{rp}
+}
+
+func (h *AuditHandler) GetAll() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// request
+		// - get the filters from the query, all optional
+		filter := audit.Filter{
+			Entity: ctx.Query("entity"),
+			Actor:  ctx.Query("actor"),
+		}
+		if value, ok := ctx.GetQuery("entity_id"); ok {
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				web.Failure(ctx, invalidQuery("entity_id", err))
+				return
+			}
+			filter.EntityId = id
+		}
+
+		// - the time range includes from and leaves to out, a bare date as to includes that whole day
+		if value, ok := ctx.GetQuery("from"); ok {
+			from, _, err := parseTime(value)
+			if err != nil {
+				web.Failure(ctx, invalidQuery("from", err))
+				return
+			}
+			filter.From = from
+		}
+		if value, ok := ctx.GetQuery("to"); ok {
+			to, dateOnly, err := parseTime(value)
+			if err != nil {
+				web.Failure(ctx, invalidQuery("to", err))
+				return
+			}
+			if dateOnly {
+				to = to.AddDate(0, 0, 1)
+			}
+			filter.To = to
+		}
+
+		// process
+		// - get the matching entries of the log
+		entries, err := h.rp.Find(ctx, filter)
+		if err != nil {
+			web.Failure(ctx, err)
+			return
+		}
+
+		// response
+		web.Success(ctx, http.StatusOK, entries)
+	}
+}
+
+// parseTime parses a time of the query, reporting whether it was a bare date. Times without a
+// zone are in UTC, like the entries of the log
+func parseTime(value string) (t time.Time, dateOnly bool, err error) {
+	if t, err = time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	if t, err = time.Parse(time.DateTime, value); err == nil {
+		return t, false, nil
+	}
+	if t, err = time.Parse(time.DateOnly, value); err == nil {
+		return t, true, nil
+	}
+	return time.Time{}, false, errInvalidTime
+}
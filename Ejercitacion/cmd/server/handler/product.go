@@ -1,14 +1,23 @@
 package handler
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"gostorage/internal/domain"
 	"gostorage/internal/product"
+	"gostorage/pkg"
+	"gostorage/pkg/apperr"
+	"gostorage/pkg/patch"
+	"gostorage/pkg/validate"
 	"gostorage/pkg/web"
+	"io"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 type ProductHandler struct {
@@ -21,11 +30,60 @@ func NewProductHandler(sv product.Service) *ProductHandler {
 
 func (h *ProductHandler) GetAll() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		// request
+		// - get the expiration filters from the query, both optional
+		var before domain.Date
+		if value, ok := ctx.GetQuery("expires_before"); ok {
+			date, err := domain.ParseDate(value)
+			if err != nil {
+				web.Failure(ctx, invalidQuery("expires_before", err))
+				return
+			}
+			before = date
+		}
+		var expired *bool
+		if value, ok := ctx.GetQuery("expired"); ok {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				web.Failure(ctx, invalidQuery("expired", err))
+				return
+			}
+			expired = &b
+		}
+
+		// - include the deleted products, only when listing them all
+		includeDeleted := false
+		if value, ok := ctx.GetQuery("include_deleted"); ok {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				web.Failure(ctx, invalidQuery("include_deleted", err))
+				return
+			}
+			includeDeleted = b
+		}
+		if includeDeleted && (!before.IsZero() || expired != nil) {
+			web.Failure(ctx, errInvalidQuery.WithFields(apperr.FieldError{
+				Field:   "include_deleted",
+				Code:    "INVALID_VALUE",
+				Message: "cannot be combined with expiration filters",
+			}))
+			return
+		}
+
 		// process
-		// - get all products
-		products, err := h.sv.GetAll(ctx)
+		// - get all products, the deleted ones too if asked, or the ones matching the expiration filters
+		var products []domain.Product
+		var err error
+		switch {
+		case includeDeleted:
+			products, err = h.sv.GetAllWithDeleted(ctx)
+		case before.IsZero() && expired == nil:
+			products, err = h.sv.GetAll(ctx)
+		default:
+			products, err = h.sv.GetByExpiration(ctx, before, expired)
+		}
 		if err != nil {
-			web.Failure(ctx, http.StatusInternalServerError, err)
+			web.Failure(ctx, err)
 			return
 		}
 
@@ -40,7 +98,7 @@ func (h *ProductHandler) GetByID() gin.HandlerFunc {
 		// - get id from path
 		id, err := strconv.Atoi(ctx.Param("id"))
 		if err != nil {
-			web.Failure(ctx, http.StatusBadRequest, err)
+			web.Failure(ctx, product.ErrServiceInvalidProductID.Wrap(err))
 			return
 		}
 
@@ -48,18 +106,18 @@ func (h *ProductHandler) GetByID() gin.HandlerFunc {
 		// - get product by id
 		p, err := h.sv.GetByID(ctx, id)
 		if err != nil {
-			switch {
-			case errors.Is(err, product.ErrServiceInvalidProductID):
-				web.Failure(ctx, http.StatusBadRequest, err)
-			case errors.Is(err, product.ErrServiceProductNotFound):
-				web.Failure(ctx, http.StatusNotFound, err)
-			default:
-				web.Failure(ctx, http.StatusInternalServerError, err)
-			}
+			web.Failure(ctx, err)
 			return
 		}
 
 		// response
+		// - the version is the ETag, a client holding it already gets a 304
+		etag := web.ETag(p.Version)
+		ctx.Header("ETag", etag)
+		if header := ctx.GetHeader("If-None-Match"); header != "" && web.IfNoneMatch(header, etag) {
+			ctx.Status(http.StatusNotModified)
+			return
+		}
 		web.Success(ctx, http.StatusOK, p)
 	}
 }
@@ -70,7 +128,7 @@ func (h *ProductHandler) Create() gin.HandlerFunc {
 		// - get product from body
 		var p domain.Product
 		if err := ctx.ShouldBindJSON(&p); err != nil {
-			web.Failure(ctx, http.StatusUnprocessableEntity, err)
+			web.Failure(ctx, bindError(err))
 			return
 		}
 
@@ -78,26 +136,12 @@ func (h *ProductHandler) Create() gin.HandlerFunc {
 		// - create product
 		p, err := h.sv.Create(ctx, &p)
 		if err != nil {
-			switch {
-			case errors.Is(err, product.ErrServiceInvalidProductName):
-				web.Failure(ctx, http.StatusBadRequest, err)
-			case errors.Is(err, product.ErrServiceInvalidProductQuantity):
-				web.Failure(ctx, http.StatusBadRequest, err)
-			case errors.Is(err, product.ErrServiceInvalidProductCodeValue):
-				web.Failure(ctx, http.StatusBadRequest, err)
-			case errors.Is(err, product.ErrServiceInvalidProductExpiration):
-				web.Failure(ctx, http.StatusBadRequest, err)
-			case errors.Is(err, product.ErrServiceInvalidProductPrice):
-				web.Failure(ctx, http.StatusBadRequest, err)
-			case errors.Is(err, product.ErrorServiceAlreadyExistsCodeValue):
-				web.Failure(ctx, http.StatusConflict, err)
-			default:
-				web.Failure(ctx, http.StatusInternalServerError, err)
-			}
+			web.Failure(ctx, err)
 			return
 		}
 
 		// response
+		ctx.Header("ETag", web.ETag(p.Version))
 		web.Success(ctx, http.StatusCreated, p)
 	}
 }
@@ -108,7 +152,7 @@ func (h *ProductHandler) Update() gin.HandlerFunc {
 		// - get id from path
 		id, err := strconv.Atoi(ctx.Param("id"))
 		if err != nil {
-			web.Failure(ctx, http.StatusBadRequest, err)
+			web.Failure(ctx, product.ErrServiceInvalidProductID.Wrap(err))
 			return
 		}
 
@@ -116,61 +160,37 @@ func (h *ProductHandler) Update() gin.HandlerFunc {
 		// - get product from body
 		originalProduct, err := h.sv.GetByID(ctx, id)
 		if err != nil {
-			switch {
-			case errors.Is(err, product.ErrServiceInvalidProductID):
-				web.Failure(ctx, http.StatusBadRequest, err)
-			case errors.Is(err, product.ErrServiceProductNotFound):
-				web.Failure(ctx, http.StatusNotFound, err)
-			default:
-				web.Failure(ctx, http.StatusInternalServerError, err)
-			}
+			web.Failure(ctx, err)
 			return
 		}
 
-		// - get product from body
-		productToUpdate := domain.Product{
-			Id:          originalProduct.Id,
-			Name:        originalProduct.Name,
-			Quantity:    originalProduct.Quantity,
-			CodeValue:   originalProduct.CodeValue,
-			IsPublished: originalProduct.IsPublished,
-			Expiration:  originalProduct.Expiration,
-			Price:       originalProduct.Price,
+		// - check the product is still the one the client read
+		if !ifMatch(ctx, originalProduct) {
+			web.Failure(ctx, errPreconditionFailed)
+			return
 		}
-		if err := ctx.ShouldBindJSON(&productToUpdate); err != nil {
-			web.Failure(ctx, http.StatusUnprocessableEntity, err)
+
+		// - apply the patch in the body to the product, by its content type
+		productToUpdate, err := patchProduct(ctx, originalProduct)
+		if err != nil {
+			web.Failure(ctx, bindError(err))
 			return
 		}
 
 		if originalProduct == productToUpdate {
+			ctx.Header("ETag", web.ETag(originalProduct.Version))
 			web.Success(ctx, http.StatusNoContent, nil)
 			return
 		}
 
 		// - update product
 		if err := h.sv.Update(ctx, &productToUpdate); err != nil {
-			switch {
-			case errors.Is(err, product.ErrServiceInvalidProductID):
-				web.Failure(ctx, http.StatusBadRequest, err)
-			case errors.Is(err, product.ErrServiceInvalidProductName):
-				web.Failure(ctx, http.StatusBadRequest, err)
-			case errors.Is(err, product.ErrServiceInvalidProductQuantity):
-				web.Failure(ctx, http.StatusBadRequest, err)
-			case errors.Is(err, product.ErrServiceInvalidProductCodeValue):
-				web.Failure(ctx, http.StatusBadRequest, err)
-			case errors.Is(err, product.ErrServiceInvalidProductExpiration):
-				web.Failure(ctx, http.StatusBadRequest, err)
-			case errors.Is(err, product.ErrServiceInvalidProductPrice):
-				web.Failure(ctx, http.StatusBadRequest, err)
-			case errors.Is(err, product.ErrorServiceAlreadyExistsCodeValue):
-				web.Failure(ctx, http.StatusConflict, err)
-			default:
-				web.Failure(ctx, http.StatusInternalServerError, err)
-			}
+			web.Failure(ctx, versionConflict(ctx, err))
 			return
 		}
 
 		// response
+		ctx.Header("ETag", web.ETag(productToUpdate.Version))
 		web.Success(ctx, http.StatusOK, productToUpdate)
 	}
 }
@@ -181,21 +201,29 @@ func (h *ProductHandler) Delete() gin.HandlerFunc {
 		// - get id from path
 		id, err := strconv.Atoi(ctx.Param("id"))
 		if err != nil {
-			web.Failure(ctx, http.StatusBadRequest, err)
+			web.Failure(ctx, product.ErrServiceInvalidProductID.Wrap(err))
 			return
 		}
 
 		// process
-		// - delete product by id
-		if err := h.sv.Delete(ctx, id); err != nil {
-			switch {
-			case errors.Is(err, product.ErrServiceInvalidProductID):
-				web.Failure(ctx, http.StatusBadRequest, err)
-			case errors.Is(err, product.ErrServiceProductNotFound):
-				web.Failure(ctx, http.StatusNotFound, err)
-			default:
-				web.Failure(ctx, http.StatusInternalServerError, err)
+		// - with If-Match, check the product is still the one the client read and delete only that version
+		version := 0
+		if ctx.GetHeader("If-Match") != "" {
+			p, err := h.sv.GetByID(ctx, id)
+			if err != nil {
+				web.Failure(ctx, err)
+				return
 			}
+			if !ifMatch(ctx, p) {
+				web.Failure(ctx, errPreconditionFailed)
+				return
+			}
+			version = p.Version
+		}
+
+		// - delete product by id
+		if err := h.sv.Delete(ctx, id, version); err != nil {
+			web.Failure(ctx, versionConflict(ctx, err))
 			return
 		}
 
@@ -203,3 +231,195 @@ func (h *ProductHandler) Delete() gin.HandlerFunc {
 		web.Success(ctx, http.StatusNoContent, nil)
 	}
 }
+
+func (h *ProductHandler) Restore() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// request
+		// - get id from path
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			web.Failure(ctx, product.ErrServiceInvalidProductID.Wrap(err))
+			return
+		}
+
+		// process
+		// - restore the deleted product
+		p, err := h.sv.Restore(ctx, id)
+		if err != nil {
+			web.Failure(ctx, err)
+			return
+		}
+
+		// response
+		ctx.Header("ETag", web.ETag(p.Version))
+		web.Success(ctx, http.StatusOK, p)
+	}
+}
+
+// MovementRequest is the body of a new stock movement
+type MovementRequest struct {
+	Type     domain.MovementType `json:"type"`
+	Quantity int                 `json:"quantity"`
+	Note     string              `json:"note"`
+}
+
+func (h *ProductHandler) AddMovement() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// request
+		// - get id from path
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			web.Failure(ctx, product.ErrServiceInvalidProductID.Wrap(err))
+			return
+		}
+		// - get movement from body
+		var req MovementRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			web.Failure(ctx, bindError(err))
+			return
+		}
+
+		// process
+		// - record the movement
+		movement, err := h.sv.AddMovement(ctx, id, req.Type, req.Quantity, req.Note)
+		if err != nil {
+			web.Failure(ctx, err)
+			return
+		}
+
+		// response
+		web.Success(ctx, http.StatusCreated, movement)
+	}
+}
+
+func (h *ProductHandler) GetMovements() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// request
+		// - get id from path
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			web.Failure(ctx, product.ErrServiceInvalidProductID.Wrap(err))
+			return
+		}
+
+		// process
+		// - get the ledger of the product, each movement carries its running balance
+		movements, err := h.sv.GetMovements(ctx, id)
+		if err != nil {
+			web.Failure(ctx, err)
+			return
+		}
+
+		// response
+		web.Success(ctx, http.StatusOK, movements)
+	}
+}
+
+// patchProduct applies the patch in the body to the product. A merge patch (RFC 7386) sets the
+// members present in the body and removes the ones set to null, a plain JSON body is handled the
+// same way, and a JSON patch (RFC 6902) applies its operations in order
+func patchProduct(ctx *gin.Context, original domain.Product) (domain.Product, error) {
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		return domain.Product{}, err
+	}
+	document, err := json.Marshal(original)
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	switch ctx.ContentType() {
+	case patch.MergePatchContentType, binding.MIMEJSON, "":
+		document, err = patch.Merge(document, body)
+	case patch.JSONPatchContentType:
+		document, err = patch.Apply(document, body)
+	default:
+		return domain.Product{}, errUnsupportedPatch.Wrap(fmt.Errorf("%q", ctx.ContentType()))
+	}
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	// Decode the patched document strictly, a member the product does not have is an error
+	var patched domain.Product
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patched); err != nil {
+		return domain.Product{}, err
+	}
+
+	// The ID identifies the product and the version and deletion are managed by the repository, a patch cannot change them
+	if patched.Id != original.Id {
+		return domain.Product{}, readOnly("id")
+	}
+	if patched.Version != original.Version {
+		return domain.Product{}, readOnly("version")
+	}
+	if patched.DeletedAt != nil {
+		return domain.Product{}, readOnly("deleted_at")
+	}
+
+	return patched, nil
+}
+
+var (
+	// errUnsupportedPatch is returned when the body of a PATCH is not a merge patch nor a JSON patch
+	errUnsupportedPatch = apperr.New(apperr.UnsupportedMediaType, "UNSUPPORTED_PATCH_TYPE", "unsupported patch content type, expected "+
+		patch.MergePatchContentType+" or "+patch.JSONPatchContentType)
+	// errReadOnlyField is returned when a patch changes a field the client cannot set
+	errReadOnlyField = apperr.New(apperr.Invalid, "READ_ONLY_FIELD", "read-only field cannot be changed")
+	// errPreconditionFailed is returned when the product does not match the If-Match header
+	errPreconditionFailed = apperr.New(apperr.PreconditionFailed, "PRECONDITION_FAILED", "product does not match If-Match")
+	// errInvalidQuery is returned when a query parameter cannot be parsed
+	errInvalidQuery = apperr.New(apperr.Invalid, "INVALID_QUERY_PARAMETER", "invalid query parameter")
+	// errInvalidBody is returned when the body cannot be decoded into the request
+	errInvalidBody = apperr.New(apperr.Unprocessable, "INVALID_BODY", "invalid request body")
+)
+
+// readOnly returns the error of a patch changing the given read-only field
+func readOnly(field string) error {
+	return errReadOnlyField.WithFields(apperr.FieldError{Field: field, Code: "READ_ONLY", Message: "cannot be changed"})
+}
+
+// invalidQuery returns the error of a query parameter that cannot be parsed
+func invalidQuery(name string, err error) error {
+	return errInvalidQuery.Wrap(err).WithFields(apperr.FieldError{Field: name, Code: "INVALID_VALUE", Message: err.Error()})
+}
+
+// ifMatch reports whether the product meets the If-Match header, a request without it always does
+func ifMatch(ctx *gin.Context, p domain.Product) bool {
+	header := ctx.GetHeader("If-Match")
+	return header == "" || web.IfMatch(header, web.ETag(p.Version))
+}
+
+// versionConflict returns the error for a product changed by another request in the middle of this one,
+// a failed precondition when the client sent one. Any other error is returned as is
+func versionConflict(ctx *gin.Context, err error) error {
+	if errors.Is(err, product.ErrServiceProductVersionConflict) && ctx.GetHeader("If-Match") != "" {
+		return errPreconditionFailed.Wrap(err)
+	}
+	return err
+}
+
+// bindError returns the application error for a body that could not be bound or patched,
+// with the field that failed when it is known
+func bindError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, pkg.ErrInvalidDate):
+		// an expiration in none of the accepted layouts is a bad request, like any other invalid field
+		return validate.ErrInvalid.Wrap(err).WithFields(apperr.FieldError{
+			Field:   "expiration",
+			Code:    "FORMAT",
+			Message: err.Error(),
+		})
+	case errors.As(err, &typeErr):
+		return errInvalidBody.Wrap(err).WithFields(apperr.FieldError{
+			Field:   typeErr.Field,
+			Code:    "INVALID_TYPE",
+			Message: "expected " + typeErr.Type.String(),
+		})
+	default:
+		return apperr.Ensure(err, errInvalidBody)
+	}
+}
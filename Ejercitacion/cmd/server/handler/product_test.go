@@ -0,0 +1,138 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"gostorage/cmd/server/handler"
+	"gostorage/internal/audit"
+	"gostorage/internal/domain"
+	"gostorage/internal/product"
+	"gostorage/pkg/apperr"
+	"gostorage/pkg/patch"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newServer maps the update of a product on a memory repository holding one product, which it returns
+func newServer(t *testing.T) (*gin.Engine, product.Repository, domain.Product) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	rp := product.NewMemoryRepository()
+	p := domain.Product{Name: "Milk", Quantity: 10, CodeValue: "M1", IsPublished: true, Expiration: domain.NewDate(2030, time.January, 31), Price: 1.5}
+	if err := rp.Create(context.Background(), &p); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	sv := product.NewService(rp, audit.NewRecorder(audit.NewFileRepository(filepath.Join(t.TempDir(), "audit.log"))))
+	server := gin.New()
+	server.PATCH("/products/:id", handler.NewProductHandler(sv).Update())
+	return server, rp, p
+}
+
+func TestUpdateRejectsReadOnlyFields(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		field       string
+	}{
+		{"merge patch of the id", patch.MergePatchContentType, `{"id":2}`, "id"},
+		{"merge patch of the version", patch.MergePatchContentType, `{"version":7}`, "version"},
+		{"merge patch of the deletion", patch.MergePatchContentType, `{"deleted_at":"2024-01-01T00:00:00Z"}`, "deleted_at"},
+		{"plain JSON of the id", "application/json", `{"name":"Oat milk","id":2}`, "id"},
+		{"JSON patch of the id", patch.JSONPatchContentType, `[{"op":"replace","path":"/id","value":2}]`, "id"},
+		{"JSON patch of the version", patch.JSONPatchContentType, `[{"op":"replace","path":"/version","value":7}]`, "version"},
+		{"JSON patch of the deletion", patch.JSONPatchContentType, `[{"op":"add","path":"/deleted_at","value":"2024-01-01T00:00:00Z"}]`, "deleted_at"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, rp, p := newServer(t)
+
+			response := send(server, p.Id, tt.contentType, tt.body, "")
+			problem := readProblem(t, response, http.StatusBadRequest)
+			if problem.Code != "READ_ONLY_FIELD" || len(problem.Errors) != 1 || problem.Errors[0].Field != tt.field {
+				t.Fatalf("got problem %+v", problem)
+			}
+
+			if got, _ := rp.GetByID(context.Background(), p.Id); got != p {
+				t.Fatalf("product changed: got %+v, want %+v", got, p)
+			}
+		})
+	}
+}
+
+func TestUpdateIfMatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		ifMatch    string
+		wantStatus int
+	}{
+		{"without the header", "", http.StatusOK},
+		{"with the current version", `"1"`, http.StatusOK},
+		{"with any version", "*", http.StatusOK},
+		{"with the current version among others", `"3", "1"`, http.StatusOK},
+		{"with an old version", `"3"`, http.StatusPreconditionFailed},
+		{"with a weak tag of the current version", `W/"1"`, http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, rp, p := newServer(t)
+
+			response := send(server, p.Id, patch.MergePatchContentType, `{"name":"Oat milk"}`, tt.ifMatch)
+			got, _ := rp.GetByID(context.Background(), p.Id)
+
+			if tt.wantStatus == http.StatusPreconditionFailed {
+				if problem := readProblem(t, response, tt.wantStatus); problem.Code != "PRECONDITION_FAILED" {
+					t.Fatalf("got problem %+v", problem)
+				}
+				if got != p {
+					t.Fatalf("product changed: got %+v, want %+v", got, p)
+				}
+				return
+			}
+
+			if response.Code != tt.wantStatus {
+				t.Fatalf("got status %d: %s", response.Code, response.Body)
+			}
+			if etag := response.Header().Get("ETag"); etag != `"2"` || got.Name != "Oat milk" || got.Version != 2 {
+				t.Fatalf("got ETag %s and product %+v", etag, got)
+			}
+		})
+	}
+}
+
+// send sends the patch of the product, with the If-Match header when ifMatch is set
+func send(server *gin.Engine, id int, contentType, body, ifMatch string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPatch, "/products/"+strconv.Itoa(id), strings.NewReader(body))
+	request.Header.Set("Content-Type", contentType)
+	if ifMatch != "" {
+		request.Header.Set("If-Match", ifMatch)
+	}
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	return response
+}
+
+// readProblem decodes the problem of a failed response with the given status
+func readProblem(t *testing.T, response *httptest.ResponseRecorder, status int) apperr.Problem {
+	t.Helper()
+	if response.Code != status {
+		t.Fatalf("got status %d, want %d: %s", response.Code, status, response.Body)
+	}
+	if got := response.Header().Get("Content-Type"); !strings.HasPrefix(got, apperr.ContentType) {
+		t.Fatalf("got content type %q", got)
+	}
+	problem := apperr.Problem{}
+	if err := json.Unmarshal(response.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("decode problem: %v", err)
+	}
+	return problem
+}
@@ -1,47 +1,212 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"gostorage/cmd/server/handler"
+	"gostorage/internal/audit"
 	"gostorage/internal/product"
+	"gostorage/pkg"
+	"gostorage/pkg/auth"
+	"gostorage/pkg/store"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/joho/godotenv"
 )
 
+// policy is the permission each route behind the authentication requires. Readers and clerks
+// can list and look up products and their stock, and only admins change them, delete and
+// restore them and read the audit log
+var policy = auth.Policy{
+	"GET /products/":               auth.Read,
+	"GET /products/:id":            auth.Read,
+	"POST /products/":              auth.Admin,
+	"PATCH /products/:id":          auth.Admin,
+	"DELETE /products/:id":         auth.Admin,
+	"POST /products/:id/restore":   auth.Admin,
+	"GET /products/:id/movements":  auth.Read,
+	"POST /products/:id/movements": auth.Admin,
+	"GET /audit/":                  auth.Admin,
+}
+
 func main() {
 	if err := godotenv.Load(); err != nil {
 		panic(err)
 	}
-	mysqlDataSource := os.Getenv("MYSQL_DATA_SOURCE")
 
-	db, err := sql.Open("mysql", mysqlDataSource)
-	if err != nil {
-		panic(err)
+	// Accepted expiration layouts, by name or as Go layouts, default to all the named ones
+	if layouts := os.Getenv("DATE_LAYOUTS"); layouts != "" {
+		if err := pkg.SetDateLayouts(strings.Split(layouts, ",")...); err != nil {
+			panic(err)
+		}
 	}
-	defer db.Close()
 
-	if err := db.Ping(); err != nil {
-		panic(err)
+	// Choose the storage backend, MySQL unless STORAGE says otherwise
+	var repository product.Repository
+	// API keys are only stored in MySQL, the JSON store takes them from API_KEYS
+	var keys auth.KeyStore
+	// The audit log is kept next to the products, in a file beside the JSON store
+	var auditLog audit.Repository
+	switch storage := os.Getenv("STORAGE"); storage {
+	case "json":
+		path := os.Getenv("JSON_STORE_PATH")
+		if path == "" {
+			path = "products.json"
+		}
+		// Compaction thresholds are optional, the store has defaults
+		opts := store.Options{}
+		if every := os.Getenv("JSON_STORE_COMPACT_EVERY"); every != "" {
+			n, err := strconv.Atoi(every)
+			if err != nil {
+				panic(err)
+			}
+			opts.CompactEvery = n
+		}
+		if interval := os.Getenv("JSON_STORE_COMPACT_INTERVAL"); interval != "" {
+			d, err := time.ParseDuration(interval)
+			if err != nil {
+				panic(err)
+			}
+			opts.CompactInterval = d
+		}
+		repository = store.NewJsonStoreWithOptions(path, opts)
+
+		auditPath := os.Getenv("AUDIT_LOG_PATH")
+		if auditPath == "" {
+			auditPath = path + ".audit"
+		}
+		auditLog = audit.NewFileRepository(auditPath)
+	case "", "mysql":
+		db, err := sql.Open("mysql", os.Getenv("MYSQL_DATA_SOURCE"))
+		if err != nil {
+			panic(err)
+		}
+		defer db.Close()
+
+		if err := db.Ping(); err != nil {
+			panic(err)
+		}
+
+		repository = product.NewRepository(db)
+		keys = auth.NewSQLKeyStore(db)
+		auditLog = audit.NewMySQLRepository(db)
+	default:
+		panic(fmt.Sprintf("unknown STORAGE %q, expected json or mysql", storage))
 	}
 
-	repository := product.NewRepository(db)
-	service := product.NewService(repository)
+	recorder := audit.NewRecorder(auditLog)
+	service := product.NewService(repository, recorder)
+
+	// Unpublish expired products in the background when an interval is set
+	if interval := os.Getenv("EXPIRATION_JOB_INTERVAL"); interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil {
+			panic(err)
+		}
+		dryRun, _ := strconv.ParseBool(os.Getenv("EXPIRATION_JOB_DRY_RUN"))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go product.NewExpirationJob(repository, recorder, d, dryRun, nil).Start(ctx)
+	}
 	productHandler := handler.NewProductHandler(service)
+	auditHandler := handler.NewAuditHandler(auditLog)
 
 	r := gin.Default()
 
 	r.GET("/ping", func(c *gin.Context) { c.String(200, "pong") })
-	products := r.Group("/products")
+	middleware := authentication(keys)
+	products := r.Group("/products", middleware...)
 	{
 		products.GET("/", productHandler.GetAll())
 		products.GET("/:id", productHandler.GetByID())
 		products.POST("/", productHandler.Create())
 		products.PATCH("/:id", productHandler.Update())
 		products.DELETE("/:id", productHandler.Delete())
+		products.POST("/:id/restore", productHandler.Restore())
+		products.GET("/:id/movements", productHandler.GetMovements())
+		products.POST("/:id/movements", productHandler.AddMovement())
+	}
+	auditGroup := r.Group("/audit", middleware...)
+	{
+		auditGroup.GET("/", auditHandler.GetAll())
+	}
+
+	// Every route behind the authentication must be in the policy, or it would deny every request
+	for _, prefix := range []string{"/products", "/audit"} {
+		if missing := policy.Missing(r.Routes(), prefix); len(missing) > 0 {
+			panic(fmt.Sprintf("routes without a permission in the policy: %v", missing))
+		}
 	}
 
 	r.Run(":8080")
 }
+
+// authentication builds the auth middleware from the environment: API keys from API_KEYS
+// and from keys, and bearer tokens verified with the key in JWT_KEY_FILE, followed by
+// the authorization of the policy. AUTH_DISABLED=true leaves the API open, for local runs
+func authentication(keys auth.KeyStore) []gin.HandlerFunc {
+	if disabled, _ := strconv.ParseBool(os.Getenv("AUTH_DISABLED")); disabled {
+		return nil
+	}
+
+	// API keys are looked up in the configuration first, then in the database
+	var stores []auth.KeyStore
+	if spec := os.Getenv("API_KEYS"); spec != "" {
+		static, err := auth.ParseKeys(spec)
+		if err != nil {
+			panic(err)
+		}
+		stores = append(stores, static)
+	}
+	if keys != nil {
+		stores = append(stores, keys)
+	}
+
+	var authenticators []auth.Authenticator
+	if len(stores) > 0 {
+		authenticators = append(authenticators, auth.NewAPIKeyAuthenticator(stores...))
+	}
+
+	// Bearer tokens are HS256 unless JWT_ALGORITHM says otherwise
+	if path := os.Getenv("JWT_KEY_FILE"); path != "" {
+		algorithm := os.Getenv("JWT_ALGORITHM")
+		if algorithm == "" {
+			algorithm = auth.HS256
+		}
+		key, err := auth.LoadJWTKey(algorithm, path)
+		if err != nil {
+			panic(err)
+		}
+
+		cfg := auth.JWTConfig{
+			Algorithm: algorithm,
+			Key:       key,
+			Issuer:    os.Getenv("JWT_ISSUER"),
+			Audience:  os.Getenv("JWT_AUDIENCE"),
+		}
+		if leeway := os.Getenv("JWT_LEEWAY"); leeway != "" {
+			if cfg.Leeway, err = time.ParseDuration(leeway); err != nil {
+				panic(err)
+			}
+		}
+
+		authenticator, err := auth.NewJWTAuthenticator(cfg)
+		if err != nil {
+			panic(err)
+		}
+		authenticators = append(authenticators, authenticator)
+	}
+
+	if len(authenticators) == 0 {
+		panic("no authentication configured, set API_KEYS or JWT_KEY_FILE, or AUTH_DISABLED=true")
+	}
+
+	return []gin.HandlerFunc{auth.Middleware(authenticators...), policy.Authorize(nil)}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"gostorage/pkg/auth"
+	"testing"
+)
+
+func TestClerkCannotChangeProducts(t *testing.T) {
+	clerk := auth.Principal{Subject: "clara", Roles: []string{auth.RoleClerk}}
+
+	for _, route := range []string{
+		"POST /products/",
+		"PATCH /products/:id",
+		"DELETE /products/:id",
+		"POST /products/:id/restore",
+		"POST /products/:id/movements",
+		"GET /audit/",
+	} {
+		permission, ok := policy[route]
+		if !ok {
+			t.Fatalf("%s: no permission in the policy", route)
+		}
+		if clerk.Can(permission) {
+			t.Fatalf("%s: a clerk has the %s permission", route, permission)
+		}
+	}
+
+	if !clerk.Can(policy["GET /products/"]) {
+		t.Fatalf("a clerk cannot list the products")
+	}
+}
@@ -0,0 +1,132 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+
+	"gostorage/internal/domain"
+)
+
+// FileRepository keeps the log in a file with one JSON entry per line, for the JSON store
+type FileRepository struct {
+	// path is the file of the log
+	path string
+	// mu serializes the appends, so each line is written whole and IDs are not repeated
+	mu sync.Mutex
+	// lastID is the ID of the last entry, -1 until the file is read
+	lastID int
+	// cut is set when the file does not end in a newline, the next append starts a new line
+	cut bool
+}
+
+// NewFileRepository creates a new FileRepository, the file is created on the first append
+func NewFileRepository(path string) Repository {
+	return &FileRepository{path: path, lastID: -1}
+}
+
+// Append writes the entry at the end of the file
+func (r *FileRepository) Append(ctx context.Context, e *domain.AuditEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// The IDs continue the ones of the file, read once
+	if r.lastID < 0 {
+		lastID := 0
+		cut, err := r.scan(func(entry domain.AuditEntry) {
+			lastID = max(lastID, entry.Id)
+		})
+		if err != nil {
+			return err
+		}
+		r.lastID, r.cut = lastID, cut
+	}
+
+	entry := *e
+	entry.Id = r.lastID + 1
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if r.cut {
+		line = append([]byte{'\n'}, line...)
+	}
+
+	// Append the line and sync it, the change it records is already saved
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	r.lastID, r.cut = entry.Id, false
+	e.Id = entry.Id
+
+	return nil
+}
+
+// Find reads the file and returns the entries matching the filter, in the order they were written
+func (r *FileRepository) Find(ctx context.Context, f Filter) ([]domain.AuditEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := make([]domain.AuditEntry, 0)
+	_, err := r.scan(func(entry domain.AuditEntry) {
+		if f.Match(entry) {
+			entries = append(entries, entry)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// scan calls fn with every entry of the file, a missing file has none, and reports whether the
+// file ends in a line cut by a crash in the middle of an append. Lines that cannot be decoded, like
+// that one, are skipped: the change they record was not confirmed to the client
+func (r *FileRepository) scan(fn func(domain.AuditEntry)) (cut bool, err error) {
+	f, err := os.Open(r.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	for {
+		// Read whole lines, the states are whole products and can be long
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var entry domain.AuditEntry
+			if json.Unmarshal(line, &entry) == nil {
+				fn(entry)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return len(line) > 0, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"gostorage/internal/domain"
+	"gostorage/pkg/database"
+)
+
+// MySQLRepository keeps the log in the audit_log table
+type MySQLRepository struct {
+	// db is the underlying MySQL database instance
+	db *sql.DB
+}
+
+// NewMySQLRepository creates a new MySQLRepository
+func NewMySQLRepository(db *sql.DB) Repository {
+	return &MySQLRepository{db}
+}
+
+// Append inserts the entry, the times are stored in UTC. It joins the transaction of ctx, so an entry
+// recorded by a repository hook is committed or rolled back with the change
+func (r *MySQLRepository) Append(ctx context.Context, e *domain.AuditEntry) error {
+	// Create the query
+	query := `
+		INSERT INTO audit_log (entity, entity_id, action, actor, occurred_at, before_state, after_state)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	// Execute the query, within the transaction of ctx when there is one
+	result, err := database.Conn(ctx, r.db).ExecContext(ctx, query, e.Entity, e.EntityId, e.Action, e.Actor, e.At.UTC().Format(time.DateTime), nullable(e.Before), nullable(e.After))
+	if err != nil {
+		return err
+	}
+
+	// Set the ID of the entry
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	e.Id = int(id)
+
+	return nil
+}
+
+// Find returns the entries matching the filter ordered by ID
+func (r *MySQLRepository) Find(ctx context.Context, f Filter) (entries []domain.AuditEntry, err error) {
+	// Create the query with a condition for each field of the filter that is set
+	var conditions []string
+	var args []any
+	if f.Entity != "" {
+		conditions = append(conditions, "entity = ?")
+		args = append(args, f.Entity)
+	}
+	if f.EntityId != 0 {
+		conditions = append(conditions, "entity_id = ?")
+		args = append(args, f.EntityId)
+	}
+	if f.Actor != "" {
+		conditions = append(conditions, "actor = ?")
+		args = append(args, f.Actor)
+	}
+	if !f.From.IsZero() {
+		conditions = append(conditions, "occurred_at >= ?")
+		args = append(args, f.From.UTC().Format(time.DateTime))
+	}
+	if !f.To.IsZero() {
+		conditions = append(conditions, "occurred_at < ?")
+		args = append(args, f.To.UTC().Format(time.DateTime))
+	}
+	query := `
+		SELECT id, entity, entity_id, action, actor, occurred_at, before_state, after_state
+		FROM audit_log
+	`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id"
+
+	// Execute the query
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries = make([]domain.AuditEntry, 0)
+	for rows.Next() {
+		// Scan the row into an entry, the time comes as text without parseTime
+		entry := domain.AuditEntry{}
+		var occurredAt string
+		var before, after []byte
+		if err = rows.Scan(&entry.Id, &entry.Entity, &entry.EntityId, &entry.Action, &entry.Actor, &occurredAt, &before, &after); err != nil {
+			return nil, err
+		}
+		if entry.At, err = time.Parse(time.DateTime, occurredAt); err != nil {
+			return nil, err
+		}
+		entry.Before, entry.After = before, after
+		entries = append(entries, entry)
+	}
+	err = rows.Err()
+
+	return
+}
+
+// nullable stores a missing state as NULL, an empty string is not valid JSON
+func nullable(state []byte) any {
+	if state == nil {
+		return nil
+	}
+	return string(state)
+}
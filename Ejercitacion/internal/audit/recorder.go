@@ -0,0 +1,86 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"gostorage/internal/domain"
+	"gostorage/pkg/auth"
+)
+
+const (
+	// ActionCreate is the creation of an entity, it has no before state
+	ActionCreate = "create"
+	// ActionUpdate is a change of an entity
+	ActionUpdate = "update"
+	// ActionDelete is the deletion of an entity, it has no after state
+	ActionDelete = "delete"
+	// ActionRestore is the undeletion of an entity
+	ActionRestore = "restore"
+	// ActionPurge is the removal for good of a deleted entity, it has no after state
+	ActionPurge = "purge"
+)
+
+const (
+	// Anonymous is the actor of the changes made with the authentication disabled
+	Anonymous = "anonymous"
+	// System is the actor of the changes made by the background jobs and the commands
+	System = "system"
+)
+
+// SystemContext returns a copy of ctx whose changes are recorded as made by the system
+func SystemContext(ctx context.Context) context.Context {
+	return auth.NewContext(ctx, auth.Principal{Subject: System})
+}
+
+// Recorder records the changes made to the entities
+type Recorder interface {
+	// Record logs the action on the entity with the given ID, made by the principal of ctx.
+	// before and after are stored as JSON, nil ones as null
+	Record(ctx context.Context, entity string, id int, action string, before, after any) error
+}
+
+// recorder is the default implementation of the Recorder interface
+type recorder struct {
+	rp Repository
+	// now returns the current time, the time of the entries
+	now func() time.Time
+}
+
+// NewRecorder creates a new recorder appending to the repository
+func NewRecorder(rp Repository) Recorder {
+	return &recorder{rp, time.Now}
+}
+
+func (r *recorder) Record(ctx context.Context, entity string, id int, action string, before, after any) error {
+	// The entry is stamped in UTC and to the second, as the MySQL table keeps it
+	entry := domain.AuditEntry{
+		Entity:   entity,
+		EntityId: id,
+		Action:   action,
+		Actor:    Anonymous,
+		At:       r.now().UTC().Truncate(time.Second),
+	}
+	if principal, ok := auth.FromContext(ctx); ok {
+		entry.Actor = principal.Subject
+	}
+
+	var err error
+	if entry.Before, err = state(before); err != nil {
+		return err
+	}
+	if entry.After, err = state(after); err != nil {
+		return err
+	}
+
+	return r.rp.Append(ctx, &entry)
+}
+
+// state marshals v, leaving nil values out
+func state(v any) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
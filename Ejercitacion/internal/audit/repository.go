@@ -0,0 +1,35 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"gostorage/internal/domain"
+)
+
+// Filter selects the entries of the log, its zero fields match every entry
+type Filter struct {
+	Entity   string
+	EntityId int
+	Actor    string
+	// From is the first instant included and To the first one left out
+	From time.Time
+	To   time.Time
+}
+
+// Match reports whether the entry passes the filter
+func (f Filter) Match(e domain.AuditEntry) bool {
+	return (f.Entity == "" || e.Entity == f.Entity) &&
+		(f.EntityId == 0 || e.EntityId == f.EntityId) &&
+		(f.Actor == "" || e.Actor == f.Actor) &&
+		(f.From.IsZero() || !e.At.Before(f.From)) &&
+		(f.To.IsZero() || e.At.Before(f.To))
+}
+
+// Repository is where the audit log is kept, entries are only ever appended
+type Repository interface {
+	// Append adds the entry to the log and sets its ID
+	Append(ctx context.Context, e *domain.AuditEntry) error
+	// Find returns the entries matching the filter ordered by ID
+	Find(ctx context.Context, f Filter) ([]domain.AuditEntry, error)
+}
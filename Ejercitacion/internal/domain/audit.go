@@ -0,0 +1,20 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry is a change made to an entity, with the state it had before and after it.
+// Before is null for a creation and After for a deletion
+type AuditEntry struct {
+	Id       int    `json:"id"`
+	Entity   string `json:"entity"`
+	EntityId int    `json:"entity_id"`
+	Action   string `json:"action"`
+	// Actor is the subject of the principal that made the change
+	Actor  string          `json:"actor"`
+	At     time.Time       `json:"at"`
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"gostorage/pkg"
+	"time"
+)
+
+// Date is a calendar date without time of day. It accepts any of the configured
+// input layouts and is always rendered as "2006-01-02"
+type Date struct {
+	time.Time
+}
+
+// NewDate returns the given calendar date
+func NewDate(year int, month time.Month, day int) Date {
+	return Date{time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+// ParseDate parses value with the accepted input layouts
+func ParseDate(value string) (Date, error) {
+	t, err := pkg.ParseDate(value)
+	if err != nil {
+		return Date{}, err
+	}
+	return Date{t}, nil
+}
+
+// String returns the date in the canonical layout, or an empty string for the zero date
+func (d Date) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.Format(pkg.CanonicalDateLayout)
+}
+
+// MarshalJSON renders the date in the canonical layout
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON accepts any of the configured input layouts, null or "" leave the zero date
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var value *string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("%w: %s", pkg.ErrInvalidDate, data)
+	}
+	if value == nil || *value == "" {
+		*d = Date{}
+		return nil
+	}
+
+	date, err := ParseDate(*value)
+	if err != nil {
+		return err
+	}
+	*d = date
+	return nil
+}
+
+// Scan reads a DATE column, or a text column holding a date
+func (d *Date) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*d = Date{}
+		return nil
+	case time.Time:
+		*d = NewDate(v.Year(), v.Month(), v.Day())
+		return nil
+	case []byte:
+		return d.scanText(string(v))
+	case string:
+		return d.scanText(v)
+	default:
+		return fmt.Errorf("%w: cannot scan %T", pkg.ErrInvalidDate, src)
+	}
+}
+
+func (d *Date) scanText(value string) error {
+	if value == "" || value == "0000-00-00" {
+		*d = Date{}
+		return nil
+	}
+
+	date, err := ParseDate(value)
+	if err != nil {
+		return err
+	}
+	*d = date
+	return nil
+}
+
+// Value writes the date in the canonical layout, which MySQL takes for a DATE column
+func (d Date) Value() (driver.Value, error) {
+	if d.IsZero() {
+		return nil, nil
+	}
+	return d.String(), nil
+}
@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+// MovementType is the reason of a stock movement
+type MovementType string
+
+const (
+	// MovementReceipt adds the received units
+	MovementReceipt MovementType = "receipt"
+	// MovementSale removes the sold units
+	MovementSale MovementType = "sale"
+	// MovementAdjustment corrects the stock in either direction
+	MovementAdjustment MovementType = "adjustment"
+	// MovementWriteOff removes lost or damaged units
+	MovementWriteOff MovementType = "write_off"
+)
+
+// StockMovement is an entry of the stock ledger of a product
+type StockMovement struct {
+	Id        int          `json:"id"`
+	ProductId int          `json:"product_id"`
+	Type      MovementType `json:"type"`
+	// Quantity is the signed change of the stock
+	Quantity int `json:"quantity"`
+	// Balance is the stock of the product right after the movement
+	Balance   int       `json:"balance"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MovementTime returns the current time as movements store it, in UTC and to the second
+func MovementTime() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
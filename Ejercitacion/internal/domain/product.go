@@ -1,11 +1,17 @@
 package domain
 
+import "time"
+
 type Product struct {
 	Id          int     `json:"id"`
-	Name        string  `json:"name" binding:"required"`
-	Quantity    int     `json:"quantity" binding:"required"`
-	CodeValue   string  `json:"code_value" binding:"required"`
+	Name        string  `json:"name"`
+	Quantity    int     `json:"quantity"`
+	CodeValue   string  `json:"code_value"`
 	IsPublished bool    `json:"is_published"`
-	Expiration  string  `json:"expiration" binding:"required"`
-	Price       float64 `json:"price" binding:"required"`
+	Expiration  Date    `json:"expiration"`
+	Price       float64 `json:"price"`
+	// Version starts at 1 and grows with every change, it is the ETag of the product
+	Version int `json:"version"`
+	// DeletedAt is when the product was deleted, nil while it is not
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
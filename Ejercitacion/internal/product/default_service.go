@@ -3,19 +3,44 @@ package product
 import (
 	"context"
 	"errors"
+	"gostorage/internal/audit"
 	"gostorage/internal/domain"
-	"gostorage/pkg"
+	"gostorage/pkg/validate"
 	"strings"
+	"time"
+)
+
+const (
+	// entity names the products in the audit log
+	entity = "products"
+	// movementEntity names the stock movements in the audit log
+	movementEntity = "stock_movements"
+)
+
+// rules are the constraints of the fields of a product, the lengths are the ones of the products table
+var rules = validate.New(
+	validate.Value("name", func(p domain.Product) string { return p.Name }, validate.NotBlank(), validate.MaxLength(255)),
+	// a write-off may take the stock to zero, so zero is a valid quantity
+	validate.Value("quantity", func(p domain.Product) int { return p.Quantity }, validate.Min(0)),
+	validate.Value("code_value", func(p domain.Product) string { return p.CodeValue }, validate.NotBlank(), validate.MaxLength(64)),
+	// the date type already rejects invalid expirations while decoding, here it only has to be present
+	validate.Value("expiration", func(p domain.Product) domain.Date { return p.Expiration },
+		validate.Check("REQUIRED", "is required", func(d domain.Date) bool { return !d.IsZero() })),
+	validate.Value("price", func(p domain.Product) float64 { return p.Price }, validate.Min(1.0)),
 )
 
 // service is the default implementation of the Service interface
 type service struct {
 	rp Repository
+	// audit records every change made through the service
+	audit audit.Recorder
+	// now returns the current time, it decides which products are expired
+	now func() time.Time
 }
 
-// NewService creates a new product service
-func NewService(r Repository) Service {
-	return &service{r}
+// NewService creates a new product service recording its changes with a
+func NewService(r Repository, a audit.Recorder) Service {
+	return &service{r, a, time.Now}
 }
 
 func (sv *service) GetAll(ctx context.Context) ([]domain.Product, error) {
@@ -27,6 +52,42 @@ func (sv *service) GetAll(ctx context.Context) ([]domain.Product, error) {
 	return products, nil
 }
 
+func (sv *service) GetAllWithDeleted(ctx context.Context) ([]domain.Product, error) {
+	// Get the products from the repository
+	products, err := sv.rp.GetAllWithDeleted(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func (sv *service) GetByExpiration(ctx context.Context, before domain.Date, expired *bool) ([]domain.Product, error) {
+	// Turn the filters into an expiration range, a product is expired once its expiration is before today
+	from, to := domain.Date{}, before
+	if expired != nil {
+		today := Today(sv.now())
+		if *expired {
+			if to.IsZero() || today.Before(to.Time) {
+				to = today
+			}
+		} else {
+			from = today
+		}
+	}
+
+	// An empty range matches nothing
+	if !from.IsZero() && !to.IsZero() && !from.Before(to.Time) {
+		return []domain.Product{}, nil
+	}
+
+	// Get the products from the repository
+	products, err := sv.rp.GetByExpiration(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
 func (sv *service) GetByID(ctx context.Context, id int) (domain.Product, error) {
 	// Validate that the ID of the product is not zero or negative
 	if id < 1 {
@@ -47,25 +108,9 @@ func (sv *service) GetByID(ctx context.Context, id int) (domain.Product, error)
 }
 
 func (sv *service) Create(ctx context.Context, p *domain.Product) (domain.Product, error) {
-	// Validate that the name of the product is not empty
-	if strings.TrimSpace(p.Name) == "" {
-		return domain.Product{}, ErrServiceInvalidProductName
-	}
-	// Validate that the quantity of the product is not zero or negative
-	if p.Quantity < 1 {
-		return domain.Product{}, ErrServiceInvalidProductQuantity
-	}
-	// Validate that the code value of the product is not empty
-	if strings.TrimSpace(p.CodeValue) == "" {
-		return domain.Product{}, ErrServiceInvalidProductCodeValue
-	}
-	// Validate that the expiration of the product is not empty or invalid
-	if strings.TrimSpace(p.Expiration) == "" || !pkg.IsValidDate(p.Expiration) {
-		return domain.Product{}, ErrServiceInvalidProductExpiration
-	}
-	// Validate that the price of the product is not zero or negative
-	if p.Price < 1 {
-		return domain.Product{}, ErrServiceInvalidProductPrice
+	// Validate the fields of the product, every invalid one is reported at once
+	if err := rules.Validate(ctx, *p); err != nil {
+		return domain.Product{}, err
 	}
 	// Validate that the product code value does not already exist
 	if exists, err := sv.rp.Exists(ctx, p.CodeValue); err != nil {
@@ -74,9 +119,17 @@ func (sv *service) Create(ctx context.Context, p *domain.Product) (domain.Produc
 		return domain.Product{}, ErrorServiceAlreadyExistsCodeValue
 	}
 
-	// Create the product in the repository
-	if err := sv.rp.Create(ctx, p); err != nil {
-		return domain.Product{}, err
+	// Create the product in the repository, recording it within the write
+	hook := func(ctx context.Context, change Change) error {
+		return sv.audit.Record(ctx, entity, change.After.Id, audit.ActionCreate, nil, change.After)
+	}
+	if err := sv.rp.Create(WithHook(ctx, hook), p); err != nil {
+		switch {
+		case errors.Is(err, ErrRepositoryProductCodeValueDuplicated):
+			return domain.Product{}, ErrorServiceAlreadyExistsCodeValue
+		default:
+			return domain.Product{}, err
+		}
 	}
 
 	return *p, nil
@@ -86,25 +139,9 @@ func (sv *service) Update(ctx context.Context, p *domain.Product) error {
 	if p.Id < 1 {
 		return ErrServiceInvalidProductID
 	}
-	// Validate that the name of the product is not empty
-	if strings.TrimSpace(p.Name) == "" {
-		return ErrServiceInvalidProductID
-	}
-	// Validate that the quantity of the product is not zero or negative
-	if p.Quantity < 1 {
-		return ErrServiceInvalidProductQuantity
-	}
-	// Validate that the code value of the product is not empty
-	if strings.TrimSpace(p.CodeValue) == "" {
-		return ErrServiceInvalidProductCodeValue
-	}
-	// Validate that the expiration of the product is not empty or invalid
-	if strings.TrimSpace(p.Expiration) == "" || !pkg.IsValidDate(p.Expiration) {
-		return ErrServiceInvalidProductExpiration
-	}
-	// Validate that the price of the product is not zero or negative
-	if p.Price < 1 {
-		return ErrServiceInvalidProductPrice
+	// Validate the fields of the product, every invalid one is reported at once
+	if err := rules.Validate(ctx, *p); err != nil {
+		return err
 	}
 	// Validate that the product code value does not already exist with different ID
 	if exists, err := sv.rp.ExistsWithDifferentID(ctx, p.Id, p.CodeValue); err != nil {
@@ -112,12 +149,18 @@ func (sv *service) Update(ctx context.Context, p *domain.Product) error {
 	} else if exists {
 		return ErrorServiceAlreadyExistsCodeValue
 	}
-
-	// Update the product in the repository
-	if err := sv.rp.Update(ctx, p); err != nil {
+	// Update the product in the repository, recording the change within the write
+	hook := func(ctx context.Context, change Change) error {
+		return sv.audit.Record(ctx, entity, change.After.Id, audit.ActionUpdate, change.Before, change.After)
+	}
+	if err := sv.rp.Update(WithHook(ctx, hook), p); err != nil {
 		switch {
 		case errors.Is(err, ErrRepositoryProductNotFound):
 			return ErrServiceProductNotFound
+		case errors.Is(err, ErrRepositoryProductCodeValueDuplicated):
+			return ErrorServiceAlreadyExistsCodeValue
+		case errors.Is(err, ErrRepositoryVersionConflict):
+			return ErrServiceProductVersionConflict
 		default:
 			return err
 		}
@@ -126,17 +169,22 @@ func (sv *service) Update(ctx context.Context, p *domain.Product) error {
 	return nil
 }
 
-func (sv *service) Delete(ctx context.Context, id int) error {
+func (sv *service) Delete(ctx context.Context, id int, version int) error {
 	// Validate that the ID of the product is not zero or negative
 	if id < 1 {
 		return ErrServiceInvalidProductID
 	}
 
-	// Delete the product from the repository
-	if err := sv.rp.Delete(ctx, id); err != nil {
+	// Delete the product from the repository, recording it within the write
+	hook := func(ctx context.Context, change Change) error {
+		return sv.audit.Record(ctx, entity, change.After.Id, audit.ActionDelete, change.Before, nil)
+	}
+	if err := sv.rp.Delete(WithHook(ctx, hook), id, version); err != nil {
 		switch {
 		case errors.Is(err, ErrRepositoryProductNotFound):
 			return ErrServiceProductNotFound
+		case errors.Is(err, ErrRepositoryVersionConflict):
+			return ErrServiceProductVersionConflict
 		default:
 			return err
 		}
@@ -144,3 +192,99 @@ func (sv *service) Delete(ctx context.Context, id int) error {
 
 	return nil
 }
+
+func (sv *service) Restore(ctx context.Context, id int) (domain.Product, error) {
+	// Validate that the ID of the product is not zero or negative
+	if id < 1 {
+		return domain.Product{}, ErrServiceInvalidProductID
+	}
+
+	// Restore the product in the repository, recording it within the write
+	hook := func(ctx context.Context, change Change) error {
+		return sv.audit.Record(ctx, entity, change.After.Id, audit.ActionRestore, change.Before, change.After)
+	}
+	product, err := sv.rp.Restore(WithHook(ctx, hook), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRepositoryProductNotFound):
+			return domain.Product{}, ErrServiceProductNotFound
+		case errors.Is(err, ErrRepositoryProductNotDeleted):
+			return domain.Product{}, ErrServiceProductNotDeleted
+		case errors.Is(err, ErrRepositoryProductCodeValueDuplicated):
+			return domain.Product{}, ErrorServiceAlreadyExistsCodeValue
+		default:
+			return domain.Product{}, err
+		}
+	}
+
+	return product, nil
+}
+
+func (sv *service) AddMovement(ctx context.Context, productID int, movementType domain.MovementType, quantity int, note string) (domain.StockMovement, error) {
+	// Validate that the ID of the product is not zero or negative
+	if productID < 1 {
+		return domain.StockMovement{}, ErrServiceInvalidProductID
+	}
+
+	// Turn the quantity into the signed change of the stock
+	switch movementType {
+	case domain.MovementReceipt:
+		if quantity < 1 {
+			return domain.StockMovement{}, ErrServiceInvalidMovementQuantity
+		}
+	case domain.MovementSale, domain.MovementWriteOff:
+		if quantity < 1 {
+			return domain.StockMovement{}, ErrServiceInvalidMovementQuantity
+		}
+		quantity = -quantity
+	case domain.MovementAdjustment:
+		if quantity == 0 {
+			return domain.StockMovement{}, ErrServiceInvalidMovementQuantity
+		}
+	default:
+		return domain.StockMovement{}, ErrServiceInvalidMovementType
+	}
+
+	// Record the movement in the repository
+	movement := domain.StockMovement{
+		ProductId: productID,
+		Type:      movementType,
+		Quantity:  quantity,
+		Note:      strings.TrimSpace(note),
+	}
+	// The repository sets the movement before running the hook, which records it within the write
+	hook := func(ctx context.Context, _ Change) error {
+		return sv.audit.Record(ctx, movementEntity, movement.Id, audit.ActionCreate, nil, movement)
+	}
+	if err := sv.rp.AddMovement(WithHook(ctx, hook), &movement); err != nil {
+		switch {
+		case errors.Is(err, ErrRepositoryProductNotFound):
+			return domain.StockMovement{}, ErrServiceProductNotFound
+		case errors.Is(err, ErrRepositoryNegativeStock):
+			return domain.StockMovement{}, ErrServiceNegativeStock
+		default:
+			return domain.StockMovement{}, err
+		}
+	}
+
+	return movement, nil
+}
+
+func (sv *service) GetMovements(ctx context.Context, productID int) ([]domain.StockMovement, error) {
+	// Validate that the ID of the product is not zero or negative
+	if productID < 1 {
+		return nil, ErrServiceInvalidProductID
+	}
+
+	// Get the ledger from the repository
+	movements, err := sv.rp.GetMovements(ctx, productID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRepositoryProductNotFound):
+			return nil, ErrServiceProductNotFound
+		default:
+			return nil, err
+		}
+	}
+	return movements, nil
+}
@@ -0,0 +1,98 @@
+package product
+
+import (
+	"context"
+	"gostorage/internal/audit"
+	"gostorage/internal/domain"
+	"log"
+	"time"
+)
+
+// Today returns the calendar date of t in UTC, the date expirations are compared against
+func Today(t time.Time) domain.Date {
+	t = t.UTC()
+	return domain.NewDate(t.Year(), t.Month(), t.Day())
+}
+
+// ExpirationJob unpublishes the published products whose expiration passed
+type ExpirationJob struct {
+	// rp is the repository holding the products
+	rp Repository
+	// audit records every product unpublished, as changed by the system
+	audit audit.Recorder
+	// interval is the time between runs
+	interval time.Duration
+	// dryRun only logs the products that would be unpublished
+	dryRun bool
+	// logger receives a line for every product changed
+	logger *log.Logger
+	// now returns the current time
+	now func() time.Time
+}
+
+// NewExpirationJob creates a new job running every interval, logging to logger or to the standard logger when nil
+func NewExpirationJob(rp Repository, a audit.Recorder, interval time.Duration, dryRun bool, logger *log.Logger) *ExpirationJob {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &ExpirationJob{rp: rp, audit: a, interval: interval, dryRun: dryRun, logger: logger, now: time.Now}
+}
+
+// Start runs the job right away and then every interval until ctx is done
+func (j *ExpirationJob) Start(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		// A failed run is logged and retried on the next tick
+		if _, err := j.Run(ctx); err != nil {
+			j.logger.Printf("expiration job: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run unpublishes the expired products once and returns them, in dry-run mode it only looks them up
+func (j *ExpirationJob) Run(ctx context.Context) ([]domain.Product, error) {
+	today := Today(j.now())
+
+	var (
+		changed []domain.Product
+		err     error
+	)
+	if j.dryRun {
+		// Look up the published products that expired before today
+		var expired []domain.Product
+		if expired, err = j.rp.GetByExpiration(ctx, domain.Date{}, today); err != nil {
+			return nil, err
+		}
+		for _, p := range expired {
+			if p.IsPublished {
+				changed = append(changed, p)
+			}
+		}
+	} else {
+		// Record every product unpublished within the write
+		hook := func(ctx context.Context, change Change) error {
+			return j.audit.Record(ctx, entity, change.After.Id, audit.ActionUpdate, change.Before, change.After)
+		}
+		if changed, err = j.rp.UnpublishExpired(WithHook(audit.SystemContext(ctx), hook), today); err != nil {
+			return nil, err
+		}
+	}
+
+	// Log every product changed
+	action := "unpublished"
+	if j.dryRun {
+		action = "would unpublish (dry run)"
+	}
+	for _, p := range changed {
+		j.logger.Printf("expiration job: %s product %d %q, expired %s", action, p.Id, p.CodeValue, p.Expiration)
+	}
+	return changed, nil
+}
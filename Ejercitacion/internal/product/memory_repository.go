@@ -0,0 +1,385 @@
+package product
+
+import (
+	"context"
+	"gostorage/internal/domain"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryRepository is a repository that implements the Repository interface keeping the products in memory
+type MemoryRepository struct {
+	// mu guards the products, the movements and the last IDs
+	mu sync.RWMutex
+	// products are the stored products indexed by their ID, the deleted ones included
+	products map[int]domain.Product
+	// lastID is the last ID assigned to a product
+	lastID int
+	// movements is the stock ledger in insertion order
+	movements []domain.StockMovement
+	// lastMovementID is the last ID assigned to a movement
+	lastMovementID int
+}
+
+// NewMemoryRepository creates a new empty MemoryRepository
+func NewMemoryRepository() Repository {
+	return &MemoryRepository{products: make(map[int]domain.Product)}
+}
+
+// GetAll returns all the products ordered by ID
+func (r *MemoryRepository) GetAll(ctx context.Context) ([]domain.Product, error) {
+	return r.getAll(false), nil
+}
+
+// GetAllWithDeleted returns all the products ordered by ID, the deleted ones included
+func (r *MemoryRepository) GetAllWithDeleted(ctx context.Context) ([]domain.Product, error) {
+	return r.getAll(true), nil
+}
+
+// getAll returns the products ordered by ID, with the deleted ones or not
+func (r *MemoryRepository) getAll(withDeleted bool) []domain.Product {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Copy the products into a slice
+	products := make([]domain.Product, 0, len(r.products))
+	for _, product := range r.products {
+		if withDeleted || product.DeletedAt == nil {
+			products = append(products, product)
+		}
+	}
+
+	// Sort the products by ID, as the MySQL repository does
+	sort.Slice(products, func(i, j int) bool {
+		return products[i].Id < products[j].Id
+	})
+
+	return products
+}
+
+// GetByID returns a product by its ID
+func (r *MemoryRepository) GetByID(ctx context.Context, id int) (domain.Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	product, ok := r.active(id)
+	if !ok {
+		return domain.Product{}, ErrRepositoryProductNotFound
+	}
+
+	return product, nil
+}
+
+// Create creates a new product
+func (r *MemoryRepository) Create(ctx context.Context, product *domain.Product) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Check the code value is unique, as the UNIQUE key does in MySQL
+	if r.codeValueTaken(0, product.CodeValue) {
+		return ErrRepositoryProductCodeValueDuplicated
+	}
+
+	// Set the ID and the version of the product and store it
+	saved := r.snapshot()
+	r.lastID++
+	product.Id = r.lastID
+	product.Version = 1
+	r.products[product.Id] = *product
+
+	// Record the initial stock
+	if product.Quantity != 0 {
+		r.addMovement(InitialReceipt(product.Id, product.Quantity))
+	}
+
+	return r.commit(ctx, saved, *product)
+}
+
+// Update updates a product
+func (r *MemoryRepository) Update(ctx context.Context, product *domain.Product) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Check if the product was not found
+	current, ok := r.active(product.Id)
+	if !ok {
+		return ErrRepositoryProductNotFound
+	}
+
+	// Compare and swap, the product must still be at the version the caller read
+	if current.Version != product.Version {
+		return ErrRepositoryVersionConflict
+	}
+
+	// Check the code value is not used by another product
+	if r.codeValueTaken(product.Id, product.CodeValue) {
+		return ErrRepositoryProductCodeValueDuplicated
+	}
+
+	saved := r.snapshot()
+	product.Version++
+	r.products[product.Id] = *product
+
+	// Record the change of quantity
+	if product.Quantity != current.Quantity {
+		r.addMovement(QuantityAdjustment(product.Id, current.Quantity, product.Quantity))
+	}
+
+	return r.commit(ctx, saved, *product)
+}
+
+// Delete deletes a product
+func (r *MemoryRepository) Delete(ctx context.Context, id int, version int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Check if the product was not found or is at another version
+	product, ok := r.active(id)
+	if !ok {
+		return ErrRepositoryProductNotFound
+	}
+	if version != 0 && product.Version != version {
+		return ErrRepositoryVersionConflict
+	}
+
+	// Mark it as deleted, its ledger stays until it is purged
+	saved := r.snapshot()
+	product.DeletedAt = DeletionTime()
+	product.Version++
+	r.products[id] = product
+
+	return r.commit(ctx, saved, product)
+}
+
+func (r *MemoryRepository) Restore(ctx context.Context, id int) (domain.Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Check the product exists and is deleted
+	product, ok := r.products[id]
+	if !ok {
+		return domain.Product{}, ErrRepositoryProductNotFound
+	}
+	if product.DeletedAt == nil {
+		return domain.Product{}, ErrRepositoryProductNotDeleted
+	}
+
+	// Check another product did not take its code value meanwhile
+	if r.codeValueTaken(id, product.CodeValue) {
+		return domain.Product{}, ErrRepositoryProductCodeValueDuplicated
+	}
+
+	saved := r.snapshot()
+	product.DeletedAt = nil
+	product.Version++
+	r.products[id] = product
+
+	if err := r.commit(ctx, saved, product); err != nil {
+		return domain.Product{}, err
+	}
+	return product, nil
+}
+
+func (r *MemoryRepository) Purge(ctx context.Context, before time.Time) ([]domain.Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Remove the products deleted before the time
+	saved := r.snapshot()
+	products := make([]domain.Product, 0)
+	for id, product := range r.products {
+		if DeletedBefore(product, before) {
+			delete(r.products, id)
+			products = append(products, product)
+		}
+	}
+
+	// Drop their ledgers, as the foreign key does in MySQL
+	movements := r.movements[:0]
+	for _, movement := range r.movements {
+		if _, ok := r.products[movement.ProductId]; ok {
+			movements = append(movements, movement)
+		}
+	}
+	r.movements = movements
+
+	// Return them ordered by ID, as the MySQL repository does
+	sort.Slice(products, func(i, j int) bool {
+		return products[i].Id < products[j].Id
+	})
+
+	if err := r.commit(ctx, saved, products...); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func (r *MemoryRepository) Exists(ctx context.Context, codeValue string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.codeValueTaken(0, codeValue), nil
+}
+
+func (r *MemoryRepository) ExistsWithDifferentID(ctx context.Context, id int, codeValue string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.codeValueTaken(id, codeValue), nil
+}
+
+// active returns the product with the ID unless it is missing or deleted, the caller holds the lock
+func (r *MemoryRepository) active(id int) (domain.Product, bool) {
+	product, ok := r.products[id]
+	if !ok || product.DeletedAt != nil {
+		return domain.Product{}, false
+	}
+	return product, true
+}
+
+// codeValueTaken reports whether a product other than id and not deleted uses codeValue, the caller holds the lock
+func (r *MemoryRepository) codeValueTaken(id int, codeValue string) bool {
+	for _, product := range r.products {
+		if product.CodeValue == codeValue && product.Id != id && product.DeletedAt == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *MemoryRepository) GetByExpiration(ctx context.Context, from, to domain.Date) ([]domain.Product, error) {
+	// Get the products ordered by ID and keep the ones in range
+	all, err := r.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	products := make([]domain.Product, 0)
+	for _, product := range all {
+		if ExpiresWithin(product, from, to) {
+			products = append(products, product)
+		}
+	}
+
+	return products, nil
+}
+
+func (r *MemoryRepository) UnpublishExpired(ctx context.Context, before domain.Date) ([]domain.Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Unpublish the published products expiring before the date
+	saved := r.snapshot()
+	products := make([]domain.Product, 0)
+	for id, product := range r.products {
+		if product.IsPublished && product.DeletedAt == nil && ExpiresWithin(product, domain.Date{}, before) {
+			product.IsPublished = false
+			product.Version++
+			r.products[id] = product
+			products = append(products, product)
+		}
+	}
+
+	// Return them ordered by ID, as the MySQL repository does
+	sort.Slice(products, func(i, j int) bool {
+		return products[i].Id < products[j].Id
+	})
+
+	if err := r.commit(ctx, saved, products...); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func (r *MemoryRepository) AddMovement(ctx context.Context, movement *domain.StockMovement) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Check the product exists
+	product, ok := r.active(movement.ProductId)
+	if !ok {
+		return ErrRepositoryProductNotFound
+	}
+
+	// Reject a movement leaving the stock below zero
+	if product.Quantity+movement.Quantity < 0 {
+		return ErrRepositoryNegativeStock
+	}
+	movement.Balance = product.Quantity + movement.Quantity
+	movement.CreatedAt = domain.MovementTime()
+
+	// Record the movement and update the quantity
+	saved := r.snapshot()
+	*movement = r.addMovement(*movement)
+	product.Quantity = movement.Balance
+	product.Version++
+	r.products[product.Id] = product
+
+	return r.commit(ctx, saved, product)
+}
+
+func (r *MemoryRepository) GetMovements(ctx context.Context, productID int) ([]domain.StockMovement, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Check the product exists
+	if _, ok := r.active(productID); !ok {
+		return nil, ErrRepositoryProductNotFound
+	}
+
+	// The ledger is kept in ID order
+	movements := make([]domain.StockMovement, 0)
+	for _, movement := range r.movements {
+		if movement.ProductId == productID {
+			movements = append(movements, movement)
+		}
+	}
+
+	return movements, nil
+}
+
+// addMovement sets the ID of the movement and appends it to the ledger, the caller holds the lock
+func (r *MemoryRepository) addMovement(movement domain.StockMovement) domain.StockMovement {
+	r.lastMovementID++
+	movement.Id = r.lastMovementID
+	r.movements = append(r.movements, movement)
+
+	return movement
+}
+
+// state is a copy of the repository taken before a write
+type state struct {
+	products       map[int]domain.Product
+	lastID         int
+	movements      []domain.StockMovement
+	lastMovementID int
+}
+
+// snapshot copies the state, so a write can be undone and its hook gets the products as they were. The caller holds the lock
+func (r *MemoryRepository) snapshot() state {
+	return state{maps.Clone(r.products), r.lastID, slices.Clone(r.movements), r.lastMovementID}
+}
+
+// commit runs the hook of ctx for the products the write changed, putting the state taken before the write back
+// when it fails. The caller holds the lock
+func (r *MemoryRepository) commit(ctx context.Context, saved state, products ...domain.Product) error {
+	changes := make([]Change, 0, len(products))
+	for _, product := range products {
+		change := Change{After: product}
+		if previous, ok := saved.products[product.Id]; ok {
+			change.Before = &previous
+		}
+		changes = append(changes, change)
+	}
+
+	if err := CallHook(ctx, changes...); err != nil {
+		r.products, r.lastID = saved.products, saved.lastID
+		r.movements, r.lastMovementID = saved.movements, saved.lastMovementID
+		return err
+	}
+	return nil
+}
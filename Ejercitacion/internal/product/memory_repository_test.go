@@ -0,0 +1,13 @@
+package product_test
+
+import (
+	"gostorage/internal/product"
+	"gostorage/internal/product/producttest"
+	"testing"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	producttest.TestRepository(t, func(t *testing.T) product.Repository {
+		return product.NewMemoryRepository()
+	})
+}
@@ -0,0 +1,99 @@
+package product
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gostorage/pkg"
+	"strings"
+)
+
+// ExpirationChange describes an expiration rewritten in the canonical layout
+type ExpirationChange struct {
+	// Id is the product ID
+	Id int
+	// From is the stored value
+	From string
+	// To is the canonical date
+	To string
+}
+
+// NormalizeMySQLExpirations converts a products.expiration text column, as older schemas had it,
+// into a DATE column parsing every known layout. A DATE column is already normalized and left untouched.
+// With dryRun it only reports the changes
+func NormalizeMySQLExpirations(ctx context.Context, db *sql.DB, dryRun bool) (changes []ExpirationChange, err error) {
+	// Check the current type of the column
+	var dataType string
+	query := `
+		SELECT DATA_TYPE FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'products' AND COLUMN_NAME = 'expiration'
+	`
+	if err = db.QueryRowContext(ctx, query).Scan(&dataType); err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(dataType, "date") {
+		return []ExpirationChange{}, nil
+	}
+
+	// Parse every stored value, all of them must be valid before anything is written
+	rows, err := db.QueryContext(ctx, `SELECT id, expiration FROM products ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes = make([]ExpirationChange, 0)
+	invalid := make([]string, 0)
+	for rows.Next() {
+		var id int
+		var value sql.NullString
+		if err = rows.Scan(&id, &value); err != nil {
+			return nil, err
+		}
+
+		date, err := pkg.ParseDateWith(value.String, pkg.KnownDateLayouts()...)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("%d (%q)", id, value.String))
+			continue
+		}
+		changes = append(changes, ExpirationChange{Id: id, From: value.String, To: date.Format(pkg.CanonicalDateLayout)})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(invalid) > 0 {
+		return nil, fmt.Errorf("%w in products %s", pkg.ErrInvalidDate, strings.Join(invalid, ", "))
+	}
+	if dryRun {
+		return changes, nil
+	}
+
+	// Fill a new DATE column and swap it for the text one
+	if _, err = db.ExecContext(ctx, `ALTER TABLE products ADD COLUMN expiration_date DATE NULL`); err != nil {
+		return nil, err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range changes {
+		if _, err = tx.ExecContext(ctx, `UPDATE products SET expiration_date = ? WHERE id = ?`, c.To, c.Id); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	for _, statement := range []string{
+		`ALTER TABLE products DROP COLUMN expiration`,
+		`ALTER TABLE products RENAME COLUMN expiration_date TO expiration`,
+		`ALTER TABLE products MODIFY expiration DATE NOT NULL`,
+	} {
+		if _, err = db.ExecContext(ctx, statement); err != nil {
+			return nil, err
+		}
+	}
+
+	return changes, nil
+}
@@ -3,7 +3,12 @@ package product
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"gostorage/internal/domain"
+	"gostorage/pkg/database"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 // MySQLRepository is a repository that implements the Repository interface
@@ -17,49 +22,56 @@ func NewRepository(db *sql.DB) Repository {
 	return &MySQLRepository{db}
 }
 
-// GetAll returns all the products
+// GetAll returns all the products ordered by ID
 func (rp *MySQLRepository) GetAll(ctx context.Context) (products []domain.Product, err error) {
 	// Create the query
 	query := `
-		SELECT id, name, quantity, code_value, is_published, expiration, price
+		SELECT id, name, quantity, code_value, is_published, expiration, price, version, deleted_at
 		FROM products
+		WHERE deleted_at IS NULL
+		ORDER BY id
 	`
 	// Execute the query
 	rows, err := rp.db.QueryContext(ctx, query)
 	if err != nil {
 		return
 	}
+	defer rows.Close()
 
-	// Iterate over the rows
-	for rows.Next() {
-		// Create a product
-		product := domain.Product{}
-
-		// Scan the row into the product
-		if err = rows.Scan(&product.Id, &product.Name, &product.Quantity, &product.CodeValue, &product.IsPublished, &product.Expiration, &product.Price); err != nil {
-			return
-		}
+	return scanProducts(rows)
+}
 
-		// Append the product to the products slice
-		products = append(products, product)
+// GetAllWithDeleted returns all the products ordered by ID, the deleted ones included
+func (rp *MySQLRepository) GetAllWithDeleted(ctx context.Context) (products []domain.Product, err error) {
+	// Create the query
+	query := `
+		SELECT id, name, quantity, code_value, is_published, expiration, price, version, deleted_at
+		FROM products
+		ORDER BY id
+	`
+	// Execute the query
+	rows, err := rp.db.QueryContext(ctx, query)
+	if err != nil {
+		return
 	}
+	defer rows.Close()
 
-	return
+	return scanProducts(rows)
 }
 
 // GetByID returns a product by its ID
 func (r *MySQLRepository) GetByID(ctx context.Context, id int) (product domain.Product, err error) {
 	// Create the query
 	query := `
-		SELECT id, name, quantity, code_value, is_published, expiration, price
-		FROM products WHERE id = ?
+		SELECT id, name, quantity, code_value, is_published, expiration, price, version, deleted_at
+		FROM products WHERE id = ? AND deleted_at IS NULL
 	`
 
 	// Execute the query
 	row := r.db.QueryRowContext(ctx, query, id)
 
 	// Scan the row into the product
-	if err = row.Scan(&product.Id, &product.Name, &product.Quantity, &product.CodeValue, &product.IsPublished, &product.Expiration, &product.Price); err != nil {
+	if product, err = scanProduct(row); err != nil {
 		switch err {
 		case sql.ErrNoRows:
 			return domain.Product{}, ErrRepositoryProductNotFound
@@ -73,22 +85,26 @@ func (r *MySQLRepository) GetByID(ctx context.Context, id int) (product domain.P
 
 // Create creates a new product
 func (r *MySQLRepository) Create(ctx context.Context, product *domain.Product) (err error) {
-	// Create the query
-	query := `
-		INSERT INTO products (name, quantity, code_value, is_published, expiration, price)
-		VALUES (?, ?, ?, ?, ?, ?)
-	`
-	// Create the statement
-	stmt, err := r.db.PrepareContext(ctx, query)
+	// The product and its initial receipt are inserted in the same transaction
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
 
+	// Create the query
+	query := `
+		INSERT INTO products (name, quantity, code_value, is_published, expiration, price, version)
+		VALUES (?, ?, ?, ?, ?, ?, 1)
+	`
 	// Execute the statement
-	result, err := stmt.ExecContext(ctx, product.Name, product.Quantity, product.CodeValue, product.IsPublished, product.Expiration, product.Price)
+	result, err := tx.ExecContext(ctx, query, product.Name, product.Quantity, product.CodeValue, product.IsPublished, product.Expiration, product.Price)
 	if err != nil {
-		return err
+		return duplicatedCodeValue(err)
 	}
 
 	// Get the ID of the newly inserted product
@@ -97,77 +113,141 @@ func (r *MySQLRepository) Create(ctx context.Context, product *domain.Product) (
 		return err
 	}
 
-	// Set the ID of the product
-	product.Id = int(id)
+	// Record the initial stock
+	if product.Quantity != 0 {
+		receipt := InitialReceipt(int(id), product.Quantity)
+		if err = insertMovement(ctx, tx, &receipt); err != nil {
+			return err
+		}
+	}
+
+	// Run the hook with the product as created
+	created := *product
+	created.Id, created.Version = int(id), 1
+	if err = CallHook(database.NewContext(ctx, tx), Change{After: created}); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	// Set the ID and the version of the product
+	product.Id = created.Id
+	product.Version = created.Version
 
 	return
 }
 
 // Update updates a product
 func (r *MySQLRepository) Update(ctx context.Context, product *domain.Product) (err error) {
-	// Create the query
+	// Lock the product, a change of quantity goes to the stock ledger in the same transaction
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
 	query := `
-		UPDATE products SET name = ?, quantity = ?, code_value = ?, is_published = ?, expiration = ?, price = ?
-		WHERE id = ?
+		SELECT id, name, quantity, code_value, is_published, expiration, price, version, deleted_at
+		FROM products WHERE id = ? AND deleted_at IS NULL FOR UPDATE
 	`
-	// Create the statement
-	stmt, err := r.db.PrepareContext(ctx, query)
+	current, err := scanProduct(tx.QueryRowContext(ctx, query, product.Id))
 	if err != nil {
+		if err == sql.ErrNoRows {
+			err = ErrRepositoryProductNotFound
+		}
 		return err
 	}
-	defer stmt.Close()
 
+	// Compare and swap, the product must still be at the version the caller read
+	if current.Version != product.Version {
+		return ErrRepositoryVersionConflict
+	}
+
+	// Create the query
+	query = `
+		UPDATE products SET name = ?, quantity = ?, code_value = ?, is_published = ?, expiration = ?, price = ?, version = version + 1
+		WHERE id = ? AND version = ?
+	`
 	// Execute the statement
-	result, err := stmt.ExecContext(ctx, product.Name, product.Quantity, product.CodeValue, product.IsPublished, product.Expiration, product.Price, product.Id)
+	_, err = tx.ExecContext(ctx, query, product.Name, product.Quantity, product.CodeValue, product.IsPublished, product.Expiration, product.Price, product.Id, product.Version)
 	if err != nil {
-		return err
+		return duplicatedCodeValue(err)
 	}
 
-	// Get the rows affected
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
+	// Record the change of quantity
+	if product.Quantity != current.Quantity {
+		adjustment := QuantityAdjustment(product.Id, current.Quantity, product.Quantity)
+		if err = insertMovement(ctx, tx, &adjustment); err != nil {
+			return err
+		}
+	}
+
+	// Run the hook with the product as updated
+	updated := *product
+	updated.Version++
+	if err = CallHook(database.NewContext(ctx, tx), Change{Before: &current, After: updated}); err != nil {
 		return err
 	}
 
-	// Check if the product was not found
-	if rowsAffected == 0 {
-		return ErrRepositoryProductNotFound
+	if err = tx.Commit(); err != nil {
+		return err
 	}
 
+	// Set the new version of the product
+	product.Version = updated.Version
+
 	return
 }
 
-// Delete deletes a product
-func (r *MySQLRepository) Delete(ctx context.Context, id int) (err error) {
-	// Create the query
-	query := `
-		DELETE FROM products WHERE id = ?
-	`
-	// Create the statement
-	stmt, err := r.db.PrepareContext(ctx, query)
+// Delete marks a product as deleted
+func (r *MySQLRepository) Delete(ctx context.Context, id int, version int) (err error) {
+	// Lock the product while it is marked as deleted
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
 
-	// Execute the statement
-	result, err := stmt.ExecContext(ctx, id)
+	query := `
+		SELECT id, name, quantity, code_value, is_published, expiration, price, version, deleted_at
+		FROM products WHERE id = ? AND deleted_at IS NULL FOR UPDATE
+	`
+	product, err := scanProduct(tx.QueryRowContext(ctx, query, id))
 	if err != nil {
+		if err == sql.ErrNoRows {
+			err = ErrRepositoryProductNotFound
+		}
 		return err
 	}
 
-	// Get the ID of the newly inserted product
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
+	// Check the product is at the version, a zero version matches any
+	if version != 0 && product.Version != version {
+		return ErrRepositoryVersionConflict
+	}
+
+	// Mark it as deleted, its ledger stays until it is purged
+	before := product
+	product.DeletedAt = DeletionTime()
+	product.Version++
+	if _, err = tx.ExecContext(ctx, `UPDATE products SET deleted_at = ?, version = ? WHERE id = ?`, product.DeletedAt.Format(time.DateTime), product.Version, id); err != nil {
 		return err
 	}
 
-	// Check if the product was not found
-	if rowsAffected == 0 {
-		return ErrRepositoryProductNotFound
+	if err = CallHook(database.NewContext(ctx, tx), Change{Before: &before, After: product}); err != nil {
+		return err
 	}
 
-	return
+	return tx.Commit()
 }
 
 func (r *MySQLRepository) Exists(ctx context.Context, codeValue string) (exists bool, err error) {
@@ -176,7 +256,7 @@ func (r *MySQLRepository) Exists(ctx context.Context, codeValue string) (exists
 		SELECT EXISTS (
 			SELECT code_value
 			FROM products
-			WHERE code_value = ?
+			WHERE code_value = ? AND deleted_at IS NULL
 		)
 	`
 
@@ -197,7 +277,7 @@ func (r *MySQLRepository) ExistsWithDifferentID(ctx context.Context, id int, cod
 		SELECT EXISTS (
 			SELECT code_value
 			FROM products
-			WHERE code_value = ? AND id != ?
+			WHERE code_value = ? AND id != ? AND deleted_at IS NULL
 		)
 	`
 
@@ -211,3 +291,327 @@ func (r *MySQLRepository) ExistsWithDifferentID(ctx context.Context, id int, cod
 
 	return
 }
+
+// duplicatedCodeValue maps the MySQL duplicate entry error raised by the code_value UNIQUE key
+func duplicatedCodeValue(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+		return ErrRepositoryProductCodeValueDuplicated
+	}
+
+	return err
+}
+
+// GetByExpiration returns the products expiring in the given range ordered by ID
+func (r *MySQLRepository) GetByExpiration(ctx context.Context, from, to domain.Date) (products []domain.Product, err error) {
+	// Create the query, a zero date leaves that end open
+	query := `
+		SELECT id, name, quantity, code_value, is_published, expiration, price, version, deleted_at
+		FROM products
+		WHERE deleted_at IS NULL AND (? IS NULL OR expiration >= ?) AND (? IS NULL OR expiration < ?)
+		ORDER BY id
+	`
+
+	// Execute the query
+	rows, err := r.db.QueryContext(ctx, query, from, from, to, to)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	return scanProducts(rows)
+}
+
+// UnpublishExpired unpublishes the published products expiring before the given date
+func (r *MySQLRepository) UnpublishExpired(ctx context.Context, before domain.Date) (products []domain.Product, err error) {
+	// Lock the expired products while they are unpublished
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	query := `
+		SELECT id, name, quantity, code_value, is_published, expiration, price, version, deleted_at
+		FROM products
+		WHERE is_published = 1 AND deleted_at IS NULL AND expiration < ?
+		ORDER BY id
+		FOR UPDATE
+	`
+	rows, err := tx.QueryContext(ctx, query, before)
+	if err != nil {
+		return
+	}
+	products, err = scanProducts(rows)
+	rows.Close()
+	if err != nil {
+		return
+	}
+
+	// Unpublish them one by one, so only the locked products change
+	changes := make([]Change, 0, len(products))
+	for i := range products {
+		if _, err = tx.ExecContext(ctx, `UPDATE products SET is_published = 0, version = version + 1 WHERE id = ?`, products[i].Id); err != nil {
+			return
+		}
+		before := products[i]
+		products[i].IsPublished = false
+		products[i].Version++
+		changes = append(changes, Change{Before: &before, After: products[i]})
+	}
+
+	if err = CallHook(database.NewContext(ctx, tx), changes...); err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
+
+// scanProducts scans every row into a product
+func scanProducts(rows *sql.Rows) (products []domain.Product, err error) {
+	products = make([]domain.Product, 0)
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+	err = rows.Err()
+	return
+}
+
+// scanProduct scans a row with the product columns into a product
+func scanProduct(row interface{ Scan(dest ...any) error }) (product domain.Product, err error) {
+	// The deletion time comes as text without parseTime, NULL while the product is not deleted
+	var deletedAt sql.NullString
+	if err = row.Scan(&product.Id, &product.Name, &product.Quantity, &product.CodeValue, &product.IsPublished, &product.Expiration, &product.Price, &product.Version, &deletedAt); err != nil {
+		return domain.Product{}, err
+	}
+	if deletedAt.Valid {
+		t, err := time.Parse(time.DateTime, deletedAt.String)
+		if err != nil {
+			return domain.Product{}, err
+		}
+		product.DeletedAt = &t
+	}
+	return
+}
+
+// Restore undeletes a product
+func (r *MySQLRepository) Restore(ctx context.Context, id int) (product domain.Product, err error) {
+	// Lock the product while it is restored
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	query := `
+		SELECT id, name, quantity, code_value, is_published, expiration, price, version, deleted_at
+		FROM products WHERE id = ? FOR UPDATE
+	`
+	if product, err = scanProduct(tx.QueryRowContext(ctx, query, id)); err != nil {
+		if err == sql.ErrNoRows {
+			err = ErrRepositoryProductNotFound
+		}
+		return domain.Product{}, err
+	}
+	if product.DeletedAt == nil {
+		return domain.Product{}, ErrRepositoryProductNotDeleted
+	}
+
+	// The code value may belong to another product by now
+	var taken bool
+	query = `SELECT EXISTS(SELECT 1 FROM products WHERE code_value = ? AND id <> ? AND deleted_at IS NULL FOR UPDATE)`
+	if err = tx.QueryRowContext(ctx, query, product.CodeValue, id).Scan(&taken); err != nil {
+		return domain.Product{}, err
+	}
+	if taken {
+		return domain.Product{}, ErrRepositoryProductCodeValueDuplicated
+	}
+
+	// The UNIQUE key still rejects a product created with the code value in the meantime
+	if _, err = tx.ExecContext(ctx, `UPDATE products SET deleted_at = NULL, version = version + 1 WHERE id = ?`, id); err != nil {
+		return domain.Product{}, duplicatedCodeValue(err)
+	}
+	before := product
+	product.DeletedAt = nil
+	product.Version++
+
+	if err = CallHook(database.NewContext(ctx, tx), Change{Before: &before, After: product}); err != nil {
+		return domain.Product{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return domain.Product{}, err
+	}
+
+	return
+}
+
+// Purge removes for good the products deleted before the given time, the foreign key removes their ledgers
+func (r *MySQLRepository) Purge(ctx context.Context, before time.Time) (products []domain.Product, err error) {
+	// Lock the products to purge while they are removed
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	query := `
+		SELECT id, name, quantity, code_value, is_published, expiration, price, version, deleted_at
+		FROM products
+		WHERE deleted_at < ?
+		ORDER BY id
+		FOR UPDATE
+	`
+	rows, err := tx.QueryContext(ctx, query, before.UTC().Format(time.DateTime))
+	if err != nil {
+		return
+	}
+	products, err = scanProducts(rows)
+	rows.Close()
+	if err != nil {
+		return
+	}
+
+	// Remove them one by one, so only the locked products go
+	changes := make([]Change, 0, len(products))
+	for _, product := range products {
+		if _, err = tx.ExecContext(ctx, `DELETE FROM products WHERE id = ?`, product.Id); err != nil {
+			return
+		}
+		purged := product
+		changes = append(changes, Change{Before: &purged, After: purged})
+	}
+
+	if err = CallHook(database.NewContext(ctx, tx), changes...); err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
+
+// AddMovement records the movement and applies it to the product quantity
+func (r *MySQLRepository) AddMovement(ctx context.Context, movement *domain.StockMovement) (err error) {
+	// Lock the product while its quantity changes
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	query := `
+		SELECT id, name, quantity, code_value, is_published, expiration, price, version, deleted_at
+		FROM products WHERE id = ? AND deleted_at IS NULL FOR UPDATE
+	`
+	product, err := scanProduct(tx.QueryRowContext(ctx, query, movement.ProductId))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = ErrRepositoryProductNotFound
+		}
+		return err
+	}
+
+	// Reject a movement leaving the stock below zero
+	if product.Quantity+movement.Quantity < 0 {
+		return ErrRepositoryNegativeStock
+	}
+	movement.Balance = product.Quantity + movement.Quantity
+	movement.CreatedAt = domain.MovementTime()
+
+	// Record the movement and update the quantity
+	if err = insertMovement(ctx, tx, movement); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE products SET quantity = ?, version = version + 1 WHERE id = ?`, movement.Balance, movement.ProductId); err != nil {
+		return err
+	}
+	before := product
+	product.Quantity = movement.Balance
+	product.Version++
+
+	if err = CallHook(database.NewContext(ctx, tx), Change{Before: &before, After: product}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetMovements returns the stock ledger of a product
+func (r *MySQLRepository) GetMovements(ctx context.Context, productID int) (movements []domain.StockMovement, err error) {
+	// Check the product exists
+	if _, err = r.GetByID(ctx, productID); err != nil {
+		return nil, err
+	}
+
+	// Create the query
+	query := `
+		SELECT id, product_id, type, quantity, balance, note, created_at
+		FROM stock_movements
+		WHERE product_id = ?
+		ORDER BY id
+	`
+	// Execute the query
+	rows, err := r.db.QueryContext(ctx, query, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movements = make([]domain.StockMovement, 0)
+	for rows.Next() {
+		// Scan the row into a movement, the time comes as text without parseTime
+		movement := domain.StockMovement{}
+		var createdAt string
+		if err = rows.Scan(&movement.Id, &movement.ProductId, &movement.Type, &movement.Quantity, &movement.Balance, &movement.Note, &createdAt); err != nil {
+			return nil, err
+		}
+		if movement.CreatedAt, err = time.Parse(time.DateTime, createdAt); err != nil {
+			return nil, err
+		}
+		movements = append(movements, movement)
+	}
+	err = rows.Err()
+
+	return
+}
+
+// insertMovement inserts the movement within the transaction and sets its ID
+func insertMovement(ctx context.Context, tx *sql.Tx, movement *domain.StockMovement) error {
+	query := `
+		INSERT INTO stock_movements (product_id, type, quantity, balance, note, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	result, err := tx.ExecContext(ctx, query, movement.ProductId, movement.Type, movement.Quantity, movement.Balance, movement.Note, movement.CreatedAt.Format(time.DateTime))
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	movement.Id = int(id)
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package product_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gostorage/internal/product"
+	"gostorage/internal/product/producttest"
+	"gostorage/migrations"
+	"gostorage/pkg/migrate"
+	"io/fs"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// TestMySQLRepository runs against the server of TEST_MYSQL_DATA_SOURCE, in a database created
+// for each test and dropped after it
+func TestMySQLRepository(t *testing.T) {
+	dataSource := os.Getenv("TEST_MYSQL_DATA_SOURCE")
+	if dataSource == "" {
+		t.Skip("TEST_MYSQL_DATA_SOURCE is not set")
+	}
+	cfg, err := mysql.ParseDSN(dataSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := sql.Open("mysql", dataSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	files, err := fs.Sub(migrations.MySQL, "mysql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	producttest.TestRepository(t, func(t *testing.T) product.Repository {
+		name := fmt.Sprintf("contract_%d", time.Now().UnixNano())
+		if _, err := server.Exec("CREATE DATABASE " + name); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { server.Exec("DROP DATABASE " + name) })
+
+		cfg := cfg.Clone()
+		cfg.DBName = name
+		db, err := sql.Open("mysql", cfg.FormatDSN())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		// Apply every migration to the new database
+		migrator, err := migrate.New(db, files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := migrator.Up(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		return product.NewRepository(db)
+	})
+}
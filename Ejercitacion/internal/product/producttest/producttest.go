@@ -0,0 +1,576 @@
+// Package producttest holds the behaviour every product.Repository must share,
+// so each implementation can be checked against the same expectations.
+package producttest
+
+import (
+	"context"
+	"errors"
+	"gostorage/internal/domain"
+	"gostorage/internal/product"
+	"testing"
+	"time"
+)
+
+// TestRepository runs the contract against repositories built by newRepo, which must
+// return an empty repository on every call
+func TestRepository(t *testing.T, newRepo func(t *testing.T) product.Repository) {
+	tests := map[string]func(t *testing.T, rp product.Repository){
+		"create and get":       testCreateAndGet,
+		"get all":              testGetAll,
+		"update":               testUpdate,
+		"delete":               testDelete,
+		"code value exists":    testExists,
+		"code value is unique": testUniqueCodeValue,
+		"get by expiration":    testGetByExpiration,
+		"unpublish expired":    testUnpublishExpired,
+		"stock movements":      testMovements,
+		"negative stock":       testNegativeStock,
+		"versions":             testVersions,
+		"soft delete":          testSoftDelete,
+		"hook":                 testHook,
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			test(t, newRepo(t))
+		})
+	}
+}
+
+func testCreateAndGet(t *testing.T, rp product.Repository) {
+	ctx := context.Background()
+
+	// Create a product and check it gets an ID
+	p := newProduct("A1")
+	if err := rp.Create(ctx, &p); err != nil || p.Id < 1 {
+		t.Fatalf("create: id %d, err %v", p.Id, err)
+	}
+
+	// Get it back
+	got, err := rp.GetByID(ctx, p.Id)
+	if err != nil || got != p {
+		t.Fatalf("get: got %+v, %v, want %+v", got, err, p)
+	}
+
+	// A missing product is not found
+	if _, err := rp.GetByID(ctx, p.Id+1); !errors.Is(err, product.ErrRepositoryProductNotFound) {
+		t.Fatalf("get missing: got %v", err)
+	}
+}
+
+func testGetAll(t *testing.T, rp product.Repository) {
+	ctx := context.Background()
+
+	// An empty repository returns an empty slice
+	products, err := rp.GetAll(ctx)
+	if err != nil || products == nil || len(products) != 0 {
+		t.Fatalf("get all empty: got %v, %v", products, err)
+	}
+
+	// Products come back ordered by ID
+	for _, code := range []string{"C", "A", "B"} {
+		p := newProduct(code)
+		if err := rp.Create(ctx, &p); err != nil {
+			t.Fatalf("create %s: %v", code, err)
+		}
+	}
+	products, err = rp.GetAll(ctx)
+	if err != nil || len(products) != 3 {
+		t.Fatalf("get all: got %v, %v", products, err)
+	}
+	for i, code := range []string{"C", "A", "B"} {
+		if products[i].CodeValue != code || (i > 0 && products[i].Id <= products[i-1].Id) {
+			t.Fatalf("get all order: got %+v", products)
+		}
+	}
+}
+
+func testUpdate(t *testing.T, rp product.Repository) {
+	ctx := context.Background()
+
+	p := newProduct("A1")
+	if err := rp.Create(ctx, &p); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	// Update the product and read the change back
+	p.Name = "Renamed"
+	p.IsPublished = false
+	if err := rp.Update(ctx, &p); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if got, err := rp.GetByID(ctx, p.Id); err != nil || got != p {
+		t.Fatalf("get updated: got %+v, %v, want %+v", got, err, p)
+	}
+
+	// Updating with the same values is not a missing product
+	if err := rp.Update(ctx, &p); err != nil {
+		t.Fatalf("update unchanged: %v", err)
+	}
+
+	// Updating a missing product fails
+	p.Id++
+	if err := rp.Update(ctx, &p); !errors.Is(err, product.ErrRepositoryProductNotFound) {
+		t.Fatalf("update missing: got %v", err)
+	}
+}
+
+func testDelete(t *testing.T, rp product.Repository) {
+	ctx := context.Background()
+
+	p := newProduct("A1")
+	if err := rp.Create(ctx, &p); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	if err := rp.Delete(ctx, p.Id, 0); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := rp.GetByID(ctx, p.Id); !errors.Is(err, product.ErrRepositoryProductNotFound) {
+		t.Fatalf("get deleted: got %v", err)
+	}
+	if err := rp.Delete(ctx, p.Id, 0); !errors.Is(err, product.ErrRepositoryProductNotFound) {
+		t.Fatalf("delete deleted: got %v", err)
+	}
+}
+
+func testExists(t *testing.T, rp product.Repository) {
+	ctx := context.Background()
+
+	p := newProduct("A1")
+	if err := rp.Create(ctx, &p); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	if exists, err := rp.Exists(ctx, "A1"); err != nil || !exists {
+		t.Fatalf("exists: got %t, %v", exists, err)
+	}
+	if exists, err := rp.Exists(ctx, "B1"); err != nil || exists {
+		t.Fatalf("exists unknown: got %t, %v", exists, err)
+	}
+	if exists, err := rp.ExistsWithDifferentID(ctx, p.Id, "A1"); err != nil || exists {
+		t.Fatalf("exists with same id: got %t, %v", exists, err)
+	}
+	if exists, err := rp.ExistsWithDifferentID(ctx, p.Id+1, "A1"); err != nil || !exists {
+		t.Fatalf("exists with different id: got %t, %v", exists, err)
+	}
+}
+
+func testUniqueCodeValue(t *testing.T, rp product.Repository) {
+	ctx := context.Background()
+
+	a, b := newProduct("A1"), newProduct("B1")
+	if err := rp.Create(ctx, &a); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := rp.Create(ctx, &b); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	// The repository enforces the unique code value on its own
+	duplicated := newProduct("A1")
+	if err := rp.Create(ctx, &duplicated); !errors.Is(err, product.ErrRepositoryProductCodeValueDuplicated) {
+		t.Fatalf("create duplicated: got %v", err)
+	}
+	b.CodeValue = "A1"
+	if err := rp.Update(ctx, &b); !errors.Is(err, product.ErrRepositoryProductCodeValueDuplicated) {
+		t.Fatalf("update duplicated: got %v", err)
+	}
+}
+
+func testGetByExpiration(t *testing.T, rp product.Repository) {
+	ctx := context.Background()
+
+	// Create products expiring on consecutive days
+	for i, code := range []string{"D1", "D2", "D3"} {
+		p := newProduct(code)
+		p.Expiration = domain.NewDate(2030, time.January, 1+i)
+		if err := rp.Create(ctx, &p); err != nil {
+			t.Fatalf("create %s: %v", code, err)
+		}
+	}
+
+	cases := []struct {
+		from, to domain.Date
+		want     []string
+	}{
+		{domain.Date{}, domain.Date{}, []string{"D1", "D2", "D3"}},
+		{domain.Date{}, domain.NewDate(2030, time.January, 2), []string{"D1"}},
+		{domain.NewDate(2030, time.January, 2), domain.Date{}, []string{"D2", "D3"}},
+		{domain.NewDate(2030, time.January, 2), domain.NewDate(2030, time.January, 3), []string{"D2"}},
+		{domain.NewDate(2031, time.January, 1), domain.Date{}, []string{}},
+	}
+	for _, c := range cases {
+		products, err := rp.GetByExpiration(ctx, c.from, c.to)
+		if err != nil || products == nil || len(products) != len(c.want) {
+			t.Fatalf("get by expiration [%s, %s): got %v, %v, want %v", c.from, c.to, products, err, c.want)
+		}
+		for i, code := range c.want {
+			if products[i].CodeValue != code {
+				t.Fatalf("get by expiration [%s, %s): got %v, want %v", c.from, c.to, products, c.want)
+			}
+		}
+	}
+}
+
+func testUnpublishExpired(t *testing.T, rp product.Repository) {
+	ctx := context.Background()
+
+	// An expired published product, an expired unpublished one and one still valid
+	expired, hidden, valid := newProduct("E1"), newProduct("E2"), newProduct("E3")
+	expired.Expiration = domain.NewDate(2020, time.January, 1)
+	hidden.Expiration, hidden.IsPublished = domain.NewDate(2020, time.January, 1), false
+	for _, p := range []*domain.Product{&expired, &hidden, &valid} {
+		if err := rp.Create(ctx, p); err != nil {
+			t.Fatalf("create %s: %v", p.CodeValue, err)
+		}
+	}
+
+	// Only the expired published product changes
+	changed, err := rp.UnpublishExpired(ctx, domain.NewDate(2025, time.January, 1))
+	if err != nil || len(changed) != 1 || changed[0].Id != expired.Id || changed[0].IsPublished {
+		t.Fatalf("unpublish expired: got %+v, %v", changed, err)
+	}
+	if got, _ := rp.GetByID(ctx, expired.Id); got.IsPublished {
+		t.Fatalf("expired product still published: %+v", got)
+	}
+	if got, _ := rp.GetByID(ctx, valid.Id); !got.IsPublished {
+		t.Fatalf("valid product unpublished: %+v", got)
+	}
+
+	// A second run has nothing left to do
+	changed, err = rp.UnpublishExpired(ctx, domain.NewDate(2025, time.January, 1))
+	if err != nil || len(changed) != 0 {
+		t.Fatalf("unpublish expired again: got %+v, %v", changed, err)
+	}
+}
+
+func testMovements(t *testing.T, rp product.Repository) {
+	ctx := context.Background()
+
+	// The initial quantity is recorded as a receipt
+	p := newProduct("M1")
+	if err := rp.Create(ctx, &p); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	// A sale lowers the stock and carries the balance
+	sale := domain.StockMovement{ProductId: p.Id, Type: domain.MovementSale, Quantity: -4, Note: "order 1"}
+	if err := rp.AddMovement(ctx, &sale); err != nil || sale.Id < 1 || sale.Balance != 6 || sale.CreatedAt.IsZero() {
+		t.Fatalf("add movement: got %+v, %v", sale, err)
+	}
+	if got, _ := rp.GetByID(ctx, p.Id); got.Quantity != 6 {
+		t.Fatalf("quantity after sale: got %d, want 6", got.Quantity)
+	}
+
+	// Updating the quantity is recorded as an adjustment
+	p, _ = rp.GetByID(ctx, p.Id)
+	p.Quantity = 8
+	if err := rp.Update(ctx, &p); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	// The ledger is returned in order with running balances
+	movements, err := rp.GetMovements(ctx, p.Id)
+	if err != nil || len(movements) != 3 {
+		t.Fatalf("get movements: got %+v, %v", movements, err)
+	}
+	want := []struct {
+		movementType      domain.MovementType
+		quantity, balance int
+	}{
+		{domain.MovementReceipt, 10, 10},
+		{domain.MovementSale, -4, 6},
+		{domain.MovementAdjustment, 2, 8},
+	}
+	for i, w := range want {
+		m := movements[i]
+		if m.ProductId != p.Id || m.Type != w.movementType || m.Quantity != w.quantity || m.Balance != w.balance {
+			t.Fatalf("movement %d: got %+v, want %+v", i, m, w)
+		}
+	}
+	if movements[1] != sale {
+		t.Fatalf("sale: got %+v, want %+v", movements[1], sale)
+	}
+
+	// A missing product has no ledger
+	if _, err := rp.GetMovements(ctx, p.Id+1); !errors.Is(err, product.ErrRepositoryProductNotFound) {
+		t.Fatalf("get movements of missing: got %v", err)
+	}
+	missing := domain.StockMovement{ProductId: p.Id + 1, Type: domain.MovementReceipt, Quantity: 1}
+	if err := rp.AddMovement(ctx, &missing); !errors.Is(err, product.ErrRepositoryProductNotFound) {
+		t.Fatalf("add movement to missing: got %v", err)
+	}
+
+	// Deleting the product drops its ledger
+	if err := rp.Delete(ctx, p.Id, 0); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := rp.GetMovements(ctx, p.Id); !errors.Is(err, product.ErrRepositoryProductNotFound) {
+		t.Fatalf("get movements of deleted: got %v", err)
+	}
+}
+
+func testNegativeStock(t *testing.T, rp product.Repository) {
+	ctx := context.Background()
+
+	p := newProduct("N1")
+	if err := rp.Create(ctx, &p); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	// Taking more than the stock is rejected and changes nothing
+	writeOff := domain.StockMovement{ProductId: p.Id, Type: domain.MovementWriteOff, Quantity: -11}
+	if err := rp.AddMovement(ctx, &writeOff); !errors.Is(err, product.ErrRepositoryNegativeStock) {
+		t.Fatalf("add movement: got %v", err)
+	}
+	if got, _ := rp.GetByID(ctx, p.Id); got.Quantity != 10 {
+		t.Fatalf("quantity: got %d, want 10", got.Quantity)
+	}
+	if movements, _ := rp.GetMovements(ctx, p.Id); len(movements) != 1 {
+		t.Fatalf("movements: got %+v, want only the receipt", movements)
+	}
+
+	// Taking exactly the stock leaves it at zero
+	writeOff = domain.StockMovement{ProductId: p.Id, Type: domain.MovementWriteOff, Quantity: -10}
+	if err := rp.AddMovement(ctx, &writeOff); err != nil || writeOff.Balance != 0 {
+		t.Fatalf("add movement: got %+v, %v", writeOff, err)
+	}
+}
+
+func testVersions(t *testing.T, rp product.Repository) {
+	ctx := context.Background()
+
+	// A new product starts at version 1
+	p := newProduct("V1")
+	if err := rp.Create(ctx, &p); err != nil || p.Version != 1 {
+		t.Fatalf("create: version %d, err %v", p.Version, err)
+	}
+
+	// An update at the current version bumps it
+	stale := p
+	p.Price = 20
+	if err := rp.Update(ctx, &p); err != nil || p.Version != 2 {
+		t.Fatalf("update: version %d, err %v", p.Version, err)
+	}
+
+	// An update at an old version is a conflict and changes nothing
+	stale.Name = "Lost update"
+	if err := rp.Update(ctx, &stale); !errors.Is(err, product.ErrRepositoryVersionConflict) {
+		t.Fatalf("update stale: got %v", err)
+	}
+	if got, _ := rp.GetByID(ctx, p.Id); got != p {
+		t.Fatalf("after stale update: got %+v, want %+v", got, p)
+	}
+
+	// Movements and unpublishing change the product, so they bump the version too
+	movement := domain.StockMovement{ProductId: p.Id, Type: domain.MovementReceipt, Quantity: 1}
+	if err := rp.AddMovement(ctx, &movement); err != nil {
+		t.Fatalf("add movement: %v", err)
+	}
+	if got, _ := rp.GetByID(ctx, p.Id); got.Version != 3 {
+		t.Fatalf("version after movement: got %d, want 3", got.Version)
+	}
+	expired := newProduct("V2")
+	expired.Expiration = domain.NewDate(2020, time.January, 1)
+	if err := rp.Create(ctx, &expired); err != nil {
+		t.Fatalf("create expired: %v", err)
+	}
+	if changed, err := rp.UnpublishExpired(ctx, domain.NewDate(2025, time.January, 1)); err != nil || len(changed) != 1 || changed[0].Version != 2 {
+		t.Fatalf("unpublish expired: got %+v, %v", changed, err)
+	}
+	if got, _ := rp.GetByID(ctx, expired.Id); got.Version != 2 {
+		t.Fatalf("version after unpublishing: got %d, want 2", got.Version)
+	}
+
+	// A delete at another version is a conflict, at the current one it deletes
+	if err := rp.Delete(ctx, p.Id, 2); !errors.Is(err, product.ErrRepositoryVersionConflict) {
+		t.Fatalf("delete stale: got %v", err)
+	}
+	if err := rp.Delete(ctx, p.Id, 3); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := rp.Delete(ctx, p.Id, 3); !errors.Is(err, product.ErrRepositoryProductNotFound) {
+		t.Fatalf("delete deleted: got %v", err)
+	}
+}
+
+func testSoftDelete(t *testing.T, rp product.Repository) {
+	ctx := context.Background()
+
+	p := newProduct("S1")
+	if err := rp.Create(ctx, &p); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	movement := domain.StockMovement{ProductId: p.Id, Type: domain.MovementReceipt, Quantity: 5}
+	if err := rp.AddMovement(ctx, &movement); err != nil {
+		t.Fatalf("add movement: %v", err)
+	}
+
+	// A deleted product is hidden but kept, with its deletion time and a new version
+	if err := rp.Delete(ctx, p.Id, 0); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if all, err := rp.GetAll(ctx); err != nil || len(all) != 0 {
+		t.Fatalf("get all: got %+v, %v", all, err)
+	}
+	if exists, err := rp.Exists(ctx, "S1"); err != nil || exists {
+		t.Fatalf("exists deleted: got %t, %v", exists, err)
+	}
+	all, err := rp.GetAllWithDeleted(ctx)
+	if err != nil || len(all) != 1 || all[0].DeletedAt == nil || all[0].Version != 3 {
+		t.Fatalf("get all with deleted: got %+v, %v", all, err)
+	}
+
+	// Restoring an active product fails
+	other := newProduct("S2")
+	if err := rp.Create(ctx, &other); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := rp.Restore(ctx, other.Id); !errors.Is(err, product.ErrRepositoryProductNotDeleted) {
+		t.Fatalf("restore active: got %v", err)
+	}
+	if _, err := rp.Restore(ctx, other.Id+100); !errors.Is(err, product.ErrRepositoryProductNotFound) {
+		t.Fatalf("restore unknown: got %v", err)
+	}
+
+	// The code value of a deleted product is free, so restoring it conflicts while it is taken
+	reused := newProduct("S1")
+	if err := rp.Create(ctx, &reused); err != nil {
+		t.Fatalf("create with deleted code value: %v", err)
+	}
+	if _, err := rp.Restore(ctx, p.Id); !errors.Is(err, product.ErrRepositoryProductCodeValueDuplicated) {
+		t.Fatalf("restore taken code value: got %v", err)
+	}
+	if err := rp.Delete(ctx, reused.Id, 0); err != nil {
+		t.Fatalf("delete reused: %v", err)
+	}
+
+	// Once the code value is free again the product comes back with its stock
+	restored, err := rp.Restore(ctx, p.Id)
+	if err != nil || restored.DeletedAt != nil || restored.Version != 4 || restored.Quantity != p.Quantity+5 {
+		t.Fatalf("restore: got %+v, %v", restored, err)
+	}
+	if got, err := rp.GetByID(ctx, p.Id); err != nil || got != restored {
+		t.Fatalf("get restored: got %+v, %v", got, err)
+	}
+
+	// Purging removes only the products deleted before the cutoff, with their movements
+	if purged, err := rp.Purge(ctx, time.Now().Add(-time.Hour)); err != nil || len(purged) != 0 {
+		t.Fatalf("purge before deletion: got %+v, %v", purged, err)
+	}
+	purged, err := rp.Purge(ctx, time.Now().Add(time.Hour))
+	if err != nil || len(purged) != 1 || purged[0].Id != reused.Id {
+		t.Fatalf("purge: got %+v, %v", purged, err)
+	}
+	if all, err := rp.GetAllWithDeleted(ctx); err != nil || len(all) != 2 {
+		t.Fatalf("get all with deleted after purge: got %+v, %v", all, err)
+	}
+	if _, err := rp.Restore(ctx, reused.Id); !errors.Is(err, product.ErrRepositoryProductNotFound) {
+		t.Fatalf("restore purged: got %v", err)
+	}
+	if err := rp.Delete(ctx, p.Id, 0); err != nil {
+		t.Fatalf("delete restored: %v", err)
+	}
+	if _, err := rp.Purge(ctx, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("purge restored: %v", err)
+	}
+	if _, err := rp.GetMovements(ctx, p.Id); !errors.Is(err, product.ErrRepositoryProductNotFound) {
+		t.Fatalf("movements of purged: got %v", err)
+	}
+}
+
+func testHook(t *testing.T, rp product.Repository) {
+	ctx := context.Background()
+	errHook := errors.New("hook failed")
+
+	// The hook sees every write with the product as it was and as written
+	var seen []product.Change
+	recording := product.WithHook(ctx, func(ctx context.Context, change product.Change) error {
+		seen = append(seen, change)
+		return nil
+	})
+	p := newProduct("H1")
+	if err := rp.Create(recording, &p); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	movement := domain.StockMovement{ProductId: p.Id, Type: domain.MovementSale, Quantity: -4}
+	if err := rp.AddMovement(recording, &movement); err != nil {
+		t.Fatalf("add movement: %v", err)
+	}
+	if len(seen) != 2 || seen[0].Before != nil || seen[0].After != p {
+		t.Fatalf("hook of create: got %+v", seen)
+	}
+	if seen[1].Before == nil || seen[1].Before.Quantity != 10 || seen[1].After.Quantity != 6 || seen[1].After.Version != 2 {
+		t.Fatalf("hook of add movement: got %+v", seen[1])
+	}
+
+	// A failing hook undoes the write and its error is returned
+	failing := product.WithHook(ctx, func(ctx context.Context, change product.Change) error {
+		return errHook
+	})
+	created := newProduct("H2")
+	if err := rp.Create(failing, &created); !errors.Is(err, errHook) {
+		t.Fatalf("create with failing hook: got %v", err)
+	}
+	if exists, err := rp.Exists(ctx, "H2"); err != nil || exists {
+		t.Fatalf("exists after failing hook: got %v, %v", exists, err)
+	}
+	updated := p
+	updated.Version, updated.Quantity = 2, 1
+	if err := rp.Update(failing, &updated); !errors.Is(err, errHook) {
+		t.Fatalf("update with failing hook: got %v", err)
+	}
+	if err := rp.AddMovement(failing, &domain.StockMovement{ProductId: p.Id, Type: domain.MovementReceipt, Quantity: 3}); !errors.Is(err, errHook) {
+		t.Fatalf("add movement with failing hook: got %v", err)
+	}
+	if err := rp.Delete(failing, p.Id, 0); !errors.Is(err, errHook) {
+		t.Fatalf("delete with failing hook: got %v", err)
+	}
+	got, err := rp.GetByID(ctx, p.Id)
+	if err != nil || got.Quantity != 6 || got.Version != 2 {
+		t.Fatalf("get after failing hooks: got %+v, %v", got, err)
+	}
+	if movements, err := rp.GetMovements(ctx, p.Id); err != nil || len(movements) != 2 {
+		t.Fatalf("movements after failing hooks: got %+v, %v", movements, err)
+	}
+
+	// Update, delete and restore see the product as it was within the write
+	seen = nil
+	updated = got
+	updated.Name = "Renamed"
+	if err := rp.Update(recording, &updated); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := rp.Delete(recording, p.Id, 0); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := rp.Restore(recording, p.Id); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if len(seen) != 3 {
+		t.Fatalf("hooks of update, delete and restore: got %+v", seen)
+	}
+	if seen[0].Before == nil || seen[0].Before.Name != got.Name || seen[0].After.Name != "Renamed" {
+		t.Fatalf("hook of update: got %+v", seen[0])
+	}
+	if seen[1].Before == nil || seen[1].Before.DeletedAt != nil || seen[1].After.DeletedAt == nil {
+		t.Fatalf("hook of delete: got %+v", seen[1])
+	}
+	if seen[2].Before == nil || seen[2].Before.DeletedAt == nil || seen[2].After.DeletedAt != nil {
+		t.Fatalf("hook of restore: got %+v", seen[2])
+	}
+}
+
+// newProduct returns a valid product with the given code value
+func newProduct(codeValue string) domain.Product {
+	return domain.Product{
+		Name:        "Product " + codeValue,
+		Quantity:    10,
+		CodeValue:   codeValue,
+		IsPublished: true,
+		Expiration:  domain.NewDate(2030, time.January, 31),
+		Price:       12.5,
+	}
+}
@@ -0,0 +1,17 @@
+package product
+
+import (
+	"context"
+	"gostorage/internal/audit"
+	"gostorage/internal/domain"
+	"time"
+)
+
+// Purge removes for good the products deleted before the given time and returns them, recording each one
+// within the write as purged by the system
+func Purge(ctx context.Context, rp Repository, a audit.Recorder, before time.Time) ([]domain.Product, error) {
+	hook := func(ctx context.Context, change Change) error {
+		return a.Record(ctx, entity, change.After.Id, audit.ActionPurge, change.Before, nil)
+	}
+	return rp.Purge(WithHook(audit.SystemContext(ctx), hook), before)
+}
@@ -3,6 +3,7 @@ package product
 import (
 	"context"
 	"errors"
+	"time"
 
 	"gostorage/internal/domain"
 )
@@ -10,22 +11,143 @@ import (
 var (
 	// ErrNotFound is returned when a product is not found
 	ErrRepositoryProductNotFound = errors.New("product not found")
+	// ErrRepositoryProductCodeValueDuplicated is returned when another product already has the code value
+	ErrRepositoryProductCodeValueDuplicated = errors.New("product code value already exists")
+	// ErrRepositoryNegativeStock is returned when a movement would leave the stock below zero
+	ErrRepositoryNegativeStock = errors.New("stock cannot be negative")
+	// ErrRepositoryVersionConflict is returned when the product changed since the given version was read
+	ErrRepositoryVersionConflict = errors.New("product version conflict")
+	// ErrRepositoryProductNotDeleted is returned when restoring a product that is not deleted
+	ErrRepositoryProductNotDeleted = errors.New("product is not deleted")
 )
 
-// Repository is an interface that defines the methods that a product repository must implement
+// Repository is an interface that defines the methods that a product repository must implement.
+// Every write runs the Hook of its context for the products it changes
+// Deleting a product only marks it as deleted, only GetAllWithDeleted, Restore and Purge see it afterwards
 type Repository interface {
-	// GetAll returns all the products
+	// GetAll returns all the products ordered by ID
 	GetAll(ctx context.Context) ([]domain.Product, error)
+	// GetAllWithDeleted returns all the products ordered by ID, the deleted ones included
+	GetAllWithDeleted(ctx context.Context) ([]domain.Product, error)
 	// GetByID returns a product by its ID
 	GetByID(ctx context.Context, id int) (domain.Product, error)
-	// Create creates a new product
+	// Create creates a new product at version 1, recording its quantity as the initial receipt in the stock ledger
 	Create(ctx context.Context, product *domain.Product) error
-	// Update updates a product
+	// Update updates a product if it is still at the version of the given product and bumps the version,
+	// recording a change of quantity as an adjustment in the stock ledger
 	Update(ctx context.Context, product *domain.Product) error
-	// Delete deletes a product
-	Delete(ctx context.Context, id int) error
+	// Delete marks a product as deleted and bumps its version if it is at the given version, a zero version
+	// deletes it at any version. Its code value is free for other products from then on
+	Delete(ctx context.Context, id int, version int) error
+	// Restore undeletes a product and bumps its version, failing if another product took its code value meanwhile
+	Restore(ctx context.Context, id int) (domain.Product, error)
+	// Purge removes for good the products deleted before the given time, with their stock ledgers, and returns them
+	Purge(ctx context.Context, before time.Time) ([]domain.Product, error)
 	// Exists verify the existence of a product with the given product code value
 	Exists(ctx context.Context, codeValue string) (bool, error)
 	// ExistsWithDifferentID verify the existence of a product with the given product code value and different ID
 	ExistsWithDifferentID(ctx context.Context, id int, codeValue string) (bool, error)
+	// GetByExpiration returns the products expiring from the from date (inclusive) to the to date (exclusive) ordered by ID, a zero date leaves that end open
+	GetByExpiration(ctx context.Context, from, to domain.Date) ([]domain.Product, error)
+	// UnpublishExpired unpublishes the published products expiring before the given date, bumping their versions, and returns them unpublished
+	UnpublishExpired(ctx context.Context, before domain.Date) ([]domain.Product, error)
+	// AddMovement records the movement and applies it to the product quantity at once, bumping the product version
+	// and setting the movement ID, balance and time
+	AddMovement(ctx context.Context, movement *domain.StockMovement) error
+	// GetMovements returns the stock ledger of a product ordered by ID
+	GetMovements(ctx context.Context, productID int) ([]domain.StockMovement, error)
+}
+
+// Change is a product a write changes: Before as it was, nil when the write creates it, and After as the write
+// leaves it, or as it was when the write removes it for good
+type Change struct {
+	Before *domain.Product
+	After  domain.Product
+}
+
+// Hook runs within a write of the repository for every product the write changes, after the change is made and
+// before it is committed. The before state is read within the write, so no other write can come in between. The
+// context it gets joins the write, a hook error undoes the whole write and is returned by it
+type Hook func(ctx context.Context, change Change) error
+
+// hookKey is the context key of the hook of the writes
+type hookKey struct{}
+
+// WithHook returns a copy of ctx whose writes run the hook
+func WithHook(ctx context.Context, hook Hook) context.Context {
+	return context.WithValue(ctx, hookKey{}, hook)
+}
+
+// CallHook runs the hook of ctx for each change in order, stopping at the first error. A context without hook
+// has nothing to run
+func CallHook(ctx context.Context, changes ...Change) error {
+	hook, ok := ctx.Value(hookKey{}).(Hook)
+	if !ok {
+		return nil
+	}
+	for _, change := range changes {
+		if err := hook(ctx, change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExpiresWithin reports whether the product expires from the from date (inclusive) to the to date (exclusive), a zero date leaves that end open
+func ExpiresWithin(product domain.Product, from, to domain.Date) bool {
+	if !from.IsZero() && product.Expiration.Before(from.Time) {
+		return false
+	}
+	if !to.IsZero() && !product.Expiration.Before(to.Time) {
+		return false
+	}
+	return true
+}
+
+// DeletedBefore reports whether the product was deleted before the given time
+func DeletedBefore(product domain.Product, before time.Time) bool {
+	return product.DeletedAt != nil && product.DeletedAt.Before(before)
+}
+
+// DeletionTime returns the current time as deletions store it, in UTC and to the second
+func DeletionTime() *time.Time {
+	now := time.Now().UTC().Truncate(time.Second)
+	return &now
+}
+
+// QuantityAdjustment returns the adjustment recording a change of quantity made by an update
+func QuantityAdjustment(productID, from, to int) domain.StockMovement {
+	return domain.StockMovement{
+		ProductId: productID,
+		Type:      domain.MovementAdjustment,
+		Quantity:  to - from,
+		Balance:   to,
+		Note:      "quantity updated",
+		CreatedAt: domain.MovementTime(),
+	}
+}
+
+// InitialReceipt returns the receipt recording the quantity a product is created with
+func InitialReceipt(productID, quantity int) domain.StockMovement {
+	return domain.StockMovement{
+		ProductId: productID,
+		Type:      domain.MovementReceipt,
+		Quantity:  quantity,
+		Balance:   quantity,
+		Note:      "initial stock",
+		CreatedAt: domain.MovementTime(),
+	}
+}
+
+// OpeningReceipt returns the receipt opening the stock ledger of a product that existed before it,
+// with the quantity the product had by then
+func OpeningReceipt(productID, quantity int) domain.StockMovement {
+	return domain.StockMovement{
+		ProductId: productID,
+		Type:      domain.MovementReceipt,
+		Quantity:  quantity,
+		Balance:   quantity,
+		Note:      "opening stock",
+		CreatedAt: domain.MovementTime(),
+	}
 }
@@ -2,38 +2,51 @@ package product
 
 import (
 	"context"
-	"errors"
 	"gostorage/internal/domain"
+	"gostorage/pkg/apperr"
 )
 
 var (
 	// ErrServiceProductNotFound is returned when a product is not found
-	ErrServiceProductNotFound = errors.New("product not found")
+	ErrServiceProductNotFound = apperr.New(apperr.NotFound, "PRODUCT_NOT_FOUND", "product not found")
 	// ErrServiceInvalidProductID is returned when the product ID is invalid
-	ErrServiceInvalidProductID = errors.New("invalid product identifier")
-	// ErrServiceInvalidProductName is returned when the product name is invalid
-	ErrServiceInvalidProductName = errors.New("invalid product name")
-	// ErrServiceInvalidProductQuantity is returned when the product quantity is invalid
-	ErrServiceInvalidProductQuantity = errors.New("invalid product quantity")
-	// ErrServiceInvalidProductCodeValue is returned when the product code value is invalid
-	ErrServiceInvalidProductCodeValue = errors.New("invalid product code value")
-	// ErrServiceInvalidProductExpiration is returned when the product expiration is invalid
-	ErrServiceInvalidProductExpiration = errors.New("invalid product expiration")
-	// ErrServiceInvalidProductPrice is returned when the product price is invalid
-	ErrServiceInvalidProductPrice = errors.New("invalid product price")
+	ErrServiceInvalidProductID = apperr.New(apperr.Invalid, "INVALID_PRODUCT_ID", "invalid product identifier")
 	// ErrServiceAlreadyExistsCodeValue is returned when the product code value already exists
-	ErrorServiceAlreadyExistsCodeValue = errors.New("product code value already exists")
+	ErrorServiceAlreadyExistsCodeValue = apperr.New(apperr.Conflict, "CODE_VALUE_CONFLICT", "product code value already exists")
+	// ErrServiceProductVersionConflict is returned when the product changed since the given version was read
+	ErrServiceProductVersionConflict = apperr.New(apperr.Conflict, "VERSION_CONFLICT", "product was modified by another request")
+	// ErrServiceProductNotDeleted is returned when restoring a product that is not deleted
+	ErrServiceProductNotDeleted = apperr.New(apperr.Conflict, "PRODUCT_NOT_DELETED", "product is not deleted")
+	// ErrServiceInvalidMovementType is returned when the stock movement type is unknown
+	ErrServiceInvalidMovementType = apperr.New(apperr.Invalid, "INVALID_MOVEMENT_TYPE", "invalid stock movement type")
+	// ErrServiceInvalidMovementQuantity is returned when the stock movement quantity is invalid for its type
+	ErrServiceInvalidMovementQuantity = apperr.New(apperr.Invalid, "INVALID_MOVEMENT_QUANTITY", "invalid stock movement quantity")
+	// ErrServiceNegativeStock is returned when a stock movement would leave the stock below zero
+	ErrServiceNegativeStock = apperr.New(apperr.Conflict, "INSUFFICIENT_STOCK", "insufficient stock")
 )
 
 type Service interface {
 	// GetAll returns all the products
 	GetAll(ctx context.Context) ([]domain.Product, error)
+	// GetAllWithDeleted returns all the products, the deleted ones included
+	GetAllWithDeleted(ctx context.Context) ([]domain.Product, error)
+	// GetByExpiration returns the products expiring before the given date, unless it is zero,
+	// keeping only the expired or the not expired ones as of today when expired is not nil
+	GetByExpiration(ctx context.Context, before domain.Date, expired *bool) ([]domain.Product, error)
 	// GetByID returns a product by its ID
 	GetByID(ctx context.Context, id int) (domain.Product, error)
 	// Create creates a new product
 	Create(ctx context.Context, p *domain.Product) (domain.Product, error)
-	// Update updates a product
+	// Update updates a product if it is still at the version of p, setting the new version in p
 	Update(ctx context.Context, p *domain.Product) error
-	// Delete deletes a product
-	Delete(ctx context.Context, id int) error
+	// Delete deletes a product if it is at the given version, a zero version deletes it at any version.
+	// The product can be restored until it is purged
+	Delete(ctx context.Context, id int, version int) error
+	// Restore undeletes a product
+	Restore(ctx context.Context, id int) (domain.Product, error)
+	// AddMovement records a stock movement of a product and returns it with the resulting balance.
+	// Receipts, sales and write-offs take a positive quantity, adjustments a signed one
+	AddMovement(ctx context.Context, productID int, movementType domain.MovementType, quantity int, note string) (domain.StockMovement, error)
+	// GetMovements returns the stock ledger of a product
+	GetMovements(ctx context.Context, productID int) ([]domain.StockMovement, error)
 }
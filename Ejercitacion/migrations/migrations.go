@@ -0,0 +1,8 @@
+package migrations
+
+import "embed"
+
+// MySQL holds the versioned schema migrations for MySQL
+//
+//go:embed mysql/*.sql
+var MySQL embed.FS
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS `products` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `name` varchar(255) NOT NULL,
+  `quantity` int NOT NULL,
+  `code_value` varchar(64) NOT NULL,
+  `is_published` tinyint(1) NOT NULL DEFAULT 0,
+  `expiration` date NOT NULL,
+  `price` decimal(10,2) NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `products_code_value_UNIQUE` (`code_value`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
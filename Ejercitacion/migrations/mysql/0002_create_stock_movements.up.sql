@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS `stock_movements` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `product_id` int NOT NULL,
+  `type` varchar(16) NOT NULL,
+  `quantity` int NOT NULL,
+  `balance` int NOT NULL,
+  `note` varchar(255) NOT NULL DEFAULT '',
+  `created_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `stock_movements_product_id_idx` (`product_id`),
+  CONSTRAINT `stock_movements_product_id_fk` FOREIGN KEY (`product_id`) REFERENCES `products` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
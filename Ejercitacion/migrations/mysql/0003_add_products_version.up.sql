@@ -0,0 +1 @@
+ALTER TABLE `products` ADD COLUMN `version` int NOT NULL DEFAULT 1;
@@ -0,0 +1,8 @@
+-- Deleted products are purged first, they may share a code value with another product
+DELETE FROM `products` WHERE `deleted_at` IS NOT NULL;
+
+ALTER TABLE `products` DROP INDEX `products_active_code_value_UNIQUE`, ADD UNIQUE KEY `products_code_value_UNIQUE` (`code_value`);
+
+ALTER TABLE `products` DROP COLUMN `active_code_value`;
+
+ALTER TABLE `products` DROP COLUMN `deleted_at`;
@@ -0,0 +1,6 @@
+ALTER TABLE `products` ADD COLUMN `deleted_at` datetime NULL DEFAULT NULL;
+
+-- Only products that are not deleted keep their code value unique, the column is NULL for deleted ones
+ALTER TABLE `products` ADD COLUMN `active_code_value` varchar(64) GENERATED ALWAYS AS (IF(`deleted_at` IS NULL, `code_value`, NULL)) VIRTUAL;
+
+ALTER TABLE `products` DROP INDEX `products_code_value_UNIQUE`, ADD UNIQUE KEY `products_active_code_value_UNIQUE` (`active_code_value`);
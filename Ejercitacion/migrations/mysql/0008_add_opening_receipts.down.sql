@@ -0,0 +1 @@
+DELETE FROM `stock_movements` WHERE `type` = 'receipt' AND `note` = 'opening stock';
@@ -0,0 +1,5 @@
+INSERT INTO `stock_movements` (`product_id`, `type`, `quantity`, `balance`, `note`, `created_at`)
+SELECT `p`.`id`, 'receipt', `p`.`quantity`, `p`.`quantity`, 'opening stock', UTC_TIMESTAMP()
+FROM `products` `p`
+WHERE `p`.`quantity` <> 0
+  AND NOT EXISTS (SELECT 1 FROM `stock_movements` `m` WHERE `m`.`product_id` = `p`.`id`);
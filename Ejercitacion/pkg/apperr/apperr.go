@@ -0,0 +1,133 @@
+package apperr
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Kind classifies application errors, each kind answers with its own HTTP status.
+type Kind int
+
+const (
+	// Internal is an unexpected error, its detail is not shown to clients.
+	Internal Kind = iota
+	// Invalid is a request with invalid data.
+	Invalid
+	// NotFound is a resource that does not exist.
+	NotFound
+	// Conflict is a request that clashes with the current state of the resource.
+	Conflict
+	// PreconditionFailed is a precondition of the request, like If-Match, that
+	// does not hold.
+	PreconditionFailed
+	// UnsupportedMediaType is a body of a type that is not accepted.
+	UnsupportedMediaType
+	// Unprocessable is a well-formed body that cannot be processed.
+	Unprocessable
+	// Unauthenticated is a request without credentials or with invalid ones.
+	Unauthenticated
+	// Forbidden is a request from a principal without the permission it needs.
+	Forbidden
+)
+
+// Status returns the HTTP status of the kind.
+func (k Kind) Status() int {
+	switch k {
+	case Invalid:
+		return http.StatusBadRequest
+	case NotFound:
+		return http.StatusNotFound
+	case Conflict:
+		return http.StatusConflict
+	case PreconditionFailed:
+		return http.StatusPreconditionFailed
+	case UnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	case Unprocessable:
+		return http.StatusUnprocessableEntity
+	case Unauthenticated:
+		return http.StatusUnauthorized
+	case Forbidden:
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// FieldError is the error of a field of the request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error is an application error with a stable code clients can rely on, like
+// PRODUCT_NOT_FOUND, and the fields that caused it when they are known.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Fields  []FieldError
+	// Err is the cause of the error, if it has one.
+	Err error
+}
+
+// ErrInternal is the application error of the errors that have none.
+var ErrInternal = New(Internal, "INTERNAL_ERROR", "internal server error")
+
+// New creates an application error.
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is compares kind and code, so copies made by Wrap and WithFields are still
+// the same error.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Kind == e.Kind && t.Code == e.Code
+}
+
+// Wrap returns a copy of the error with the given cause.
+func (e *Error) Wrap(err error) *Error {
+	c := *e
+	c.Err = err
+	return &c
+}
+
+// WithFields returns a copy of the error with the given fields added.
+func (e *Error) WithFields(fields ...FieldError) *Error {
+	c := *e
+	c.Fields = append(append([]FieldError(nil), e.Fields...), fields...)
+	return &c
+}
+
+// From returns the application error in the chain of err, or an internal one
+// when there is none.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return ErrInternal.Wrap(err)
+}
+
+// Ensure returns err when it already has an application error in its chain,
+// otherwise it wraps it in fallback.
+func Ensure(err error, fallback *Error) error {
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+	return fallback.Wrap(err)
+}
@@ -0,0 +1,164 @@
+package auth_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"gostorage/migrations"
+	"gostorage/pkg/auth"
+	"gostorage/pkg/migrate"
+)
+
+func TestHashKey(t *testing.T) {
+	// The SHA-256 of "abc", from FIPS 180-2.
+	if got := auth.HashKey("abc"); got != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+		t.Fatalf("got %s", got)
+	}
+	if auth.HashKey("abc") == auth.HashKey("abd") {
+		t.Fatalf("different keys have the same hash")
+	}
+
+	first, err := auth.NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := auth.NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 32 random bytes in unpadded base64url.
+	if len(first) != 43 || first == second || strings.ContainsAny(first, "+/=") {
+		t.Fatalf("got keys %q and %q", first, second)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	hash := auth.HashKey("k1")
+	tests := []struct {
+		name    string
+		spec    string
+		want    auth.StaticKeys
+		wantErr bool
+	}{
+		{"with a role", "ana:" + hash + ":admin", auth.StaticKeys{hash: {Subject: "ana", Method: auth.MethodAPIKey, Roles: []string{auth.RoleAdmin}}}, false},
+		{"without a role is a reader", "ana:" + hash, auth.StaticKeys{hash: {Subject: "ana", Method: auth.MethodAPIKey, Roles: []string{auth.RoleReader}}}, false},
+		{"upper case hash", " ana:" + strings.ToUpper(hash) + ":clerk , ", auth.StaticKeys{hash: {Subject: "ana", Method: auth.MethodAPIKey, Roles: []string{auth.RoleClerk}}}, false},
+		{"empty", "", auth.StaticKeys{}, false},
+		{"no subject", ":" + hash + ":admin", nil, true},
+		{"short hash", "ana:abc:admin", nil, true},
+		{"hash that is not hex", "ana:" + strings.Repeat("z", 64) + ":admin", nil, true},
+		{"unknown role", "ana:" + hash + ":owner", nil, true},
+		{"too many parts", "ana:" + hash + ":admin:x", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := auth.ParseKeys(tt.spec)
+			if (err != nil) != tt.wantErr || !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, %v", got, err)
+			}
+		})
+	}
+}
+
+// TestAPIKeyRevocation runs against the server of TEST_MYSQL_DATA_SOURCE, in
+// a database created for the test and dropped after it.
+func TestAPIKeyRevocation(t *testing.T) {
+	ctx := context.Background()
+	db := newDatabase(t)
+
+	// The table keeps only the hash, as the apikey command stores it.
+	for _, k := range []struct{ subject, key, role string }{{"ana", "k1", auth.RoleAdmin}, {"ana", "k2", auth.RoleClerk}, {"bob", "k3", auth.RoleReader}} {
+		if _, err := db.ExecContext(ctx, "INSERT INTO api_keys (subject, key_hash, role) VALUES (?, ?, ?)", k.subject, auth.HashKey(k.key), k.role); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.ExecContext(ctx, "UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE subject = ? AND revoked_at IS NULL", "ana"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A key in the configuration is not revoked with the ones in the table.
+	static := auth.StaticKeys{auth.HashKey("k4"): {Subject: "ana", Method: auth.MethodAPIKey, Roles: []string{auth.RoleAdmin}}}
+	a := auth.NewAPIKeyAuthenticator(static, auth.NewSQLKeyStore(db))
+
+	tests := []struct {
+		name    string
+		key     string
+		want    string
+		wantErr error
+	}{
+		{"revoked admin key", "k1", "", auth.ErrInvalidCredentials},
+		{"revoked clerk key", "k2", "", auth.ErrInvalidCredentials},
+		{"key of another subject", "k3", "bob", nil},
+		{"key in the configuration", "k4", "ana", nil},
+		{"unknown key", "unknown", "", auth.ErrInvalidCredentials},
+		{"no key", "", "", auth.ErrNoCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest("GET", "/", nil)
+			if tt.key != "" {
+				request.Header.Set(auth.APIKeyHeader, tt.key)
+			}
+			p, err := a.Authenticate(request)
+			if !errors.Is(err, tt.wantErr) || p.Subject != tt.want {
+				t.Fatalf("got %+v, %v", p, err)
+			}
+		})
+	}
+}
+
+// newDatabase creates a database on the server of TEST_MYSQL_DATA_SOURCE,
+// dropped after the test, and applies every migration to it.
+func newDatabase(t *testing.T) *sql.DB {
+	t.Helper()
+	dataSource := os.Getenv("TEST_MYSQL_DATA_SOURCE")
+	if dataSource == "" {
+		t.Skip("TEST_MYSQL_DATA_SOURCE is not set")
+	}
+	cfg, err := mysql.ParseDSN(dataSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := sql.Open("mysql", dataSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	name := fmt.Sprintf("keys_%d", time.Now().UnixNano())
+	if _, err := server.Exec("CREATE DATABASE " + name); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Exec("DROP DATABASE " + name) })
+
+	cfg.DBName = name
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	files, err := fs.Sub(migrations.MySQL, "mysql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := migrate.New(db, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
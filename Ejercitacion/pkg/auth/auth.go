@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"gostorage/pkg/apperr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContextKey is the gin context key of the authenticated principal.
+const ContextKey = "principal"
+
+var (
+	// ErrNoCredentials is returned by an authenticator when the request does
+	// not carry its kind of credentials, so the next one can try.
+	ErrNoCredentials = errors.New("no credentials")
+
+	ErrUnauthenticated    = apperr.New(apperr.Unauthenticated, "UNAUTHENTICATED", "authentication required")
+	ErrInvalidCredentials = apperr.New(apperr.Unauthenticated, "INVALID_CREDENTIALS", "invalid credentials")
+)
+
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// Principal is who made the request, the subject of its token or the owner
+// of its API key, with the roles it was given.
+type Principal struct {
+	Subject string   `json:"subject"`
+	Method  string   `json:"method"`
+	Roles   []string `json:"roles"`
+}
+
+// Authenticator turns the credentials of a request into a principal.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Principal, error)
+}
+
+type principalKey struct{}
+
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal of a gin context, or of any context
+// derived from one or from its request.
+func FromContext(ctx context.Context) (Principal, bool) {
+	if p, ok := ctx.Value(ContextKey).(Principal); ok {
+		return p, true
+	}
+
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// Middleware authenticates every request with the first authenticator that
+// finds its credentials in it. Requests without credentials, or with invalid
+// ones, are answered with 401, and a failed lookup with 500.
+func Middleware(authenticators ...Authenticator) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		for _, authenticator := range authenticators {
+			p, err := authenticator.Authenticate(ctx.Request)
+			if errors.Is(err, ErrNoCredentials) {
+				continue
+			}
+			if err != nil {
+				abort(ctx, err)
+				return
+			}
+
+			ctx.Set(ContextKey, p)
+			ctx.Request = ctx.Request.WithContext(NewContext(ctx.Request.Context(), p))
+			ctx.Next()
+			return
+		}
+
+		abort(ctx, ErrUnauthenticated)
+	}
+}
+
+func abort(ctx *gin.Context, err error) {
+	if apperr.From(err).Kind == apperr.Unauthenticated {
+		ctx.Header("WWW-Authenticate", `Bearer realm="api"`)
+	}
+	apperr.Write(ctx, err)
+	ctx.Abort()
+}
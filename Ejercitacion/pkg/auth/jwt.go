@@ -0,0 +1,231 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+)
+
+var ErrUnknownAlgorithm = errors.New("unknown jwt algorithm, expected HS256 or RS256")
+
+// JWTConfig tells how bearer tokens are verified. Key is the shared secret
+// for HS256 and the public key for RS256, see LoadJWTKey.
+type JWTConfig struct {
+	Algorithm string
+	Key       any
+	// Issuer and Audience are checked against the iss and aud claims when set
+	Issuer   string
+	Audience string
+	// Leeway tolerates clock skew on the exp and nbf claims
+	Leeway time.Duration
+}
+
+// LoadJWTKey reads the key of algorithm from path: the raw secret for HS256,
+// surrounding spaces trimmed, or a PEM public key or certificate for RS256.
+func LoadJWTKey(algorithm, path string) (any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch algorithm {
+	case HS256:
+		secret := []byte(strings.TrimSpace(string(data)))
+		if len(secret) < sha256.Size {
+			return nil, fmt.Errorf("jwt secret in %s must have at least %d bytes", path, sha256.Size)
+		}
+		return secret, nil
+	case RS256:
+		return parseRSAPublicKey(data)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAlgorithm, algorithm)
+	}
+}
+
+func parseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block in the jwt key")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			key = cert.PublicKey
+		}
+	default:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("the jwt key is not an RSA public key")
+	}
+	return rsaKey, nil
+}
+
+type jwtAuthenticator struct {
+	cfg JWTConfig
+	now func() time.Time
+}
+
+// NewJWTAuthenticator authenticates the bearer token in the Authorization
+// header. Only the configured algorithm is accepted, whatever the token
+// header says, and the token must have sub and exp claims. The roles of the
+// principal come from the roles claim.
+func NewJWTAuthenticator(cfg JWTConfig) (Authenticator, error) {
+	switch cfg.Algorithm {
+	case HS256:
+		if _, ok := cfg.Key.([]byte); !ok {
+			return nil, errors.New("HS256 needs a []byte secret")
+		}
+	case RS256:
+		if _, ok := cfg.Key.(*rsa.PublicKey); !ok {
+			return nil, errors.New("RS256 needs an *rsa.PublicKey")
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAlgorithm, cfg.Algorithm)
+	}
+
+	return &jwtAuthenticator{cfg, time.Now}, nil
+}
+
+type claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  list     `json:"aud"`
+	Roles     list     `json:"roles"`
+	ExpiresAt *float64 `json:"exp"`
+	NotBefore *float64 `json:"nbf"`
+}
+
+// list is a claim that can be a single string or a list of them, like aud.
+type list []string
+
+func (l *list) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*l = list{one}
+		return nil
+	}
+
+	return json.Unmarshal(data, (*[]string)(l))
+}
+
+func (j *jwtAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return Principal{}, ErrNoCredentials
+	}
+
+	c, err := j.verify(strings.TrimSpace(token))
+	if err != nil {
+		return Principal{}, ErrInvalidCredentials.Wrap(err)
+	}
+
+	return Principal{Subject: c.Subject, Method: MethodJWT, Roles: c.Roles}, nil
+}
+
+func (j *jwtAuthenticator) verify(token string) (claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims{}, errors.New("malformed token")
+	}
+
+	var header struct {
+		Algorithm string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return claims{}, err
+	}
+	if header.Algorithm != j.cfg.Algorithm {
+		return claims{}, fmt.Errorf("unexpected algorithm %q", header.Algorithm)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims{}, errors.New("malformed token signature")
+	}
+	if !j.verifySignature(parts[0]+"."+parts[1], signature) {
+		return claims{}, errors.New("invalid token signature")
+	}
+
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return claims{}, err
+	}
+
+	now := j.now()
+	switch {
+	case c.Subject == "":
+		return claims{}, errors.New("token has no subject")
+	case c.ExpiresAt == nil:
+		return claims{}, errors.New("token has no expiration")
+	case now.After(numericDate(*c.ExpiresAt).Add(j.cfg.Leeway)):
+		return claims{}, errors.New("token is expired")
+	case c.NotBefore != nil && now.Add(j.cfg.Leeway).Before(numericDate(*c.NotBefore)):
+		return claims{}, errors.New("token is not valid yet")
+	case j.cfg.Issuer != "" && c.Issuer != j.cfg.Issuer:
+		return claims{}, errors.New("unexpected token issuer")
+	case j.cfg.Audience != "" && !slices.Contains(c.Audience, j.cfg.Audience):
+		return claims{}, errors.New("unexpected token audience")
+	}
+
+	return c, nil
+}
+
+func (j *jwtAuthenticator) verifySignature(input string, signature []byte) bool {
+	switch key := j.cfg.Key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(input))
+		return hmac.Equal(mac.Sum(nil), signature)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256([]byte(input))
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	default:
+		return false
+	}
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errors.New("malformed token")
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.New("malformed token")
+	}
+	return nil
+}
+
+// numericDate converts a JWT NumericDate, seconds since the epoch that may
+// have a fraction, to a time.
+func numericDate(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
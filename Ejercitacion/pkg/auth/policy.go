@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"gostorage/pkg/apperr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Permission is what a route requires from the principal calling it.
+type Permission string
+
+const (
+	Read  Permission = "read"
+	Write Permission = "write"
+	Admin Permission = "admin"
+)
+
+const (
+	RoleReader = "reader"
+	RoleClerk  = "clerk"
+	RoleAdmin  = "admin"
+)
+
+// Roles grants the permissions of each role, every role can do what the ones
+// before it can.
+var Roles = map[string][]Permission{
+	RoleReader: {Read},
+	RoleClerk:  {Read, Write},
+	RoleAdmin:  {Read, Write, Admin},
+}
+
+var ErrForbidden = apperr.New(apperr.Forbidden, "FORBIDDEN", "permission denied")
+
+// Can tells whether any role of p grants permission. Unknown roles grant
+// nothing.
+func (p Principal) Can(permission Permission) bool {
+	for _, role := range p.Roles {
+		if slices.Contains(Roles[role], permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy maps each route, as "METHOD /path" with the path pattern it was
+// registered with, to the permission it requires.
+type Policy map[string]Permission
+
+// Missing returns the routes under prefix that have no permission in p.
+func (p Policy) Missing(routes gin.RoutesInfo, prefix string) []string {
+	var missing []string
+	for _, route := range routes {
+		key := route.Method + " " + route.Path
+		if _, ok := p[key]; !ok && strings.HasPrefix(route.Path, prefix) {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
+
+// Authorize lets the request through when its principal has the permission
+// the policy requires for the route, and answers 403 otherwise. Routes the
+// policy does not list are denied. Every decision is logged to logger, or to
+// the standard logger when nil.
+func (p Policy) Authorize(logger *log.Logger) gin.HandlerFunc {
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	return func(ctx *gin.Context) {
+		route := ctx.Request.Method + " " + ctx.FullPath()
+		principal, ok := FromContext(ctx)
+		if !ok {
+			logger.Printf("authz: deny anonymous %s: not authenticated", route)
+			abort(ctx, ErrUnauthenticated)
+			return
+		}
+
+		permission, ok := p[route]
+		switch {
+		case !ok:
+			logger.Printf("authz: deny %s roles=%v %s: no policy for the route", principal.Subject, principal.Roles, route)
+			abort(ctx, ErrForbidden.Wrap(fmt.Errorf("no policy for %s", route)))
+		case !principal.Can(permission):
+			logger.Printf("authz: deny %s roles=%v %s: requires %s", principal.Subject, principal.Roles, route, permission)
+			abort(ctx, ErrForbidden.Wrap(fmt.Errorf("%s requires the %s permission", route, permission)))
+		default:
+			logger.Printf("authz: allow %s roles=%v %s: requires %s", principal.Subject, principal.Roles, route, permission)
+			ctx.Next()
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package patch_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"gostorage/pkg/patch"
+	"reflect"
+	"testing"
+)
+
+// document has members needing escapes in their pointers: "a/b" is /a~1b, "m~n" is /m~0n and "~1" is /~01
+const document = `{"a":1,"b":{"c":[1,2,3]},"a/b":"slash","m~n":"tilde","~1":"literal"}`
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		// want is the patched document, ignored when wantErr is set
+		want    string
+		wantErr error
+	}{
+		// add
+		{"add a member", `[{"op":"add","path":"/d","value":4}]`, `{"a":1,"b":{"c":[1,2,3]},"a/b":"slash","m~n":"tilde","~1":"literal","d":4}`, nil},
+		{"add over a member replaces it", `[{"op":"add","path":"/a","value":[1]}]`, `{"a":[1],"b":{"c":[1,2,3]},"a/b":"slash","m~n":"tilde","~1":"literal"}`, nil},
+		{"add into an array shifts the rest", `[{"op":"add","path":"/b/c/1","value":9}]`, `{"a":1,"b":{"c":[1,9,2,3]},"a/b":"slash","m~n":"tilde","~1":"literal"}`, nil},
+		{"add at the length of an array appends", `[{"op":"add","path":"/b/c/3","value":9}]`, `{"a":1,"b":{"c":[1,2,3,9]},"a/b":"slash","m~n":"tilde","~1":"literal"}`, nil},
+		{"add at - appends", `[{"op":"add","path":"/b/c/-","value":9}]`, `{"a":1,"b":{"c":[1,2,3,9]},"a/b":"slash","m~n":"tilde","~1":"literal"}`, nil},
+		{"add at the root replaces the document", `[{"op":"add","path":"","value":{"z":0}}]`, `{"z":0}`, nil},
+		{"add past the end of an array", `[{"op":"add","path":"/b/c/4","value":9}]`, "", patch.ErrPathNotFound},
+		{"add under a missing member", `[{"op":"add","path":"/x/y","value":9}]`, "", patch.ErrPathNotFound},
+		{"add without a value", `[{"op":"add","path":"/d"}]`, "", patch.ErrInvalidPatch},
+
+		// remove
+		{"remove a member", `[{"op":"remove","path":"/a"}]`, `{"b":{"c":[1,2,3]},"a/b":"slash","m~n":"tilde","~1":"literal"}`, nil},
+		{"remove from an array shifts the rest", `[{"op":"remove","path":"/b/c/0"}]`, `{"a":1,"b":{"c":[2,3]},"a/b":"slash","m~n":"tilde","~1":"literal"}`, nil},
+		{"remove a missing member", `[{"op":"remove","path":"/x"}]`, "", patch.ErrPathNotFound},
+		{"remove past the end of an array", `[{"op":"remove","path":"/b/c/3"}]`, "", patch.ErrPathNotFound},
+		{"remove at -", `[{"op":"remove","path":"/b/c/-"}]`, "", patch.ErrInvalidPatch},
+
+		// replace
+		{"replace a member", `[{"op":"replace","path":"/a","value":"one"}]`, `{"a":"one","b":{"c":[1,2,3]},"a/b":"slash","m~n":"tilde","~1":"literal"}`, nil},
+		{"replace an array element", `[{"op":"replace","path":"/b/c/2","value":0}]`, `{"a":1,"b":{"c":[1,2,0]},"a/b":"slash","m~n":"tilde","~1":"literal"}`, nil},
+		{"replace a missing member", `[{"op":"replace","path":"/x","value":1}]`, "", patch.ErrPathNotFound},
+
+		// move
+		{"move a member", `[{"op":"move","from":"/a","path":"/d"}]`, `{"d":1,"b":{"c":[1,2,3]},"a/b":"slash","m~n":"tilde","~1":"literal"}`, nil},
+		{"move within an array", `[{"op":"move","from":"/b/c/0","path":"/b/c/-"}]`, `{"a":1,"b":{"c":[2,3,1]},"a/b":"slash","m~n":"tilde","~1":"literal"}`, nil},
+		{"move to a sibling with a longer name", `[{"op":"move","from":"/a","path":"/ab"}]`, `{"ab":1,"b":{"c":[1,2,3]},"a/b":"slash","m~n":"tilde","~1":"literal"}`, nil},
+		{"move into its own child", `[{"op":"move","from":"/b","path":"/b/d"}]`, "", patch.ErrInvalidPatch},
+		{"move a missing member", `[{"op":"move","from":"/x","path":"/d"}]`, "", patch.ErrPathNotFound},
+
+		// copy
+		{"copy a member", `[{"op":"copy","from":"/b","path":"/d"}]`, `{"a":1,"b":{"c":[1,2,3]},"d":{"c":[1,2,3]},"a/b":"slash","m~n":"tilde","~1":"literal"}`, nil},
+		{"a copy is independent of its source", `[{"op":"copy","from":"/b/c","path":"/d"},{"op":"add","path":"/d/0","value":0}]`, `{"a":1,"b":{"c":[1,2,3]},"d":[0,1,2,3],"a/b":"slash","m~n":"tilde","~1":"literal"}`, nil},
+		{"copy a missing member", `[{"op":"copy","from":"/x","path":"/d"}]`, "", patch.ErrPathNotFound},
+
+		// test
+		{"test a matching value", `[{"op":"test","path":"/b","value":{"c":[1,2,3]}}]`, document, nil},
+		{"test a different value", `[{"op":"test","path":"/a","value":2}]`, "", patch.ErrTestFailed},
+		{"test a missing member", `[{"op":"test","path":"/x","value":1}]`, "", patch.ErrPathNotFound},
+
+		// array indices
+		{"index with a leading zero", `[{"op":"replace","path":"/b/c/01","value":0}]`, "", patch.ErrInvalidPatch},
+		{"index with a sign", `[{"op":"replace","path":"/b/c/+1","value":0}]`, "", patch.ErrInvalidPatch},
+		{"negative index", `[{"op":"replace","path":"/b/c/-1","value":0}]`, "", patch.ErrInvalidPatch},
+		{"index that is not a number", `[{"op":"replace","path":"/b/c/one","value":0}]`, "", patch.ErrInvalidPatch},
+
+		// escapes
+		{"~1 is a slash", `[{"op":"replace","path":"/a~1b","value":"s"}]`, `{"a":1,"b":{"c":[1,2,3]},"a/b":"s","m~n":"tilde","~1":"literal"}`, nil},
+		{"~0 is a tilde", `[{"op":"remove","path":"/m~0n"}]`, `{"a":1,"b":{"c":[1,2,3]},"a/b":"slash","~1":"literal"}`, nil},
+		{"~01 is a tilde followed by 1", `[{"op":"replace","path":"/~01","value":"l"}]`, `{"a":1,"b":{"c":[1,2,3]},"a/b":"slash","m~n":"tilde","~1":"l"}`, nil},
+
+		// malformed patches
+		{"unknown op", `[{"op":"merge","path":"/a","value":1}]`, "", patch.ErrInvalidPatch},
+		{"path without a slash", `[{"op":"remove","path":"a"}]`, "", patch.ErrInvalidPatch},
+		{"patch that is not a list", `{"op":"remove","path":"/a"}`, "", patch.ErrInvalidPatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := patch.Apply([]byte(document), []byte(tt.patch))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && !equalJSON(t, got, tt.want) {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyIsAllOrNothing(t *testing.T) {
+	doc := []byte(document)
+	original := append([]byte(nil), doc...)
+
+	// The first operations succeed and the last one fails
+	ops := `[{"op":"add","path":"/d","value":4},{"op":"remove","path":"/b/c/0"},{"op":"test","path":"/a","value":2}]`
+	got, err := patch.Apply(doc, []byte(ops))
+	if !errors.Is(err, patch.ErrTestFailed) || got != nil {
+		t.Fatalf("got %s, %v", got, err)
+	}
+	if !bytes.Equal(doc, original) {
+		t.Fatalf("document changed: got %s", doc)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{"set a member", `{"a":1,"b":2}`, `{"a":3}`, `{"a":3,"b":2}`},
+		{"add a member", `{"a":1}`, `{"b":2}`, `{"a":1,"b":2}`},
+		{"null removes a member", `{"a":1,"b":2}`, `{"a":null}`, `{"b":2}`},
+		{"null for a missing member", `{"a":1}`, `{"x":null}`, `{"a":1}`},
+		{"objects merge recursively", `{"a":{"b":1,"c":2}}`, `{"a":{"b":null,"d":3}}`, `{"a":{"c":2,"d":3}}`},
+		{"arrays are replaced whole", `{"a":[1,2,3]}`, `{"a":[4]}`, `{"a":[4]}`},
+		{"an object replaces a value", `{"a":1}`, `{"a":{"b":null,"c":1}}`, `{"a":{"c":1}}`},
+		{"a patch that is not an object replaces the document", `{"a":1}`, `[1]`, `[1]`},
+		{"an empty patch changes nothing", `{"a":1}`, `{}`, `{"a":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := patch.Merge([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("merge: %v", err)
+			}
+			if !equalJSON(t, got, tt.want) {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := patch.Merge([]byte(`{"a":1}`), []byte(`{"a":`)); !errors.Is(err, patch.ErrInvalidPatch) {
+		t.Fatalf("malformed patch: got %v", err)
+	}
+}
+
+// equalJSON reports whether both documents hold the same value, whatever the order of their members
+func equalJSON(t *testing.T, got []byte, want string) bool {
+	t.Helper()
+	var g, w any
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("got %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("want %s: %v", want, err)
+	}
+	return reflect.DeepEqual(g, w)
+}
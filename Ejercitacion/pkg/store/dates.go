@@ -0,0 +1,112 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"gostorage/internal/domain"
+	"gostorage/internal/product"
+	"gostorage/pkg"
+)
+
+// NormalizeDates rewrites the expirations of the snapshot in path in the
+// canonical layout, reading any of the known layouts, and compacts it with the
+// log. With dryRun it only reports the changes without writing anything
+func NormalizeDates(path string, dryRun bool) ([]product.ExpirationChange, error) {
+	unlock, err := lockFile(path, true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// read as generic json so it does not depend on the accepted layouts
+	file, err := readRaw(path)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]product.ExpirationChange, 0)
+	invalid, unparseable := make([]string, 0), make([]string, 0)
+	for i, item := range file {
+		var id int
+		var value string
+		// without a valid ID the record is reported by its position in the file
+		if err := json.Unmarshal(item["id"], &id); err != nil {
+			unparseable = append(unparseable, fmt.Sprintf("#%d (id %s)", i+1, rawOrMissing(item["id"])))
+			continue
+		}
+		if err := json.Unmarshal(item["expiration"], &value); err != nil {
+			invalid = append(invalid, fmt.Sprintf("%d (%s)", id, item["expiration"]))
+			continue
+		}
+
+		date, err := pkg.ParseDateWith(value, pkg.KnownDateLayouts()...)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("%d (%q)", id, value))
+			continue
+		}
+		canonical := date.Format(pkg.CanonicalDateLayout)
+		if canonical != value {
+			changes = append(changes, product.ExpirationChange{Id: id, From: value, To: canonical})
+		}
+		item["expiration"], _ = json.Marshal(canonical)
+	}
+	if len(unparseable) > 0 {
+		return nil, fmt.Errorf("%w in %s: %s", ErrUnparseableProduct, path, strings.Join(unparseable, ", "))
+	}
+	if len(invalid) > 0 {
+		return nil, fmt.Errorf("%w in products %s", pkg.ErrInvalidDate, strings.Join(invalid, ", "))
+	}
+	if dryRun || len(changes) == 0 {
+		return changes, nil
+	}
+
+	// with canonical expirations the file can be read as products
+	bytes, err := json.Marshal(file)
+	if err != nil {
+		return nil, err
+	}
+	var products []domain.Product
+	if err := json.Unmarshal(bytes, &products); err != nil {
+		return nil, err
+	}
+	if ids := duplicatedIDs(products); len(ids) > 0 {
+		return nil, fmt.Errorf("%w in %s: %v, run repair first", ErrDuplicatedID, path, ids)
+	}
+
+	last, err := readSequence(path)
+	if err != nil {
+		return nil, err
+	}
+	ix, _, _, err := loadIndex(path, products, last)
+	if err != nil {
+		return nil, err
+	}
+	if err := compact(path, ix); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// readRaw reads the products of path as uninterpreted json objects
+func readRaw(path string) ([]map[string]json.RawMessage, error) {
+	var products []map[string]json.RawMessage
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(bytes, &products); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// rawOrMissing returns the json of the value or "missing" when the field is absent
+func rawOrMissing(value json.RawMessage) string {
+	if value == nil {
+		return "missing"
+	}
+	return string(value)
+}
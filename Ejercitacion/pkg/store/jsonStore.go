@@ -1,111 +1,619 @@
 package store
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"gostorage/internal/domain"
+	"gostorage/internal/product"
 )
 
+const (
+	// DefaultCompactEvery is the number of log entries that triggers a compaction
+	DefaultCompactEvery = 1000
+)
+
+var (
+	// ErrDuplicatedID is returned when loading a file with repeated IDs, the repair command fixes it
+	ErrDuplicatedID = errors.New("integrity error: duplicated product id")
+	// ErrUnparseableProduct is returned when a record of the file has no valid ID
+	ErrUnparseableProduct = errors.New("integrity error: unparseable product")
+)
+
+// Options sets when the write log is compacted
+type Options struct {
+	// CompactEvery compacts when the log reaches this number of entries, zero uses DefaultCompactEvery
+	CompactEvery int
+	// CompactInterval compacts on the first write after this time, zero disables it
+	CompactInterval time.Duration
+}
+
+// jsonStore is a product.Repository keeping the products in a JSON file.
+// The products are kept in memory, every write is appended to a log and
+// the file is only rewritten when compacting
 type jsonStore struct {
 	pathToFile string
+	opts       Options
+	// mu serializes the access within the process, the file lock does it between processes
+	mu sync.Mutex
+
+	// index is nil until the first load
+	index *index
+	// snapshot identifies the loaded file, another process replaces it when compacting
+	snapshot os.FileInfo
+	// walOffset is how far the log was read and walEntries how many entries it has
+	walOffset  int64
+	walEntries int
+	// compactedAt is the last compaction or load
+	compactedAt time.Time
 }
 
-// loadProducts carga los productos desde un archivo json
-func (s *jsonStore) loadProducts() ([]domain.Product, error) {
-	var products []domain.Product
-	file, err := os.ReadFile(s.pathToFile)
+// NewJsonStore creates a new store of products
+func NewJsonStore(path string) product.Repository {
+	return NewJsonStoreWithOptions(path, Options{})
+}
+
+// NewJsonStoreWithOptions creates a new store of products with the given compaction
+func NewJsonStoreWithOptions(path string, opts Options) product.Repository {
+	_, err := os.Stat(path)
 	if err != nil {
-		return nil, err
+		panic(err)
 	}
-	err = json.Unmarshal([]byte(file), &products)
+	if opts.CompactEvery <= 0 {
+		opts.CompactEvery = DefaultCompactEvery
+	}
+	return &jsonStore{
+		pathToFile: path,
+		opts:       opts,
+	}
+}
+
+// load loads the snapshot and applies the whole log to it
+func (s *jsonStore) load(snapshot os.FileInfo) error {
+	products, err := readProducts(s.pathToFile)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return products, nil
+	if ids := duplicatedIDs(products); len(ids) > 0 {
+		return fmt.Errorf("%w in %s: %v", ErrDuplicatedID, s.pathToFile, ids)
+	}
+	lastID, err := readSequence(s.pathToFile)
+	if err != nil {
+		return err
+	}
+
+	ix, offset, entries, err := loadIndex(s.pathToFile, products, lastID)
+	if err != nil {
+		return err
+	}
+
+	s.index, s.snapshot = ix, snapshot
+	s.walOffset, s.walEntries = offset, entries
+	s.compactedAt = time.Now()
+	return nil
 }
 
-// saveProducts guarda los productos en un archivo json
-func (s *jsonStore) saveProducts(products []domain.Product) error {
-	bytes, err := json.Marshal(products)
+// refresh brings in the changes other processes made since the last
+// read: it applies the new log entries, or reloads everything after a compaction
+func (s *jsonStore) refresh() error {
+	snapshot, err := os.Stat(s.pathToFile)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(s.pathToFile, bytes, 0644)
+
+	walSize := int64(0)
+	if wal, err := os.Stat(walPath(s.pathToFile)); err == nil {
+		walSize = wal.Size()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if s.index == nil || !os.SameFile(snapshot, s.snapshot) || walSize < s.walOffset {
+		return s.load(snapshot)
+	}
+	if walSize == s.walOffset {
+		return nil
+	}
+
+	offset, entries, err := replay(s.pathToFile, s.walOffset, s.index)
+	s.walOffset = offset
+	s.walEntries += entries
+	return err
 }
 
-// NewJsonStore crea un nuevo store de products
-func NewJsonStore(path string) StoreInterface {
-	_, err := os.Stat(path)
+// read brings the index up to date under a shared lock and hands it to fn
+func (s *jsonStore) read(ctx context.Context, fn func(ix *index) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := lockFile(s.pathToFile, false)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	return &jsonStore{
-		pathToFile: path,
+	defer unlock()
+
+	if err := s.refresh(); err != nil {
+		return err
 	}
+	return fn(s.index)
 }
 
-func (s *jsonStore) Read(id int) (domain.Product, error) {
-	products, err := s.loadProducts()
+// write brings the index up to date under an exclusive lock, appends the entries
+// fn returns to the log and only then applies them in memory
+func (s *jsonStore) write(ctx context.Context, fn func(ix *index) ([]walEntry, error)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := lockFile(s.pathToFile, true)
 	if err != nil {
-		return domain.Product{}, err
+		return err
 	}
-	for _, product := range products {
-		if product.Id == id {
-			return product, nil
+	defer unlock()
+
+	if err := s.refresh(); err != nil {
+		return err
+	}
+	entries, err := fn(s.index)
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+
+	offset, err := appendEntries(s.pathToFile, s.walOffset, entries)
+	if err != nil {
+		return err
+	}
+	// the hook runs with the entries already in the log, if it fails they are taken out before being applied
+	if err := product.CallHook(ctx, s.index.changed(entries)...); err != nil {
+		return errors.Join(err, os.Truncate(walPath(s.pathToFile), s.walOffset))
+	}
+	for _, entry := range entries {
+		s.index.apply(entry)
+	}
+	s.walOffset = offset
+	s.walEntries += len(entries)
+
+	// the write is already in the log, a failed compaction is logged and retried on the next one
+	if s.walEntries >= s.opts.CompactEvery || (s.opts.CompactInterval > 0 && time.Since(s.compactedAt) >= s.opts.CompactInterval) {
+		if err := s.compact(); err != nil {
+			log.Printf("store: compact %s: %v", s.pathToFile, err)
 		}
 	}
-	return domain.Product{}, errors.New("product not found")
+	return nil
 }
 
-func (s *jsonStore) Create(product domain.Product) error {
-	products, err := s.loadProducts()
+// compact rewrites the snapshot with the state in memory, the caller holds the exclusive lock
+func (s *jsonStore) compact() error {
+	if err := compact(s.pathToFile, s.index); err != nil {
+		return err
+	}
+	snapshot, err := os.Stat(s.pathToFile)
 	if err != nil {
 		return err
 	}
-	product.Id = len(products) + 1
-	products = append(products, product)
-	return s.saveProducts(products)
+
+	s.snapshot = snapshot
+	s.walOffset, s.walEntries = 0, 0
+	s.compactedAt = time.Now()
+	return nil
 }
 
-func (s *jsonStore) Update(product domain.Product) error {
-	products, err := s.loadProducts()
+func (s *jsonStore) GetAll(ctx context.Context) ([]domain.Product, error) {
+	var all []domain.Product
+	err := s.read(ctx, func(ix *index) error {
+		all = ix.sortedActive()
+		return nil
+	})
 	if err != nil {
-		return err
+		return nil, err
+	}
+	return all, nil
+}
+
+func (s *jsonStore) GetAllWithDeleted(ctx context.Context) ([]domain.Product, error) {
+	var all []domain.Product
+	err := s.read(ctx, func(ix *index) error {
+		all = ix.sorted()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+func (s *jsonStore) GetByID(ctx context.Context, id int) (domain.Product, error) {
+	var found domain.Product
+	err := s.read(ctx, func(ix *index) error {
+		p, ok := ix.active(id)
+		if !ok {
+			return product.ErrRepositoryProductNotFound
+		}
+		found = p
+		return nil
+	})
+	return found, err
+}
+
+func (s *jsonStore) Create(ctx context.Context, p *domain.Product) error {
+	return s.write(ctx, func(ix *index) ([]walEntry, error) {
+		if ix.codeValueTaken(0, p.CodeValue) {
+			return nil, product.ErrRepositoryProductCodeValueDuplicated
+		}
+		// the ID comes from the sequence, which never goes back, so a deleted ID is never reused
+		created := *p
+		created.Id, created.Version = ix.lastID+1, 1
+		p.Id, p.Version = created.Id, created.Version
+		entry := walEntry{Op: opPut, Id: created.Id, Product: &created}
+
+		// the initial quantity is recorded as the first receipt
+		if created.Quantity != 0 {
+			receipt := product.InitialReceipt(created.Id, created.Quantity)
+			receipt.Id = ix.lastMovementID + 1
+			entry.Movement = &receipt
+		}
+		return []walEntry{entry}, nil
+	})
+}
+
+func (s *jsonStore) Update(ctx context.Context, p *domain.Product) error {
+	return s.write(ctx, func(ix *index) ([]walEntry, error) {
+		current, ok := ix.active(p.Id)
+		if !ok {
+			return nil, product.ErrRepositoryProductNotFound
+		}
+		// compare and swap, the product must still be at the version the caller read
+		if current.Version != p.Version {
+			return nil, product.ErrRepositoryVersionConflict
+		}
+		if ix.codeValueTaken(p.Id, p.CodeValue) {
+			return nil, product.ErrRepositoryProductCodeValueDuplicated
+		}
+		updated := *p
+		updated.Version++
+		p.Version = updated.Version
+		entry := walEntry{Op: opPut, Id: updated.Id, Product: &updated}
+
+		// a change of quantity is recorded as an adjustment
+		if updated.Quantity == current.Quantity {
+			return []walEntry{entry}, nil
+		}
+		entries, next := ix.opening(current)
+		adjustment := product.QuantityAdjustment(updated.Id, current.Quantity, updated.Quantity)
+		adjustment.Id = next
+		entry.Movement = &adjustment
+		return append(entries, entry), nil
+	})
+}
+
+func (s *jsonStore) Delete(ctx context.Context, id int, version int) error {
+	return s.write(ctx, func(ix *index) ([]walEntry, error) {
+		p, ok := ix.active(id)
+		if !ok {
+			return nil, product.ErrRepositoryProductNotFound
+		}
+		if version != 0 && p.Version != version {
+			return nil, product.ErrRepositoryVersionConflict
+		}
+
+		// the product stays marked as deleted until it is purged
+		p.DeletedAt = product.DeletionTime()
+		p.Version++
+		return []walEntry{{Op: opPut, Id: id, Product: &p}}, nil
+	})
+}
+
+func (s *jsonStore) Restore(ctx context.Context, id int) (domain.Product, error) {
+	var restored domain.Product
+	err := s.write(ctx, func(ix *index) ([]walEntry, error) {
+		p, ok := ix.products[id]
+		if !ok {
+			return nil, product.ErrRepositoryProductNotFound
+		}
+		if p.DeletedAt == nil {
+			return nil, product.ErrRepositoryProductNotDeleted
+		}
+		// another product may have taken the code value while it was deleted
+		if ix.codeValueTaken(id, p.CodeValue) {
+			return nil, product.ErrRepositoryProductCodeValueDuplicated
+		}
+
+		p.DeletedAt = nil
+		p.Version++
+		restored = p
+		return []walEntry{{Op: opPut, Id: id, Product: &p}}, nil
+	})
+	if err != nil {
+		return domain.Product{}, err
 	}
-	for i, p := range products {
-		if p.Id == product.Id {
-			products[i] = product
-			return s.saveProducts(products)
+	return restored, nil
+}
+
+func (s *jsonStore) Purge(ctx context.Context, before time.Time) ([]domain.Product, error) {
+	purged := make([]domain.Product, 0)
+	err := s.write(ctx, func(ix *index) ([]walEntry, error) {
+		// deleting from the index deletes the movements of the product too
+		entries := make([]walEntry, 0)
+		for _, p := range ix.sorted() {
+			if product.DeletedBefore(p, before) {
+				purged = append(purged, p)
+				entries = append(entries, walEntry{Op: opDelete, Id: p.Id})
+			}
 		}
+		return entries, nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return errors.New("product not found")
+	return purged, nil
 }
 
-func (s *jsonStore) Delete(id int) error {
-	products, err := s.loadProducts()
+func (s *jsonStore) Exists(ctx context.Context, codeValue string) (bool, error) {
+	exists := false
+	err := s.read(ctx, func(ix *index) error {
+		exists = ix.codeValueTaken(0, codeValue)
+		return nil
+	})
+	return exists, err
+}
+
+func (s *jsonStore) ExistsWithDifferentID(ctx context.Context, id int, codeValue string) (bool, error) {
+	exists := false
+	err := s.read(ctx, func(ix *index) error {
+		exists = ix.codeValueTaken(id, codeValue)
+		return nil
+	})
+	return exists, err
+}
+
+func (s *jsonStore) GetByExpiration(ctx context.Context, from, to domain.Date) ([]domain.Product, error) {
+	found := make([]domain.Product, 0)
+	err := s.read(ctx, func(ix *index) error {
+		for _, p := range ix.sortedActive() {
+			if product.ExpiresWithin(p, from, to) {
+				found = append(found, p)
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	for i, p := range products {
-		if p.Id == id {
-			products = append(products[:i], products[i+1:]...)
-			return s.saveProducts(products)
+	return found, nil
+}
+
+func (s *jsonStore) UnpublishExpired(ctx context.Context, before domain.Date) ([]domain.Product, error) {
+	unpublished := make([]domain.Product, 0)
+	err := s.write(ctx, func(ix *index) ([]walEntry, error) {
+		entries := make([]walEntry, 0)
+		for _, p := range ix.sortedActive() {
+			if p.IsPublished && product.ExpiresWithin(p, domain.Date{}, before) {
+				changed := p
+				changed.IsPublished = false
+				changed.Version++
+				unpublished = append(unpublished, changed)
+				entries = append(entries, walEntry{Op: opPut, Id: changed.Id, Product: &changed})
+			}
 		}
+		return entries, nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return errors.New("product not found")
+	return unpublished, nil
+}
+
+func (s *jsonStore) AddMovement(ctx context.Context, movement *domain.StockMovement) error {
+	return s.write(ctx, func(ix *index) ([]walEntry, error) {
+		p, ok := ix.active(movement.ProductId)
+		if !ok {
+			return nil, product.ErrRepositoryProductNotFound
+		}
+		if p.Quantity+movement.Quantity < 0 {
+			return nil, product.ErrRepositoryNegativeStock
+		}
+
+		// the movement and the new quantity go in the same log entry
+		entries, next := ix.opening(p)
+		p.Quantity += movement.Quantity
+		p.Version++
+		movement.Id = next
+		movement.Balance = p.Quantity
+		movement.CreatedAt = domain.MovementTime()
+		recorded := *movement
+		return append(entries, walEntry{Op: opPut, Id: p.Id, Product: &p, Movement: &recorded}), nil
+	})
 }
 
-func (s *jsonStore) Exists(codeValue string) bool {
-	products, err := s.loadProducts()
+func (s *jsonStore) GetMovements(ctx context.Context, productID int) ([]domain.StockMovement, error) {
+	var movements []domain.StockMovement
+	unopened := false
+	err := s.read(ctx, func(ix *index) error {
+		p, ok := ix.active(productID)
+		if !ok {
+			return product.ErrRepositoryProductNotFound
+		}
+		movements, unopened = ix.movementsOf(productID), ix.unopened(p)
+		return nil
+	})
 	if err != nil {
-		return false
+		return nil, err
+	}
+	if !unopened {
+		return movements, nil
 	}
+
+	// the product predates the stock ledger, which is opened with its quantity before reading it
+	err = s.write(ctx, func(ix *index) ([]walEntry, error) {
+		p, ok := ix.active(productID)
+		if !ok {
+			return nil, product.ErrRepositoryProductNotFound
+		}
+		entries, _ := ix.opening(p)
+		return entries, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetMovements(ctx, productID)
+}
+
+// changed returns the changes of the entries, one per ID and in the order of the entries, with each product as
+// it is before applying them and as they leave it. A removed product is left as it was before applying them
+func (ix *index) changed(entries []walEntry) []product.Change {
+	changes := make([]product.Change, 0, len(entries))
+	positions := make(map[int]int)
+	for _, entry := range entries {
+		p := ix.products[entry.Id]
+		if entry.Op == opPut {
+			p = *entry.Product
+		}
+		if i, ok := positions[entry.Id]; ok {
+			changes[i].After = p
+			continue
+		}
+
+		change := product.Change{After: p}
+		if before, ok := ix.products[entry.Id]; ok {
+			change.Before = &before
+		}
+		positions[entry.Id] = len(changes)
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// unopened reports whether the product has stock but no movement explaining it
+func (ix *index) unopened(p domain.Product) bool {
+	return p.Quantity != 0 && len(ix.byProduct[p.Id]) == 0
+}
+
+// opening returns the entry opening the ledger of a product that predates the movements
+// with a receipt of its quantity, and the ID of the next movement
+func (ix *index) opening(p domain.Product) ([]walEntry, int) {
+	next := ix.lastMovementID + 1
+	if !ix.unopened(p) {
+		return nil, next
+	}
+	receipt := product.OpeningReceipt(p.Id, p.Quantity)
+	receipt.Id = next
+	return []walEntry{{Op: opPut, Id: p.Id, Product: &p, Movement: &receipt}}, next + 1
+}
+
+// readProducts reads the products of path without validating them
+func readProducts(path string) ([]domain.Product, error) {
+	var products []domain.Product
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal([]byte(file), &products)
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// writeProducts saves the products in path atomically
+func writeProducts(path string, products []domain.Product) error {
+	bytes, err := json.Marshal(products)
+	if err != nil {
+		return err
+	}
+	return writeFile(path, bytes)
+}
+
+// sequencePath is the file keeping the last assigned ID
+func sequencePath(path string) string {
+	return path + ".seq"
+}
+
+// readSequence returns the last assigned ID, zero when there is no sequence yet
+func readSequence(path string) (int, error) {
+	bytes, err := os.ReadFile(sequencePath(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	last, err := strconv.Atoi(strings.TrimSpace(string(bytes)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid sequence in %s: %w", sequencePath(path), err)
+	}
+	return last, nil
+}
+
+// writeSequence saves the last assigned ID
+func writeSequence(path string, last int) error {
+	return writeFile(sequencePath(path), []byte(strconv.Itoa(last)+"\n"))
+}
+
+// maxID returns the highest ID of the products
+func maxID(products []domain.Product) int {
+	max := 0
+	for _, p := range products {
+		if p.Id > max {
+			max = p.Id
+		}
+	}
+	return max
+}
+
+// duplicatedIDs returns the IDs appearing more than once
+func duplicatedIDs(products []domain.Product) []int {
+	seen := make(map[int]int, len(products))
+	ids := make([]int, 0)
 	for _, p := range products {
-		if p.CodeValue == codeValue {
-			return true
+		seen[p.Id]++
+		if seen[p.Id] == 2 {
+			ids = append(ids, p.Id)
 		}
 	}
-	return false
+	sort.Ints(ids)
+	return ids
+}
+
+// writeFile writes a temporary file in the same directory and renames it,
+// so a crash never leaves the file truncated
+func writeFile(path string, bytes []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(bytes); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
 }
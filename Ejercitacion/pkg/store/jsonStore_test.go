@@ -0,0 +1,294 @@
+package store_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gostorage/internal/domain"
+	"gostorage/internal/product"
+	"gostorage/internal/product/producttest"
+	"gostorage/pkg/store"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJsonStore(t *testing.T) {
+	producttest.TestRepository(t, func(t *testing.T) product.Repository {
+		return store.NewJsonStore(newFile(t, "[]"))
+	})
+}
+
+func TestJsonStoreReplaysTheLogOnReopen(t *testing.T) {
+	ctx := context.Background()
+	path := newFile(t, "[]")
+
+	first := store.NewJsonStore(path)
+	kept, dropped := newProduct("W1"), newProduct("W2")
+	for _, p := range []*domain.Product{&kept, &dropped} {
+		if err := first.Create(ctx, p); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	kept.Name = "Renamed"
+	if err := first.Update(ctx, &kept); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := first.Delete(ctx, dropped.Id, 0); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	// Nothing was compacted, the writes are only in the log
+	if got := readFile(t, path); got != "[]" {
+		t.Fatalf("snapshot: got %s", got)
+	}
+
+	// A new store over the same file sees them
+	second := store.NewJsonStore(path)
+	all, err := second.GetAll(ctx)
+	if err != nil || len(all) != 1 || all[0].Name != "Renamed" || all[0].Version != kept.Version {
+		t.Fatalf("get all: got %+v, %v", all, err)
+	}
+	movements, err := second.GetMovements(ctx, kept.Id)
+	if err != nil || len(movements) != 1 || movements[0].Type != domain.MovementReceipt {
+		t.Fatalf("get movements: got %+v, %v", movements, err)
+	}
+}
+
+func TestJsonStoreIgnoresACutLastLine(t *testing.T) {
+	ctx := context.Background()
+	path := newFile(t, "[]")
+
+	p := newProduct("C1")
+	if err := store.NewJsonStore(path).Create(ctx, &p); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	// A write that stopped halfway leaves a line without its end
+	wal, err := os.OpenFile(path+".wal", os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wal.WriteString(`{"op":"put","id":2,"product":{"id":2,"name":"Cut`); err != nil {
+		t.Fatal(err)
+	}
+	wal.Close()
+
+	reopened := store.NewJsonStore(path)
+	all, err := reopened.GetAll(ctx)
+	if err != nil || len(all) != 1 || all[0].Id != p.Id {
+		t.Fatalf("get all: got %+v, %v", all, err)
+	}
+
+	// The next write replaces the cut line and the log reads whole again
+	next := newProduct("C2")
+	if err := reopened.Create(ctx, &next); err != nil || next.Id != 2 {
+		t.Fatalf("create after cut: got %d, %v", next.Id, err)
+	}
+	if strings.Contains(readFile(t, path+".wal"), "Cut") {
+		t.Fatalf("the cut line is still in the log")
+	}
+	all, err = store.NewJsonStore(path).GetAll(ctx)
+	if err != nil || len(all) != 2 {
+		t.Fatalf("get all after reopen: got %+v, %v", all, err)
+	}
+}
+
+func TestJsonStoreCompaction(t *testing.T) {
+	ctx := context.Background()
+	path := newFile(t, "[]")
+
+	// Every write compacts
+	rp := store.NewJsonStoreWithOptions(path, store.Options{CompactEvery: 1})
+	kept, purged := newProduct("K1"), newProduct("K2")
+	for _, p := range []*domain.Product{&kept, &purged} {
+		if err := rp.Create(ctx, p); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	sale := domain.StockMovement{ProductId: kept.Id, Type: domain.MovementSale, Quantity: -3}
+	if err := rp.AddMovement(ctx, &sale); err != nil {
+		t.Fatalf("add movement: %v", err)
+	}
+	if err := rp.Delete(ctx, purged.Id, 0); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := rp.Purge(ctx, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+
+	// The log is empty and the snapshot has what it held
+	if got := readFile(t, path+".wal"); got != "" {
+		t.Fatalf("log after compacting: got %q", got)
+	}
+	var products []domain.Product
+	if err := json.Unmarshal([]byte(readFile(t, path)), &products); err != nil || len(products) != 1 || products[0].Id != kept.Id || products[0].Quantity != 7 {
+		t.Fatalf("snapshot: got %+v, %v", products, err)
+	}
+
+	// The sequence keeps the ID of the purged product and the movements stay apart
+	if got := strings.TrimSpace(readFile(t, path+".seq")); got != "2" {
+		t.Fatalf("sequence: got %q, want 2", got)
+	}
+	var movements []domain.StockMovement
+	if err := json.Unmarshal([]byte(readFile(t, path+".movements")), &movements); err != nil || len(movements) != 2 || movements[1] != sale {
+		t.Fatalf("movements: got %+v, %v", movements, err)
+	}
+
+	// A new store loads the compacted files
+	reopened := store.NewJsonStore(path)
+	if got, err := reopened.GetMovements(ctx, kept.Id); err != nil || len(got) != 2 {
+		t.Fatalf("get movements after reopen: got %+v, %v", got, err)
+	}
+}
+
+func TestJsonStoreDuplicatedID(t *testing.T) {
+	deleted := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		products []domain.Product
+		wantErr  error
+	}{
+		{"distinct ids", withIDs(1, 2, 3), nil},
+		{"repeated id", withIDs(1, 2, 1), store.ErrDuplicatedID},
+		{"repeated by a deleted product", append(withIDs(1, 2), domain.Product{Id: 2, CodeValue: "old", DeletedAt: &deleted}), store.ErrDuplicatedID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rp := store.NewJsonStore(newFile(t, marshal(t, tt.products)))
+
+			// Reads and writes refuse the file alike
+			if _, err := rp.GetAll(context.Background()); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("get all: got %v, want %v", err, tt.wantErr)
+			}
+			p := newProduct("NEW")
+			if err := rp.Create(context.Background(), &p); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("create: got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestJsonStoreDoesNotReuseIDs(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		opts store.Options
+		// remove takes the last product out and returns the store that creates the next one
+		remove func(t *testing.T, path string, rp product.Repository, id int) product.Repository
+	}{
+		{
+			name: "after delete",
+			remove: func(t *testing.T, path string, rp product.Repository, id int) product.Repository {
+				if err := rp.Delete(ctx, id, 0); err != nil {
+					t.Fatalf("delete: %v", err)
+				}
+				return rp
+			},
+		},
+		{
+			name:   "after purge",
+			remove: purge,
+		},
+		{
+			name: "after purge and reopen",
+			remove: func(t *testing.T, path string, rp product.Repository, id int) product.Repository {
+				purge(t, path, rp, id)
+				return store.NewJsonStore(path)
+			},
+		},
+		{
+			name: "after purge and compaction",
+			opts: store.Options{CompactEvery: 1},
+			remove: func(t *testing.T, path string, rp product.Repository, id int) product.Repository {
+				purge(t, path, rp, id)
+				return store.NewJsonStore(path)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := newFile(t, "[]")
+			rp := store.NewJsonStoreWithOptions(path, tt.opts)
+			first, last := newProduct("I1"), newProduct("I2")
+			for _, p := range []*domain.Product{&first, &last} {
+				if err := rp.Create(ctx, p); err != nil {
+					t.Fatalf("create: %v", err)
+				}
+			}
+
+			next := newProduct("I3")
+			if err := tt.remove(t, path, rp, last.Id).Create(ctx, &next); err != nil || next.Id != last.Id+1 {
+				t.Fatalf("create after removing %d: got %d, %v", last.Id, next.Id, err)
+			}
+		})
+	}
+}
+
+// purge deletes the product and purges it for good
+func purge(t *testing.T, path string, rp product.Repository, id int) product.Repository {
+	t.Helper()
+	if err := rp.Delete(context.Background(), id, 0); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if purged, err := rp.Purge(context.Background(), time.Now().Add(time.Hour)); err != nil || len(purged) != 1 {
+		t.Fatalf("purge: got %+v, %v", purged, err)
+	}
+	return rp
+}
+
+// withIDs returns a product for each ID, with a code value telling them apart
+func withIDs(ids ...int) []domain.Product {
+	products := make([]domain.Product, 0, len(ids))
+	for i, id := range ids {
+		p := newProduct(fmt.Sprintf("P%d", i))
+		p.Id, p.Version = id, 1
+		products = append(products, p)
+	}
+	return products
+}
+
+// marshal returns the products as the store file holds them
+func marshal(t *testing.T, products []domain.Product) string {
+	t.Helper()
+	bytes, err := json.Marshal(products)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(bytes)
+}
+
+// newFile creates the store file with content in a temporary directory, the store expects it to exist
+func newFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "products.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readFile returns the content of path
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(bytes)
+}
+
+// newProduct returns a valid product with the given code value
+func newProduct(codeValue string) domain.Product {
+	return domain.Product{
+		Name:        "Product " + codeValue,
+		Quantity:    10,
+		CodeValue:   codeValue,
+		IsPublished: true,
+		Expiration:  domain.NewDate(2030, time.January, 31),
+		Price:       12.5,
+	}
+}
@@ -0,0 +1,83 @@
+//go:build unix
+
+package store_test
+
+import (
+	"bufio"
+	"context"
+	"gostorage/pkg/store"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// holdLockEnv tells the test binary to act as another process holding the lock of a store
+const holdLockEnv = "STORE_TEST_HOLD_LOCK"
+
+// TestHoldLock is not a test, it is the second process of TestJsonStoreLockExcludesOtherProcesses.
+// It takes the lock of the store, says so and keeps it until its input closes
+func TestHoldLock(t *testing.T) {
+	path := os.Getenv(holdLockEnv)
+	if path == "" {
+		t.Skip("only run as the helper process")
+	}
+
+	file, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout.WriteString("locked\n")
+	bufio.NewReader(os.Stdin).ReadString('\n')
+}
+
+func TestJsonStoreLockExcludesOtherProcesses(t *testing.T) {
+	path := newFile(t, "[]")
+
+	helper := exec.Command(os.Args[0], "-test.run=^TestHoldLock$")
+	helper.Env = append(os.Environ(), holdLockEnv+"="+path)
+	release, err := helper.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := helper.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := helper.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer helper.Wait()
+	if line, err := bufio.NewReader(output).ReadString('\n'); err != nil || line != "locked\n" {
+		release.Close()
+		t.Fatalf("helper: got %q, %v", line, err)
+	}
+
+	// The write waits while the other process holds the lock
+	done := make(chan error, 1)
+	go func() {
+		p := newProduct("L1")
+		done <- store.NewJsonStore(path).Create(context.Background(), &p)
+	}()
+	select {
+	case err := <-done:
+		release.Close()
+		t.Fatalf("create finished with the lock taken: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// and goes on once it is released
+	release.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("create still waiting after the lock was released")
+	}
+}
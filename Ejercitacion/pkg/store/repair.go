@@ -0,0 +1,99 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+
+	"gostorage/internal/domain"
+)
+
+// RepairMode tells how the repeated IDs are fixed
+type RepairMode string
+
+const (
+	// Renumber gives every repetition a new ID and keeps all the products
+	Renumber RepairMode = "renumber"
+	// Dedupe keeps the first appearance of each ID and drops the rest
+	Dedupe RepairMode = "dedupe"
+)
+
+var (
+	// ErrInvalidRepairMode is returned when the mode is neither renumber nor dedupe
+	ErrInvalidRepairMode = errors.New("invalid repair mode, expected renumber or dedupe")
+)
+
+// RepairChange describes what was done with a repetition
+type RepairChange struct {
+	// OldID is the repeated ID
+	OldID int
+	// NewID is the assigned ID, zero when the product was dropped
+	NewID int
+	// Product is the affected product
+	Product domain.Product
+}
+
+// Repair fixes the repeated IDs of the snapshot in path, applies the log to it and
+// compacts it adjusting the sequence. With dryRun it only reports the changes without writing anything
+func Repair(path string, mode RepairMode, dryRun bool) ([]RepairChange, error) {
+	if mode != Renumber && mode != Dedupe {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRepairMode, mode)
+	}
+
+	unlock, err := lockFile(path, true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	products, err := readProducts(path)
+	if err != nil {
+		return nil, err
+	}
+	last, err := readSequence(path)
+	if err != nil {
+		return nil, err
+	}
+	if max := maxID(products); max > last {
+		last = max
+	}
+
+	// the new IDs must be above the ones the log already used
+	logged := newIndex(nil, last)
+	if _, _, err := replay(path, 0, logged); err != nil {
+		return nil, err
+	}
+	last = logged.lastID
+
+	changes := make([]RepairChange, 0)
+	seen := make(map[int]bool, len(products))
+	repaired := make([]domain.Product, 0, len(products))
+	for _, p := range products {
+		if !seen[p.Id] {
+			seen[p.Id] = true
+			repaired = append(repaired, p)
+			continue
+		}
+
+		change := RepairChange{OldID: p.Id, Product: p}
+		if mode == Renumber {
+			last++
+			p.Id = last
+			change.NewID = p.Id
+			repaired = append(repaired, p)
+		}
+		changes = append(changes, change)
+	}
+
+	if dryRun || len(changes) == 0 {
+		return changes, nil
+	}
+
+	ix, _, _, err := loadIndex(path, repaired, last)
+	if err != nil {
+		return nil, err
+	}
+	if err := compact(path, ix); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
@@ -0,0 +1,144 @@
+package store_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gostorage/internal/domain"
+	"gostorage/pkg/store"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRepair(t *testing.T) {
+	// P0 and P2 share ID 1, P1 and P4 share ID 2
+	snapshot := withIDs(1, 2, 1, 3, 2)
+	logged := withIDs(6)[0]
+	logged.CodeValue = "LOG"
+
+	tests := []struct {
+		name   string
+		mode   store.RepairMode
+		dryRun bool
+		// log is the content of the write log before repairing
+		log         string
+		wantChanges []store.RepairChange
+		// wantCodes are the code values by ID after repairing, nil when the file is left untouched
+		wantCodes map[int]string
+		wantErr   error
+	}{
+		{
+			name: "renumber",
+			mode: store.Renumber,
+			wantChanges: []store.RepairChange{
+				{OldID: 1, NewID: 4, Product: snapshot[2]},
+				{OldID: 2, NewID: 5, Product: snapshot[4]},
+			},
+			wantCodes: map[int]string{1: "P0", 2: "P1", 3: "P3", 4: "P2", 5: "P4"},
+		},
+		{
+			name: "renumber above the ids of the log",
+			mode: store.Renumber,
+			log:  logEntry(t, logged),
+			wantChanges: []store.RepairChange{
+				{OldID: 1, NewID: 7, Product: snapshot[2]},
+				{OldID: 2, NewID: 8, Product: snapshot[4]},
+			},
+			wantCodes: map[int]string{1: "P0", 2: "P1", 3: "P3", 6: "LOG", 7: "P2", 8: "P4"},
+		},
+		{
+			name: "dedupe",
+			mode: store.Dedupe,
+			wantChanges: []store.RepairChange{
+				{OldID: 1, Product: snapshot[2]},
+				{OldID: 2, Product: snapshot[4]},
+			},
+			wantCodes: map[int]string{1: "P0", 2: "P1", 3: "P3"},
+		},
+		{
+			name:   "renumber dry run",
+			mode:   store.Renumber,
+			dryRun: true,
+			wantChanges: []store.RepairChange{
+				{OldID: 1, NewID: 4, Product: snapshot[2]},
+				{OldID: 2, NewID: 5, Product: snapshot[4]},
+			},
+		},
+		{
+			name:   "dedupe dry run",
+			mode:   store.Dedupe,
+			dryRun: true,
+			wantChanges: []store.RepairChange{
+				{OldID: 1, Product: snapshot[2]},
+				{OldID: 2, Product: snapshot[4]},
+			},
+		},
+		{
+			name:    "invalid mode",
+			mode:    "merge",
+			wantErr: store.ErrInvalidRepairMode,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := marshal(t, snapshot)
+			path := newFile(t, content)
+			if tt.log != "" {
+				if err := os.WriteFile(path+".wal", []byte(tt.log), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			changes, err := store.Repair(path, tt.mode, tt.dryRun)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("repair: got %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(changes, tt.wantChanges) {
+				t.Fatalf("changes: got %+v, want %+v", changes, tt.wantChanges)
+			}
+
+			if tt.wantCodes == nil {
+				if got := readFile(t, path); got != content {
+					t.Fatalf("file changed without writing: got %s", got)
+				}
+				if _, err := os.Stat(path + ".seq"); !errors.Is(err, os.ErrNotExist) {
+					t.Fatalf("sequence written without writing: got %v", err)
+				}
+				return
+			}
+
+			// The store loads the repaired file with the log already in it
+			if tt.log != "" && readFile(t, path+".wal") != "" {
+				t.Fatalf("log left after repairing: got %q", readFile(t, path+".wal"))
+			}
+			all, err := store.NewJsonStore(path).GetAll(context.Background())
+			if err != nil {
+				t.Fatalf("get all: %v", err)
+			}
+			codes := make(map[int]string, len(all))
+			for _, p := range all {
+				codes[p.Id] = p.CodeValue
+			}
+			if !reflect.DeepEqual(codes, tt.wantCodes) {
+				t.Fatalf("products: got %v, want %v", codes, tt.wantCodes)
+			}
+
+			// A second repair finds nothing to do
+			if changes, err := store.Repair(path, tt.mode, false); err != nil || len(changes) != 0 {
+				t.Fatalf("repair again: got %+v, %v", changes, err)
+			}
+		})
+	}
+}
+
+// logEntry returns the line of the write log storing p
+func logEntry(t *testing.T, p domain.Product) string {
+	t.Helper()
+	bytes, err := json.Marshal(map[string]any{"op": "put", "id": p.Id, "product": p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(bytes)) + "\n"
+}
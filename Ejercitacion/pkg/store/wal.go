@@ -0,0 +1,320 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"gostorage/internal/domain"
+)
+
+const (
+	// opPut stores the whole product under its ID
+	opPut = "put"
+	// opDelete removes the product with the ID
+	opDelete = "delete"
+)
+
+// walEntry is a line of the write log. Applying it twice leaves the
+// same result, so the log can be replayed over a snapshot that already includes it
+type walEntry struct {
+	Op      string          `json:"op"`
+	Id      int             `json:"id"`
+	Product *domain.Product `json:"product,omitempty"`
+	// Movement goes with a put that changed the quantity
+	Movement *domain.StockMovement `json:"movement,omitempty"`
+}
+
+// walPath is the write log next to the snapshot
+func walPath(path string) string {
+	return path + ".wal"
+}
+
+// movementsPath is the file with the compacted stock movements
+func movementsPath(path string) string {
+	return path + ".movements"
+}
+
+// index keeps the products in memory, indexed by ID and by code value,
+// along with their stock movements
+type index struct {
+	// products has the deleted ones too, byCode only the ones not deleted
+	products map[int]domain.Product
+	byCode   map[string]int
+	// lastID is the highest ID ever assigned, even if the product is gone
+	lastID int
+
+	movements map[int]domain.StockMovement
+	// byProduct has the IDs of the movements of each product in order
+	byProduct      map[int][]int
+	lastMovementID int
+}
+
+// newIndex indexes the products, which must not have repeated IDs
+func newIndex(products []domain.Product, lastID int) *index {
+	ix := &index{
+		products:  make(map[int]domain.Product, len(products)),
+		byCode:    make(map[string]int, len(products)),
+		lastID:    lastID,
+		movements: make(map[int]domain.StockMovement),
+		byProduct: make(map[int][]int),
+	}
+	for _, p := range products {
+		ix.put(p)
+	}
+	return ix
+}
+
+// loadIndex indexes the products and the compacted movements and applies the whole log to them.
+// It also returns the offset and the number of entries of the log
+func loadIndex(path string, products []domain.Product, lastID int) (*index, int64, int, error) {
+	ix := newIndex(products, lastID)
+
+	var movements []domain.StockMovement
+	bytes, err := os.ReadFile(movementsPath(path))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, 0, 0, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(bytes, &movements); err != nil {
+			return nil, 0, 0, err
+		}
+	}
+	for _, m := range movements {
+		ix.putMovement(m)
+	}
+
+	offset, entries, err := replay(path, 0, ix)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return ix, offset, entries, nil
+}
+
+func (ix *index) put(p domain.Product) {
+	// the products saved before they had versions start at version 1
+	if p.Version == 0 {
+		p.Version = 1
+	}
+	if old, ok := ix.products[p.Id]; ok && ix.byCode[old.CodeValue] == p.Id {
+		delete(ix.byCode, old.CodeValue)
+	}
+	ix.products[p.Id] = p
+	// a deleted product frees its code value
+	if p.DeletedAt == nil {
+		ix.byCode[p.CodeValue] = p.Id
+	}
+	if p.Id > ix.lastID {
+		ix.lastID = p.Id
+	}
+}
+
+func (ix *index) delete(id int) {
+	if old, ok := ix.products[id]; ok && ix.byCode[old.CodeValue] == id {
+		delete(ix.byCode, old.CodeValue)
+	}
+	delete(ix.products, id)
+
+	// the movements go with the product, as with the foreign key in MySQL
+	for _, movementID := range ix.byProduct[id] {
+		delete(ix.movements, movementID)
+	}
+	delete(ix.byProduct, id)
+}
+
+// putMovement stores the movement, storing it again does not duplicate it
+func (ix *index) putMovement(m domain.StockMovement) {
+	if _, ok := ix.movements[m.Id]; !ok {
+		ix.byProduct[m.ProductId] = append(ix.byProduct[m.ProductId], m.Id)
+	}
+	ix.movements[m.Id] = m
+	if m.Id > ix.lastMovementID {
+		ix.lastMovementID = m.Id
+	}
+}
+
+// movementsOf returns the movements of the product ordered by ID
+func (ix *index) movementsOf(productID int) []domain.StockMovement {
+	ids := append([]int(nil), ix.byProduct[productID]...)
+	sort.Ints(ids)
+
+	movements := make([]domain.StockMovement, 0, len(ids))
+	for _, id := range ids {
+		movements = append(movements, ix.movements[id])
+	}
+	return movements
+}
+
+// sortedMovements returns all the movements ordered by ID
+func (ix *index) sortedMovements() []domain.StockMovement {
+	movements := make([]domain.StockMovement, 0, len(ix.movements))
+	for _, m := range ix.movements {
+		movements = append(movements, m)
+	}
+	sort.Slice(movements, func(i, j int) bool {
+		return movements[i].Id < movements[j].Id
+	})
+	return movements
+}
+
+func (ix *index) apply(e walEntry) {
+	switch e.Op {
+	case opPut:
+		ix.put(*e.Product)
+		if e.Movement != nil {
+			ix.putMovement(*e.Movement)
+		}
+	case opDelete:
+		ix.delete(e.Id)
+		if e.Id > ix.lastID {
+			ix.lastID = e.Id
+		}
+	}
+}
+
+// codeValueTaken reports whether a product with another ID uses the code value
+func (ix *index) codeValueTaken(id int, codeValue string) bool {
+	owner, ok := ix.byCode[codeValue]
+	return ok && owner != id
+}
+
+// active returns the product with the ID if it exists and is not deleted
+func (ix *index) active(id int) (domain.Product, bool) {
+	p, ok := ix.products[id]
+	if !ok || p.DeletedAt != nil {
+		return domain.Product{}, false
+	}
+	return p, true
+}
+
+// sortedActive returns the products not deleted ordered by ID
+func (ix *index) sortedActive() []domain.Product {
+	products := make([]domain.Product, 0, len(ix.products))
+	for _, p := range ix.sorted() {
+		if p.DeletedAt == nil {
+			products = append(products, p)
+		}
+	}
+	return products
+}
+
+// sorted returns all the products ordered by ID, the deleted ones included
+func (ix *index) sorted() []domain.Product {
+	products := make([]domain.Product, 0, len(ix.products))
+	for _, p := range ix.products {
+		products = append(products, p)
+	}
+	sort.Slice(products, func(i, j int) bool {
+		return products[i].Id < products[j].Id
+	})
+	return products
+}
+
+// replay applies the log entries from offset on. An unfinished last line
+// is a write that was cut short and is ignored. It returns the offset
+// after the last whole line and the number of entries applied
+func replay(path string, offset int64, ix *index) (int64, int, error) {
+	file, err := os.Open(walPath(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return offset, 0, nil
+	}
+	if err != nil {
+		return offset, 0, err
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return offset, 0, err
+	}
+
+	applied := 0
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return offset, applied, nil
+		}
+		if err != nil {
+			return offset, applied, err
+		}
+
+		entry := walEntry{}
+		if err := json.Unmarshal(bytes.TrimSpace(line), &entry); err != nil || !entry.valid() {
+			return offset, applied, fmt.Errorf("corrupted log %s at offset %d", walPath(path), offset)
+		}
+		ix.apply(entry)
+		offset += int64(len(line))
+		applied++
+	}
+}
+
+func (e walEntry) valid() bool {
+	switch e.Op {
+	case opPut:
+		return e.Product != nil && e.Product.Id == e.Id && e.Id > 0 &&
+			(e.Movement == nil || e.Movement.ProductId == e.Id && e.Movement.Id > 0)
+	case opDelete:
+		return e.Id > 0
+	default:
+		return false
+	}
+}
+
+// appendEntries appends the entries to the log at offset, dropping whatever a
+// write cut short left after it, and returns the new offset. They are
+// written together, a crash leaves at most the last line cut
+func appendEntries(path string, offset int64, entries []walEntry) (int64, error) {
+	lines := make([]byte, 0)
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return offset, err
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+
+	file, err := os.OpenFile(walPath(path), os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return offset, err
+	}
+	defer file.Close()
+
+	if err := file.Truncate(offset); err != nil {
+		return offset, err
+	}
+	if _, err := file.WriteAt(lines, offset); err != nil {
+		return offset, err
+	}
+	if err := file.Sync(); err != nil {
+		return offset, err
+	}
+	return offset + int64(len(lines)), nil
+}
+
+// compact writes the snapshot with the state of the index and empties the log. If it
+// stops between the two steps the log is applied again over the new snapshot
+// without changing the result
+func compact(path string, ix *index) error {
+	if err := writeSequence(path, ix.lastID); err != nil {
+		return err
+	}
+	movements, err := json.Marshal(ix.sortedMovements())
+	if err != nil {
+		return err
+	}
+	if err := writeFile(movementsPath(path), movements); err != nil {
+		return err
+	}
+	if err := writeProducts(path, ix.sorted()); err != nil {
+		return err
+	}
+	if err := os.Truncate(walPath(path), 0); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
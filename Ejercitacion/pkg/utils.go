@@ -1,14 +1,89 @@
 package pkg
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"time"
 )
 
-func IsValidDate(dateString string) bool {
-	layout := "2006-01-02"
-	location, _ := time.LoadLocation("UTC")
+// CanonicalDateLayout is the layout dates are stored and rendered with, it is always accepted on input
+const CanonicalDateLayout = "2006-01-02"
+
+var (
+	// ErrInvalidDate is returned when a date does not match any accepted layout
+	ErrInvalidDate = errors.New("invalid date")
+
+	// NamedDateLayouts are the layouts that can be enabled by name with SetDateLayouts
+	NamedDateLayouts = map[string]string{
+		"iso8601":    CanonicalDateLayout,
+		"dd/mm/yyyy": "02/01/2006",
+		"rfc3339":    time.RFC3339,
+	}
+
+	// knownDateLayouts are all the named layouts, the ones accepted by default
+	knownDateLayouts = []string{CanonicalDateLayout, "02/01/2006", time.RFC3339}
+
+	// dateLayouts are the accepted input layouts, tried in order
+	dateLayouts   = knownDateLayouts
+	dateLayoutsMu sync.RWMutex
+)
+
+// KnownDateLayouts returns every named layout, to read data written before the accepted layouts were narrowed
+func KnownDateLayouts() []string {
+	return append([]string(nil), knownDateLayouts...)
+}
 
-	if _, err := time.ParseInLocation(layout, dateString, location); err != nil {
+// SetDateLayouts replaces the accepted input layouts, each one a name from NamedDateLayouts or a Go layout
+func SetDateLayouts(layouts ...string) error {
+	if len(layouts) == 0 {
+		return fmt.Errorf("%w: no layouts", ErrInvalidDate)
+	}
+
+	resolved := make([]string, 0, len(layouts))
+	for _, layout := range layouts {
+		layout = strings.TrimSpace(layout)
+		if named, ok := NamedDateLayouts[strings.ToLower(layout)]; ok {
+			layout = named
+		}
+		// a Go layout must mention the year, month and day
+		if !strings.Contains(layout, "2006") || !strings.Contains(layout, "01") && !strings.Contains(layout, "Jan") || !strings.Contains(layout, "02") {
+			return fmt.Errorf("%w: unknown layout %q", ErrInvalidDate, layout)
+		}
+		resolved = append(resolved, layout)
+	}
+
+	dateLayoutsMu.Lock()
+	defer dateLayoutsMu.Unlock()
+	dateLayouts = resolved
+	return nil
+}
+
+// ParseDate parses value with the accepted layouts and returns the calendar date at midnight UTC.
+// Timestamps keep the date of their own offset
+func ParseDate(value string) (time.Time, error) {
+	dateLayoutsMu.RLock()
+	layouts := dateLayouts
+	dateLayoutsMu.RUnlock()
+
+	return ParseDateWith(value, append([]string{CanonicalDateLayout}, layouts...)...)
+}
+
+// ParseDateWith parses value with the given layouts instead of the accepted ones
+func ParseDateWith(value string, layouts ...string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%w: %q", ErrInvalidDate, value)
+}
+
+func IsValidDate(dateString string) bool {
+	if _, err := ParseDate(dateString); err != nil {
 		return false
 	}
 
@@ -0,0 +1,42 @@
+package validate
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Check builds a rule from a predicate, it fails with code and message
+// when the predicate does not hold
+func Check[T any](code, message string, ok func(T) bool) Rule[T] {
+	return func(ctx context.Context, value T) (*Violation, error) {
+		if ok(value) {
+			return nil, nil
+		}
+		return &Violation{code, message}, nil
+	}
+}
+
+// NotBlank requires a text with more than spaces
+func NotBlank() Rule[string] {
+	return Check("REQUIRED", "is required", func(value string) bool { return strings.TrimSpace(value) != "" })
+}
+
+// MaxLength requires a text of at most n characters
+func MaxLength(n int) Rule[string] {
+	return Check("MAX_LENGTH", fmt.Sprintf("must be at most %d characters", n), func(value string) bool {
+		return utf8.RuneCountInString(value) <= n
+	})
+}
+
+// Min requires a value greater than or equal to min
+func Min[T cmp.Ordered](min T) Rule[T] {
+	return Check("MIN", fmt.Sprintf("must be at least %v", min), func(value T) bool { return value >= min })
+}
+
+// Max requires a value less than or equal to max
+func Max[T cmp.Ordered](max T) Rule[T] {
+	return Check("MAX", fmt.Sprintf("must be at most %v", max), func(value T) bool { return value <= max })
+}
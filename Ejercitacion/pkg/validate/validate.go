@@ -0,0 +1,77 @@
+package validate
+
+import (
+	"context"
+	"errors"
+	"gostorage/pkg/apperr"
+	"strings"
+)
+
+// ErrInvalid is returned when any rule fails, with a field per violation
+var ErrInvalid = apperr.New(apperr.Invalid, "VALIDATION_FAILED", "validation failed")
+
+// Violation is the result of a rule that does not hold
+type Violation struct {
+	Code    string
+	Message string
+}
+
+// Rule checks a value and returns the violation when it fails. The error is for
+// the checks that could not be made
+type Rule[T any] func(ctx context.Context, value T) (*Violation, error)
+
+// Field declares the rules of a JSON field of E
+type Field[E any] struct {
+	name  string
+	check func(ctx context.Context, entity E) (*apperr.FieldError, error)
+}
+
+// Validator is the list of fields of E with their rules. All the fields are
+// checked in one pass and each one reports only its first failing rule
+type Validator[E any] []Field[E]
+
+// New creates a validator with the given fields
+func New[E any](fields ...Field[E]) Validator[E] {
+	return fields
+}
+
+// Validate returns nil if entity satisfies every rule, otherwise ErrInvalid
+// with the violations as fields
+func (v Validator[E]) Validate(ctx context.Context, entity E) error {
+	var violations []apperr.FieldError
+	for _, field := range v {
+		violation, err := field.check(ctx, entity)
+		if err != nil {
+			return err
+		}
+		if violation != nil {
+			violations = append(violations, *violation)
+		}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+
+	messages := make([]string, len(violations))
+	for i, violation := range violations {
+		messages[i] = violation.Field + " " + violation.Message
+	}
+	return ErrInvalid.Wrap(errors.New(strings.Join(messages, ", "))).WithFields(violations...)
+}
+
+// Value declares the field name of E, which is read with get
+func Value[E, T any](name string, get func(E) T, rules ...Rule[T]) Field[E] {
+	return Field[E]{name, func(ctx context.Context, entity E) (*apperr.FieldError, error) {
+		value := get(entity)
+		for _, rule := range rules {
+			violation, err := rule(ctx, value)
+			if err != nil {
+				return nil, err
+			}
+			if violation != nil {
+				return &apperr.FieldError{Field: name, Code: violation.Code, Message: violation.Message}, nil
+			}
+		}
+		return nil, nil
+	}}
+}
@@ -0,0 +1,35 @@
+package web
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ETag returns the tag of a version of a resource
+func ETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// IfMatch reports whether the tag satisfies the If-Match header. It uses the strong comparison,
+// so a weak tag in the header never matches
+func IfMatch(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// IfNoneMatch reports whether the tag matches any in the If-None-Match header,
+// in which case the response is 304. It uses the weak comparison
+func IfNoneMatch(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
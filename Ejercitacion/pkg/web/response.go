@@ -1,33 +1,23 @@
 package web
 
 import (
-	"net/http"
+	"gostorage/pkg/apperr"
 
 	"github.com/gin-gonic/gin"
 )
 
-type errorResponse struct {
-	Status  int    `json:"status"`
-	Code    string `json:"code"`
-	Message string `json:"message"`
-}
-
 type response struct {
 	Data interface{} `json:"data"`
 }
 
-// Success escribe una respuesta exitosa
+// Success writes a successful response
 func Success(ctx *gin.Context, status int, data interface{}) {
 	ctx.JSON(status, response{
 		Data: data,
 	})
 }
 
-// Failure escribe una respuesta fallida
-func Failure(ctx *gin.Context, status int, err error) {
-	ctx.JSON(status, errorResponse{
-		Message: err.Error(),
-		Status:  status,
-		Code:    http.StatusText(status),
-	})
+// Failure writes a failed response as application/problem+json, the status comes from the error
+func Failure(ctx *gin.Context, err error) {
+	apperr.Write(ctx, err)
 }